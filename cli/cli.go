@@ -0,0 +1,491 @@
+package cli
+
+import (
+	"BynxDB/core"
+	"BynxDB/core/utils"
+	"BynxDB/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const usage = `Usage: bynx [flags] <command> [arguments]
+
+Commands:
+  tables                                   list the tables
+  open <table>                             show the schema of a table and start the shell on it
+  shell                                    start the interactive SQL shell
+  create-table <table> <col>:<type>[:pk][:unique]...
+                                           create a table, types are int and text
+  insert <table> <col>=<value>...          insert one row
+  query <table> [condition]                print the rows matching a SQL condition
+  delete <table> <condition>               delete the rows matching a SQL condition
+  truncate <table>                         delete every row of a table and give back its disk space
+  drop-table <table>                       remove a table and its files
+  compact [table]...                       rewrite the files of the tables densely, all tables by default
+  check [table]...                         verify the files of the tables, all tables by default
+  backup <table> <file>                    write a consistent snapshot of a table to a file
+  restore <file>                           recreate the table held by a backup file
+  migrate [-dry-run] [-page-size n] [-to dir] [table]...
+                                           upgrade the files of the tables to the latest format, all tables by
+                                           default, in place or as copies in dir
+
+Flags:
+`
+
+// * cli carries the state shared by the commands of one invocation.
+type cli struct {
+	engine  *sql.Engine
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	history string
+}
+
+// * Run executes the bynx command line and returns the process exit code.
+func Run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("bynx", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	logDepth := flags.Int("log-depth", -1, "write logs up to this depth to logs/app.log, -1 disables logging")
+	verbose := flags.Bool("v", false, "print database messages to stderr")
+	history := flags.String("history", defaultHistoryPath(), "shell history file, empty disables it")
+	readOnly := flags.Bool("read-only", false, "open the tables read only, refusing any change, alongside other readers")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if *logDepth >= 0 {
+		utils.InitFileLogs()
+		utils.SetLogDepth(*logDepth)
+	} else {
+		utils.SetLogDepth(-1)
+		log.SetOutput(io.Discard)
+	}
+	if *verbose {
+		utils.SetConsole(stderr)
+	} else {
+		utils.SetConsole(io.Discard)
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	c := &cli{
+		engine:  sql.NewEngineWithOptions(&core.Options{ReadOnly: *readOnly}),
+		stdin:   stdin,
+		stdout:  stdout,
+		stderr:  stderr,
+		history: *history,
+	}
+	defer c.engine.Close()
+
+	cmd, cmdArgs := flags.Arg(0), flags.Args()[1:]
+	var err error
+	switch cmd {
+	case "tables":
+		err = c.tables(cmdArgs)
+	case "open":
+		err = c.open(cmdArgs)
+	case "shell":
+		err = c.shell("")
+	case "create-table":
+		err = c.createTable(cmdArgs)
+	case "insert":
+		err = c.insert(cmdArgs)
+	case "query":
+		err = c.query(cmdArgs)
+	case "delete":
+		err = c.delete(cmdArgs)
+	case "truncate":
+		err = c.tableStatement(cmd, "TRUNCATE", cmdArgs)
+	case "drop-table":
+		err = c.tableStatement(cmd, "DROP", cmdArgs)
+	case "compact":
+		err = c.compact(cmdArgs)
+	case "check":
+		err = c.check(cmdArgs)
+	case "backup":
+		err = c.backup(cmdArgs)
+	case "restore":
+		err = c.restore(cmdArgs)
+	case "migrate":
+		err = c.migrate(cmdArgs)
+	case "help":
+		flags.Usage()
+	default:
+		fmt.Fprintln(stderr, "unknown command:", cmd)
+		flags.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".bynx_history")
+}
+
+// * Commands
+
+func (c *cli) tables(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: bynx tables")
+	}
+	names, err := core.Tables()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Fprintln(c.stdout, name)
+	}
+	return nil
+}
+
+func (c *cli) open(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: bynx open <table>")
+	}
+	if err := c.schema(args[0]); err != nil {
+		return err
+	}
+	return c.shell(args[0])
+}
+
+func (c *cli) createTable(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: bynx create-table <table> <col>:<type>[:pk][:unique]...")
+	}
+	stmt := &sql.CreateTable{Table: args[0]}
+	for _, arg := range args[1:] {
+		parts := strings.Split(arg, ":")
+		if len(parts) < 2 {
+			return errors.New("[error] column needs a type: " + arg)
+		}
+		col := sql.ColumnDef{Name: parts[0]}
+		var ok bool
+		if col.Type, ok = sql.ColumnType(parts[1]); !ok {
+			return errors.New("[error] unknown column type: " + parts[1])
+		}
+		for _, opt := range parts[2:] {
+			switch strings.ToLower(opt) {
+			case "pk":
+				col.PrimaryKey = true
+			case "unique":
+				col.Unique = true
+			default:
+				return errors.New("[error] unknown column option: " + opt)
+			}
+		}
+		stmt.Cols = append(stmt.Cols, col)
+	}
+	if _, err := c.engine.Execute(stmt); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "created table", strings.ToUpper(stmt.Table))
+	return nil
+}
+
+// * insert converts every col=value argument to the type of its column, so text needs no quoting.
+func (c *cli) insert(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: bynx insert <table> <col>=<value>...")
+	}
+	db, err := c.engine.Table(args[0])
+	if err != nil {
+		return err
+	}
+	tD := db.TableDef()
+	values := map[string]any{}
+	for _, arg := range args[1:] {
+		name, raw, ok := strings.Cut(arg, "=")
+		if !ok {
+			return errors.New("[error] expected <col>=<value>, got: " + arg)
+		}
+		colIndex, err := tD.ColIndex(name)
+		if err != nil {
+			return err
+		}
+		switch tD.Types[colIndex] {
+		case core.TYPE_INT64:
+			val, err := strconv.Atoi(raw)
+			if err != nil {
+				return errors.New("[error] column " + tD.Cols[colIndex] + " needs an integer, got: " + raw)
+			}
+			values[name] = val
+		default:
+			values[name] = []byte(raw)
+		}
+	}
+	if err := db.InsertRow(values); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "1 row inserted")
+	return nil
+}
+
+func (c *cli) query(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: bynx query <table> [condition]")
+	}
+	query := "SELECT * FROM " + args[0]
+	if len(args) > 1 {
+		query += " WHERE " + strings.Join(args[1:], " ")
+	}
+	return c.exec(query)
+}
+
+func (c *cli) delete(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: bynx delete <table> <condition>")
+	}
+	return c.exec("DELETE FROM " + args[0] + " WHERE " + strings.Join(args[1:], " "))
+}
+
+// * tableStatement runs TRUNCATE or DROP on the table given as the only argument.
+func (c *cli) tableStatement(cmd string, keyword string, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: bynx " + cmd + " <table>")
+	}
+	return c.exec(keyword + " TABLE " + args[0])
+}
+
+// * compact compacts the named tables, or every table, and prints the space each gave back.
+func (c *cli) compact(args []string) error {
+	names := args
+	if len(names) == 0 {
+		var err error
+		if names, err = core.Tables(); err != nil {
+			return err
+		}
+	}
+	var total int64
+	for _, name := range names {
+		db, err := c.engine.Table(name)
+		if err != nil {
+			return err
+		}
+		reclaimed, err := db.Compact()
+		if err != nil {
+			return err
+		}
+		total += reclaimed
+		fmt.Fprintf(c.stdout, "compacted %s, %d bytes reclaimed\n", strings.ToUpper(name), reclaimed)
+	}
+	if len(names) > 1 {
+		fmt.Fprintf(c.stdout, "%d bytes reclaimed in total\n", total)
+	}
+	return nil
+}
+
+// * check verifies the named tables, or every table, printing the trees of each, the problems found and the warnings.
+func (c *cli) check(args []string) error {
+	names := args
+	if len(names) == 0 {
+		var err error
+		if names, err = core.Tables(); err != nil {
+			return err
+		}
+	}
+	failed := 0
+	for _, name := range names {
+		db, err := c.engine.Table(name)
+		if err != nil {
+			return err
+		}
+		report, err := db.Verify()
+		var verifyErr *core.VerifyError
+		if err != nil && !errors.As(err, &verifyErr) {
+			return err
+		}
+		for _, tree := range report.Trees {
+			fmt.Fprintf(c.stdout, "%s: %d pages, %d free, %d nodes, %d items, depth %d, %d underfilled, %d overfilled\n",
+				tree.Tree, tree.Pages, tree.FreePages, tree.Nodes, tree.Items, tree.Depth, tree.Underfilled, tree.Overfilled)
+		}
+		for _, problem := range report.Problems {
+			fmt.Fprintln(c.stdout, "  "+problem.String())
+		}
+		for _, warning := range report.Warnings {
+			fmt.Fprintln(c.stdout, "  warning: "+warning.String())
+		}
+		if len(report.Problems) != 0 {
+			failed++
+			fmt.Fprintf(c.stdout, "%s: %d problems\n", strings.ToUpper(name), len(report.Problems))
+		} else {
+			fmt.Fprintf(c.stdout, "%s: ok\n", strings.ToUpper(name))
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("[error] check found problems in %d of %d tables", failed, len(names))
+	}
+	return nil
+}
+
+func (c *cli) backup(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: bynx backup <table> <file>")
+	}
+	db, err := c.engine.Table(args[0])
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	if err := db.Backup(file); err != nil {
+		file.Close()
+		os.Remove(args[1])
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "backed up", strings.ToUpper(args[0]), "to", args[1])
+	return nil
+}
+
+func (c *cli) restore(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: bynx restore <file>")
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	dir, err := core.DataDir()
+	if err != nil {
+		return err
+	}
+	name, err := core.Restore(file, dir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "restored table", name)
+	return nil
+}
+
+// * migrate upgrades the files of the named tables, or every table, printing what was done to each file.
+func (c *cli) migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	opts := &core.MigrateOptions{}
+	flags.BoolVar(&opts.DryRun, "dry-run", false, "check that the files can be upgraded without changing them")
+	flags.IntVar(&opts.PageSize, "page-size", 0, "page size of version 0 files, which don't record it, default the one of this machine")
+	flags.StringVar(&opts.Dir, "to", "", "write the upgraded files to this directory instead of replacing them")
+	if err := flags.Parse(args); err != nil {
+		return errors.New("usage: bynx migrate [-dry-run] [-page-size n] [-to dir] [table]...")
+	}
+	names := flags.Args()
+	if len(names) == 0 {
+		var err error
+		if names, err = core.Tables(); err != nil {
+			return err
+		}
+	}
+	verb := "migrated"
+	if opts.DryRun {
+		verb = "would migrate"
+	}
+	migrated := 0
+	for _, name := range names {
+		results, err := core.MigrateTable(name, opts)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			file := filepath.Base(res.Path)
+			if res.From == res.To {
+				fmt.Fprintf(c.stdout, "%s: up to date, version %d\n", file, res.To)
+				continue
+			}
+			migrated++
+			fmt.Fprintf(c.stdout, "%s: %s from version %d to %d, %d pages: %s\n", file, verb, res.From, res.To, res.Pages,
+				strings.Join(res.Steps, ", "))
+		}
+	}
+	fmt.Fprintf(c.stdout, "%s %d %s\n", verb, migrated, plural(migrated, "file"))
+	return nil
+}
+
+// * exec runs every statement of a script and prints the results.
+func (c *cli) exec(script string) error {
+	stmts, err := sql.ParseScript(script)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		res, err := c.engine.Execute(stmt)
+		if err != nil {
+			return err
+		}
+		printResult(c.stdout, stmt, res)
+	}
+	return nil
+}
+
+// * schema prints the columns of a table, primary key first.
+func (c *cli) schema(table string) error {
+	db, err := c.engine.Table(table)
+	if err != nil {
+		return err
+	}
+	tD := db.TableDef()
+	res := &sql.Result{Columns: []sql.Column{
+		{Name: "COLUMN", Type: core.TYPE_BYTE},
+		{Name: "TYPE", Type: core.TYPE_BYTE},
+		{Name: "KEY", Type: core.TYPE_BYTE},
+		{Name: "CONSTRAINTS", Type: core.TYPE_BYTE},
+	}}
+	for i, col := range tD.Cols {
+		key := ""
+		if i == 0 {
+			key = "PRIMARY KEY"
+		}
+		for _, uniqueCol := range tD.UniqueCols {
+			if uniqueCol == i {
+				key = "UNIQUE"
+			}
+		}
+		for _, fk := range tD.ForeignKeys {
+			if strings.EqualFold(fk.Col, col) {
+				key = strings.TrimSpace(key + " REFERENCES " + fk.RefTable + " ON DELETE " + fk.OnDelete.String())
+			}
+		}
+		var constraints []string
+		if val, ok := tD.Defaults[col]; ok {
+			constraints = append(constraints, "DEFAULT "+formatValue(val))
+		}
+		for _, check := range tD.Checks {
+			if check.Col == col {
+				constraints = append(constraints, "CHECK ("+check.String()+")")
+			}
+		}
+		res.Rows = append(res.Rows, []any{[]byte(col), []byte(sql.TypeName(tD.Types[i])), []byte(key),
+			[]byte(strings.Join(constraints, " "))})
+	}
+	fmt.Fprintln(c.stdout, "Table", strings.ToUpper(table))
+	printTable(c.stdout, res)
+	return nil
+}
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const shellHelp = `Statements end with a semicolon and may span several lines:
+  CREATE TABLE t (id INT PRIMARY KEY, name TEXT, email TEXT UNIQUE);
+  INSERT INTO t VALUES (1, 'Alice', 'alice@example.com');
+  SELECT * FROM t WHERE id BETWEEN 1 AND 10 AND name = 'Alice';
+  SELECT name FROM t ORDER BY email DESC LIMIT 10 OFFSET 20;
+  SELECT name, COUNT(*), MAX(id) FROM t GROUP BY name;
+  UPDATE t SET name = 'Bob' WHERE id = 1;
+  DELETE FROM t WHERE id > 5 OR name = 'Bob';
+  TRUNCATE TABLE t;
+  DROP TABLE t;
+
+Commands:
+  .tables           list the tables
+  .schema [table]   show the columns of a table
+  .history          list the previous statements
+  !n                run statement n of the history again, !! runs the last one
+  .help             show this help
+  .quit             leave the shell
+`
+
+// * maxHistory is the number of entries kept in the history file
+const maxHistory = 1000
+
+type shell struct {
+	*cli
+	table   string // * Table given to open, used by .schema without an argument
+	entries []string
+}
+
+// * shell reads statements from stdin until .quit or the end of input. Every statement or command is added to the
+// * history, which is kept in the history file between sessions.
+func (c *cli) shell(table string) error {
+	sh := &shell{cli: c, table: strings.ToUpper(table)}
+	sh.loadHistory()
+	fmt.Fprintln(c.stdout, "BynxDB shell, .help for help")
+
+	scanner := bufio.NewScanner(c.stdin)
+	var buf strings.Builder
+	for {
+		if buf.Len() == 0 {
+			fmt.Fprint(c.stdout, sh.prompt())
+		} else {
+			fmt.Fprint(c.stdout, "   ...> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if buf.Len() == 0 {
+			if line == "" {
+				continue
+			}
+			if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "!") {
+				quit, err := sh.command(line)
+				if err != nil {
+					fmt.Fprintln(c.stdout, "Error:", err)
+				}
+				if quit {
+					break
+				}
+				continue
+			}
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		if strings.HasSuffix(line, ";") {
+			sh.run(strings.TrimSpace(buf.String()))
+			buf.Reset()
+		}
+	}
+	fmt.Fprintln(c.stdout)
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	// * A statement missing its final semicolon at the end of input still runs
+	if rest := strings.TrimSpace(buf.String()); rest != "" {
+		sh.run(rest)
+	}
+	return sh.saveHistory()
+}
+
+func (sh *shell) prompt() string {
+	if sh.table != "" {
+		return "bynx(" + strings.ToLower(sh.table) + ")> "
+	}
+	return "bynx> "
+}
+
+// * run executes a statement and records it in the history.
+func (sh *shell) run(statement string) {
+	sh.addHistory(statement)
+	if err := sh.exec(statement); err != nil {
+		fmt.Fprintln(sh.stdout, "Error:", err)
+	}
+}
+
+// * command handles a dot command or a history recall. It reports whether the shell should stop.
+func (sh *shell) command(line string) (bool, error) {
+	if strings.HasPrefix(line, "!") {
+		statement, err := sh.recall(line[1:])
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintln(sh.stdout, statement)
+		if strings.HasPrefix(statement, ".") {
+			return sh.command(statement)
+		}
+		sh.run(statement)
+		return false, nil
+	}
+	sh.addHistory(line)
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ".quit", ".exit":
+		return true, nil
+	case ".help":
+		fmt.Fprint(sh.stdout, shellHelp)
+	case ".tables":
+		return false, sh.tables(nil)
+	case ".schema":
+		table := sh.table
+		if len(fields) > 1 {
+			table = fields[1]
+		}
+		if table == "" {
+			return false, errors.New("usage: .schema <table>")
+		}
+		return false, sh.schema(table)
+	case ".history":
+		for i, entry := range sh.entries {
+			fmt.Fprintf(sh.stdout, "%5d  %s\n", i+1, strings.ReplaceAll(entry, "\n", " "))
+		}
+	default:
+		return false, errors.New("unknown command: " + fields[0] + ", .help for help")
+	}
+	return false, nil
+}
+
+func (sh *shell) recall(ref string) (string, error) {
+	// * Recalls are not added to the history themselves, only the statement they run
+	if len(sh.entries) == 0 {
+		return "", errors.New("history is empty")
+	}
+	if ref == "!" {
+		return sh.entries[len(sh.entries)-1], nil
+	}
+	n, err := strconv.Atoi(ref)
+	if err != nil || n < 1 || n > len(sh.entries) {
+		return "", errors.New("no such history entry: " + ref)
+	}
+	return sh.entries[n-1], nil
+}
+
+// * History
+
+func (sh *shell) addHistory(entry string) {
+	if len(sh.entries) != 0 && sh.entries[len(sh.entries)-1] == entry {
+		return
+	}
+	sh.entries = append(sh.entries, entry)
+}
+
+// * History entries are stored one per line, multi line statements are joined with spaces.
+func (sh *shell) loadHistory() {
+	if sh.history == "" {
+		return
+	}
+	data, err := os.ReadFile(sh.history)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			sh.entries = append(sh.entries, line)
+		}
+	}
+}
+
+func (sh *shell) saveHistory() error {
+	if sh.history == "" {
+		return nil
+	}
+	entries := sh.entries
+	if len(entries) > maxHistory {
+		entries = entries[len(entries)-maxHistory:]
+	}
+	var sb strings.Builder
+	for _, entry := range entries {
+		sb.WriteString(strings.ReplaceAll(entry, "\n", " "))
+		sb.WriteByte('\n')
+	}
+	return os.WriteFile(sh.history, []byte(sb.String()), 0600)
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"BynxDB/core"
+	"BynxDB/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// * printResult prints the rows of a SELECT as a table and the affected row count of anything else.
+func printResult(w io.Writer, stmt sql.Statement, res *sql.Result) {
+	switch s := stmt.(type) {
+	case *sql.Select:
+		printTable(w, res)
+		fmt.Fprintf(w, "(%d %s)\n", len(res.Rows), plural(len(res.Rows), "row"))
+	case *sql.CreateTable:
+		fmt.Fprintln(w, "created table", strings.ToUpper(s.Table))
+	case *sql.Insert:
+		fmt.Fprintf(w, "%d %s inserted\n", res.RowsAffected, plural(res.RowsAffected, "row"))
+	case *sql.Update:
+		fmt.Fprintf(w, "%d %s updated\n", res.RowsAffected, plural(res.RowsAffected, "row"))
+	case *sql.Delete:
+		fmt.Fprintf(w, "%d %s deleted\n", res.RowsAffected, plural(res.RowsAffected, "row"))
+	case *sql.Truncate:
+		fmt.Fprintln(w, "truncated table", strings.ToUpper(s.Table))
+	case *sql.Drop:
+		fmt.Fprintln(w, "dropped table", strings.ToUpper(s.Table))
+	case *sql.Explain:
+		printTable(w, res)
+	}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+/*
+* printTable draws a result set with aligned columns, numbers aligned right:
+*
+*	+----+-------+
+*	| ID | NAME  |
+*	+----+-------+
+*	| 10 | Mudit |
+*	+----+-------+
+ */
+func printTable(w io.Writer, res *sql.Result) {
+	cells := make([][]string, len(res.Rows))
+	widths := make([]int, len(res.Columns))
+	for i, col := range res.Columns {
+		widths[i] = utf8.RuneCountInString(col.Name)
+	}
+	for r, row := range res.Rows {
+		cells[r] = make([]string, len(row))
+		for i, val := range row {
+			cells[r][i] = formatValue(val)
+			widths[i] = max(widths[i], utf8.RuneCountInString(cells[r][i]))
+		}
+	}
+
+	var sb strings.Builder
+	border := func() {
+		sb.WriteByte('+')
+		for _, width := range widths {
+			sb.WriteString(strings.Repeat("-", width+2))
+			sb.WriteByte('+')
+		}
+		sb.WriteByte('\n')
+	}
+	line := func(values []string, rightAlign func(int) bool) {
+		sb.WriteByte('|')
+		for i, val := range values {
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(val))
+			sb.WriteByte(' ')
+			if rightAlign(i) {
+				sb.WriteString(pad + val)
+			} else {
+				sb.WriteString(val + pad)
+			}
+			sb.WriteString(" |")
+		}
+		sb.WriteByte('\n')
+	}
+
+	border()
+	line(res.ColumnNames(), func(int) bool { return false })
+	border()
+	for _, row := range cells {
+		line(row, func(i int) bool {
+			return res.Columns[i].Type == core.TYPE_INT64 || res.Columns[i].Type == sql.TYPE_FLOAT64
+		})
+	}
+	if len(cells) != 0 {
+		border()
+	}
+	fmt.Fprint(w, sb.String())
+}
+
+// * formatValue renders a column value, byte values that aren't printable text are shown in hex.
+func formatValue(val any) string {
+	switch data := val.(type) {
+	case int:
+		return strconv.Itoa(data)
+	case float64:
+		return strconv.FormatFloat(data, 'f', -1, 64)
+	case []byte:
+		if utf8.Valid(data) && !strings.ContainsFunc(string(data), isControl) {
+			return string(data)
+		}
+		return fmt.Sprintf("x'%x'", data)
+	case nil:
+		return "NULL"
+	}
+	return fmt.Sprint(val)
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
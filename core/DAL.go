@@ -4,7 +4,9 @@ import (
 	"BynxDB/core/utils"
 	"errors"
 	"fmt"
-	"os"
+	"io"
+	"sync"
+	"time"
 )
 
 type pgNum uint64
@@ -16,6 +18,7 @@ const (
 
 type page struct {
 	Num  pgNum
+	Type pageType
 	Data []byte
 }
 
@@ -23,6 +26,20 @@ type Options struct {
 	PageSize       int
 	MinFillPercent float32
 	MaxFillPercent float32
+	// * ReadOnly opens existing files read only under a shared lock, which other read only openers share.
+	ReadOnly bool
+	// * LockTimeout is how long opening waits for another process to let go of a file, zero fails at once with
+	// * ErrLocked.
+	LockTimeout time.Duration
+	// * Storage holds the files, nil is FileStorage.
+	Storage Storage
+}
+
+func (opts *Options) storage() Storage {
+	if opts.Storage == nil {
+		return FileStorage
+	}
+	return opts.Storage
 }
 
 var DefaultOptions = &Options{
@@ -31,26 +48,64 @@ var DefaultOptions = &Options{
 }
 
 type DAL struct {
-	file           *os.File
+	storage Storage
+	file    StorageFile
+	// * unlock lets go of the lock on the file, nil when the DAL holds none.
+	unlock func()
+	// * readOnly refuses every page write, the file being opened read only.
+	readOnly       bool
 	pageSize       int
 	MinFillPercent float32
 	MaxFillPercent float32
+	// * batching defers the meta and freelist writes to commit while a batch of changes is applied.
+	batching bool
+	// * snapshot is the state a running backup copies, snapMu orders page writes with its reads.
+	snapMu   sync.Mutex
+	snapshot *pageSnapshot
+	// * journal saves the pages a write overwrites, nil for files written outside of a table
+	journal *journal
+	// * version is the format version the file is written in, zero being FORMAT_VERSION. Only migrations write
+	// * older ones.
+	version uint16
 
 	*freeList
 	*Meta
 }
 
 func DalCreate(path string, options *Options) (*DAL, error) {
-	dal := &DAL{Meta: newMetaPage(), pageSize: options.PageSize, MinFillPercent: options.MinFillPercent, MaxFillPercent: options.MaxFillPercent}
+	dal := &DAL{Meta: newMetaPage(), storage: options.storage(), pageSize: options.PageSize,
+		MinFillPercent: options.MinFillPercent, MaxFillPercent: options.MaxFillPercent, readOnly: options.ReadOnly}
+	exists, err := dal.storage.Exists(path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists && !validPageSize(dal.pageSize) {
+		return nil, fmt.Errorf("[error] unsupported page size %d", dal.pageSize)
+	}
+	if dal.file, err = dal.storage.Open(path, options.ReadOnly); err != nil {
+		return nil, err
+	}
+	if dal.unlock, err = dal.storage.Lock(path, !options.ReadOnly, options.LockTimeout); err != nil {
+		_ = dal.Close()
+		return nil, err
+	}
+	// * Another process may have created the file while the lock was awaited
+	size, err := dal.file.Size()
+	if err != nil {
+		_ = dal.Close()
+		return nil, err
+	}
 	// * If a database exists
-	if _, err := os.Stat(path); err == nil {
+	if exists || size > 0 {
 		// // fmt.println("Database Exists")
 		utils.InfoLogAndPrint("Database Exists")
-		dal.file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+		// * The file keeps the page size it was created with
+		header, err := readFileHeader(dal.file, path)
 		if err != nil {
 			_ = dal.Close()
 			return nil, err
 		}
+		dal.pageSize = header.pageSize
 		Meta, err := dal.Readmeta()
 
 		if err != nil {
@@ -63,130 +118,239 @@ func DalCreate(path string, options *Options) (*DAL, error) {
 		freeList, err := dal.Readfreelist()
 
 		if err != nil {
+			_ = dal.Close()
 			return nil, err
 		}
 		// // fmt.println(dal.Root)
 		dal.freeList = freeList
-		utils.Info(1, "Loaded Database: ", "Freelist: ", dal.freelistPage, "TableDef: ", dal.TableDefPage, "Root: ", dal.Root)
-	} else if errors.Is(err, os.ErrNotExist) { // *Creating Database
-		utils.Info(1, "Creating new Database")
-		_, err := os.Stat("./db/")
-		if os.IsNotExist(err) {
-			err = os.Mkdir("./db/", 0777)
-			if err != nil {
-				_ = dal.Close()
-				return nil, err
-			}
-		}
-		dal.file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
-		if err != nil {
+		// * A file longer than the max page of its freelist would hand out pages still in use
+		if pages := pgNum(size / int64(dal.pageSize)); pages > dal.maxPage {
 			_ = dal.Close()
-			return nil, err
+			return nil, corruptPage(dal.freelistPage, "the freelist ends at page %d of a file of %d pages", dal.maxPage, pages)
 		}
+		utils.Info(1, "Loaded Database: ", "Freelist: ", dal.freelistPage, "TableDef: ", dal.TableDefPage, "Root: ", dal.Root)
+	} else { // *Creating Database
+		utils.Info(1, "Creating new Database")
 		dal.freeList = freeListCreate()
+		dal.rowCountKnown = true
 		dal.freelistPage = dal.GetNextPage()
 		if _, err := dal.Writefreelist(); err != nil {
+			_ = dal.Close()
 			return nil, err
 		}
 		if _, err := dal.Writemeta(dal.Meta); err != nil {
+			_ = dal.Close()
 			return nil, err
 		}
 		utils.Info(1, "New Database: ", "Freelist: ", dal.freelistPage, "TableDef: ", dal.TableDefPage, "Root: ", dal.Root)
-	} else {
-		return nil, err
 	}
 
 	return dal, nil
 }
 
 func (d *DAL) Close() error {
+	d.snapMu.Lock()
+	defer d.snapMu.Unlock()
 	if d.file != nil {
-		if err := d.file.Close(); err != nil {
+		err := d.file.Close()
+		d.file = nil
+		if d.unlock != nil {
+			d.unlock()
+			d.unlock = nil
+		}
+		if err != nil {
 			return fmt.Errorf("Could not close file: %s", err)
 		}
-		d.file = nil
 	}
 	return nil
 }
 
 // * Page Auxi Functions
 
-// * Allocate space in memort the size of a page in disk
-func (d *DAL) Allocateemptypage() *page {
+// * Allocate space in memort for the data of a page in disk, the page without its header
+func (d *DAL) Allocateemptypage(typ pageType) *page {
 	return &page{
-		Data: make([]byte, d.pageSize),
+		Type: typ,
+		Data: make([]byte, d.pageSize-pageHeaderSize),
 	}
 }
 
+// * Readpage reads a page and checks its header. Pages past the end of the file are corrupt too: nothing points there.
 func (d *DAL) Readpage(pageNum pgNum) (*page, error) {
-	p := d.Allocateemptypage()
-
-	offset := int(pageNum) * d.pageSize
+	buf := make([]byte, d.pageSize)
+	offset := int64(pageNum) * int64(d.pageSize)
+	if _, err := d.file.ReadAt(buf, offset); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, corruptPage(pageNum, "past the end of the file")
+		}
+		return nil, err
+	}
+	return decodePage(pageNum, buf)
+}
 
-	if _, err := d.file.ReadAt(p.Data, int64(offset)); err != nil {
+// * readPageOf reads a page that has to be of the given type.
+func (d *DAL) readPageOf(pageNum pgNum, typ pageType) (*page, error) {
+	p, err := d.Readpage(pageNum)
+	if err != nil {
 		return nil, err
 	}
+	if p.Type != typ {
+		return nil, corruptPage(pageNum, "%s page where a %s page was expected", p.Type, typ)
+	}
 	return p, nil
 }
 
 func (d *DAL) Writepage(p *page) error {
 	utils.Info(4, "Writing Page: ", p.Num)
+	if d.readOnly {
+		return ErrReadOnly
+	}
+	buf, err := encodePage(p, d.pageSize)
+	if err != nil {
+		return err
+	}
+	d.snapMu.Lock()
+	defer d.snapMu.Unlock()
+	if err := d.savePage(p.Num); err != nil {
+		return err
+	}
+	if err := d.journal.save(d, p.Num); err != nil {
+		return err
+	}
 	offset := int64(p.Num) * int64(d.pageSize)
-	_, err := d.file.WriteAt(p.Data, offset)
+	if _, err = d.file.WriteAt(buf, offset); err != nil {
+		return d.journal.fail(err)
+	}
+	return nil
+}
+
+// * restorePage writes back the data a page held before a write that is rolled back, passing the journal by.
+func (d *DAL) restorePage(data []byte, offset int64) error {
+	d.snapMu.Lock()
+	defer d.snapMu.Unlock()
+	_, err := d.file.WriteAt(data, offset)
 	return err
 }
 
+// * truncate cuts the file to size pages, once the write under way is made.
+func (d *DAL) truncate(pages pgNum) error {
+	size := int64(pages) * int64(d.pageSize)
+	if later, err := d.journal.truncateLater(d, size); later || err != nil {
+		return err
+	}
+	return d.file.Truncate(size)
+}
+
 // * (Maintaining) Persistance Auxi Functions
 
 func (d *DAL) Writemeta(metaToWrite *Meta) (*page, error) {
 	utils.Info(1, "Writing Meta: ", "Freelist: ", metaToWrite.freelistPage, "TableDef: ", metaToWrite.TableDefPage, "Root: ", metaToWrite.Root)
-	p := d.Allocateemptypage()
-	p.Num = metaPageNum
-
-	metaToWrite.Serialize(p.Data)
-
+	p := d.metaPage(metaToWrite)
 	if err := d.Writepage(p); err != nil {
 		return nil, err
 	}
 	return p, nil
 }
 
+// * metaPage lays out the meta page: the file header followed by the fields of Meta.
+func (d *DAL) metaPage(m *Meta) *page {
+	p := d.Allocateemptypage(PAGE_META)
+	p.Num = metaPageNum
+	header := &fileHeader{version: d.formatVersion(), pageSize: d.pageSize}
+	header.serialize(p.Data)
+	m.Serialize(p.Data[fileHeaderSize:])
+	return p
+}
+
+// * commitMeta persists the meta page, unless a batch is being applied, in which case commit writes it.
+func (d *DAL) commitMeta() error {
+	if d.batching {
+		return nil
+	}
+	_, err := d.Writemeta(d.Meta)
+	return err
+}
+
+// * commit ends a batch by writing the meta page and the freelist once.
+func (d *DAL) commit() error {
+	d.batching = false
+	if _, err := d.Writemeta(d.Meta); err != nil {
+		return err
+	}
+	_, err := d.Writefreelist()
+	return err
+}
+
 func (d *DAL) Readmeta() (*Meta, error) {
 	utils.Info(1, "Reading meta page: ", metaPageNum)
-	p, err := d.Readpage(metaPageNum)
+	p, err := d.readPageOf(metaPageNum, PAGE_META)
 
 	if err != nil {
 		return nil, err
 	}
 
 	Meta := newMetaPage()
-	Meta.Deserialize(p.Data)
+	Meta.Deserialize(p.Data[fileHeaderSize:])
 	return Meta, nil
 }
 
+// * formatVersion returns the format version the file is written in.
+func (d *DAL) formatVersion() uint16 {
+	if d.version == 0 {
+		return FORMAT_VERSION
+	}
+	return d.version
+}
+
+// * freelistPages lays out fL as the chain of pages starting at head.
+func (d *DAL) freelistPages(fL *freeList, head pgNum) []*page {
+	next := fL.overflowPages(d.pageSize-pageHeaderSize, d.formatVersion())
+	pages := make([]*page, len(next)+1)
+	bufs := make([][]byte, len(pages))
+	for i := range pages {
+		pages[i] = d.Allocateemptypage(PAGE_FREELIST)
+		pages[i].Num = head
+		if i > 0 {
+			pages[i].Num = next[i-1]
+		}
+		bufs[i] = pages[i].Data
+	}
+	fL.serialize(bufs, next, d.formatVersion())
+	return pages
+}
+
+// * Writefreelist writes the chain of freelist pages and returns the first one.
 func (d *DAL) Writefreelist() (*page, error) {
-	p := d.Allocateemptypage()
-	p.Num = d.freelistPage
-	d.freeList.serialize(p.Data)
+	pages := d.freelistPages(d.freeList, d.freelistPage)
 	utils.Info(1, "Writing Freelist: ", d.freeList.State())
-	if err := d.Writepage(p); err != nil {
-		return nil, err
+	for _, p := range pages {
+		if err := d.Writepage(p); err != nil {
+			return nil, err
+		}
 	}
-	d.freelistPage = p.Num
-	return p, nil
+	return pages[0], nil
 
 }
 
 func (d *DAL) Readfreelist() (*freeList, error) {
 	utils.Info(1, "Reading Freelist.")
-	p, err := d.Readpage(d.freelistPage)
-	if err != nil {
-		return nil, err
-	}
-
 	freeList := freeListCreate()
-
-	freeList.deserialize(p.Data)
+	seen := map[pgNum]bool{}
+	for pageNum := d.freelistPage; pageNum != 0; {
+		if seen[pageNum] {
+			return nil, corruptPage(pageNum, "freelist chain loops back to the page")
+		}
+		seen[pageNum] = true
+		p, err := d.readPageOf(pageNum, PAGE_FREELIST)
+		if err != nil {
+			return nil, err
+		}
+		next, err := freeList.deserialize(p.Data, d.formatVersion())
+		if err != nil {
+			return nil, corruptPage(pageNum, "%v", err)
+		}
+		pageNum = next
+	}
 	utils.Info(2, "Reading Freelist: ", freeList.State())
 	return freeList, nil
 
@@ -204,19 +368,21 @@ func (d *DAL) nodeCreate(items []*Item, childNodes []pgNum) *Node {
 }
 
 func (d *DAL) Getnode(pageNum pgNum) (*Node, error) {
-	p, err := d.Readpage(pageNum)
+	p, err := d.readPageOf(pageNum, PAGE_NODE)
 	if err != nil {
 		return nil, err
 	}
 	node := NodeCreate()
-	node.Deserialize(p.Data)
+	if err := node.Deserialize(p.Data); err != nil {
+		return nil, corruptPage(pageNum, "%v", err)
+	}
 	node.Pagenum = pageNum
 	node.DAL = d
 	return node, nil
 }
 
 func (d *DAL) Writenode(n *Node) (*Node, error) {
-	p := d.Allocateemptypage()
+	p := d.Allocateemptypage(PAGE_NODE)
 	if n.Pagenum == 0 {
 		utils.Warn("Writing Node With 0 Pg num")
 		p.Num = d.GetNextPage()
@@ -255,19 +421,25 @@ func (d *DAL) isUnderPopulated(node *Node) bool {
 }
 
 // * Return the index + 1 of the Item till which the minThreshold of a nodeSize hold true.
+// * When splitting, the item at the returned index moves up to the parent, so at least one item has to be left over
+// * for the new right node.
 func (d *DAL) getSplitIndex(node *Node, splitNec bool) int {
 	size := nodeHeaderSize
 	minSize := d.minThreshold()
+	lastIndex := len(node.Items) - 1
+	if splitNec {
+		lastIndex--
+	}
 	// fmt.println(minSize)
 	for i := range node.Items {
 		size += node.elementSize(i)
 		// fmt.println(size, i)
-		if float32(size) > minSize && i < len(node.Items)-1 {
+		if float32(size) > minSize && i < lastIndex {
 			return i + 1
 		}
 	}
-	if splitNec && len(node.Items) == 2 {
-		return 1
+	if splitNec && len(node.Items) >= 3 {
+		return len(node.Items) - 2
 	}
 	return -1
 }
@@ -0,0 +1,294 @@
+package core
+
+import (
+	"errors"
+	"sort"
+)
+
+/*
+* Aggregations. Rows are streamed from the plan of the predicates and folded into one accumulator per group, so only
+* the groups are held in memory. Without GROUP BY some aggregates don't read the rows at all:
+*
+*	COUNT(*)       without predicates or filter, the row count kept in the meta page of the records tree
+*	MIN / MAX      on the primary key or a unique column, the first matching row of an in order walk of its tree
+ */
+
+type AggFunc int
+
+const (
+	AGG_COUNT AggFunc = iota
+	AGG_SUM
+	AGG_MIN
+	AGG_MAX
+	AGG_AVG
+)
+
+var aggNames = map[AggFunc]string{AGG_COUNT: "COUNT", AGG_SUM: "SUM", AGG_MIN: "MIN", AGG_MAX: "MAX", AGG_AVG: "AVG"}
+
+func (f AggFunc) String() string {
+	return aggNames[f]
+}
+
+// * Aggregate is a function over a column. Col is empty for COUNT(*).
+type Aggregate struct {
+	Func AggFunc
+	Col  string
+}
+
+func CountAll() Aggregate        { return Aggregate{Func: AGG_COUNT} }
+func Count(col string) Aggregate { return Aggregate{Func: AGG_COUNT, Col: col} }
+func Sum(col string) Aggregate   { return Aggregate{Func: AGG_SUM, Col: col} }
+func Min(col string) Aggregate   { return Aggregate{Func: AGG_MIN, Col: col} }
+func Max(col string) Aggregate   { return Aggregate{Func: AGG_MAX, Col: col} }
+func Avg(col string) Aggregate   { return Aggregate{Func: AGG_AVG, Col: col} }
+
+func (a Aggregate) String() string {
+	if a.Col == "" {
+		return a.Func.String() + "(*)"
+	}
+	return a.Func.String() + "(" + a.Col + ")"
+}
+
+// * AggregateOptions group the rows and filter them beyond the predicates, like QueryOptions.Filter.
+type AggregateOptions struct {
+	GroupBy []string
+	Filter  func(row []any) bool
+}
+
+/*
+* Aggregate computes aggs over the rows matching preds. The result has one row per group, ordered by the group
+* columns, holding the group values followed by one value per aggregate. Without GROUP BY there is exactly one row,
+* without aggregates it lists the distinct groups.
+*
+* COUNT returns an int, SUM, MIN and MAX the type of their column and AVG a float64. SUM and AVG take integer columns
+* only. Over no rows SUM, MIN, MAX and AVG are nil.
+ */
+func (db *DB) Aggregate(aggs []Aggregate, opts *AggregateOptions, preds ...Predicate) (*ResultSet, error) {
+	if opts == nil {
+		opts = &AggregateOptions{}
+	}
+	tD := db.records.TableDef
+	if len(aggs) == 0 && len(opts.GroupBy) == 0 {
+		return nil, errors.New("[error] no aggregate or group given")
+	}
+	aggCols := make([]int, len(aggs))
+	for i, agg := range aggs {
+		aggCols[i] = -1
+		if agg.Col == "" {
+			if agg.Func != AGG_COUNT {
+				return nil, errors.New("[error] " + agg.Func.String() + " needs a column")
+			}
+			continue
+		}
+		colIndex, err := tD.ColIndex(agg.Col)
+		if err != nil {
+			return nil, err
+		}
+		if (agg.Func == AGG_SUM || agg.Func == AGG_AVG) && tD.Types[colIndex] != TYPE_INT64 {
+			return nil, errors.New("[error] " + agg.String() + " needs an integer column")
+		}
+		aggCols[i] = colIndex
+	}
+	var groupCols []int
+	for _, col := range opts.GroupBy {
+		colIndex, err := tD.ColIndex(col)
+		if err != nil {
+			return nil, err
+		}
+		groupCols = append(groupCols, colIndex)
+	}
+
+	rs := &ResultSet{}
+	for _, colIndex := range groupCols {
+		rs.Columns = append(rs.Columns, tD.Cols[colIndex])
+	}
+	for i, agg := range aggs {
+		if aggCols[i] != -1 {
+			agg.Col = tD.Cols[aggCols[i]]
+		}
+		rs.Columns = append(rs.Columns, agg.String())
+	}
+
+	if len(groupCols) == 0 {
+		row, err := db.aggregateAll(aggs, aggCols, opts.Filter, preds)
+		if err != nil {
+			return nil, err
+		}
+		rs.Rows = [][]any{row}
+		return rs, nil
+	}
+
+	plan, err := db.Plan(preds...)
+	if err != nil {
+		return nil, err
+	}
+	// * Groups are keyed by the encoded group values
+	groups := map[string]*aggGroup{}
+	err = plan.Each(func(row []any) (bool, error) {
+		if opts.Filter != nil && !opts.Filter(row) {
+			return true, nil
+		}
+		var key []byte
+		for _, colIndex := range groupCols {
+			key, _ = checkTypeAndEncodeByte(tD, colIndex, row[colIndex], key)
+		}
+		group, ok := groups[string(key)]
+		if !ok {
+			group = &aggGroup{accs: make([]accumulator, len(aggs))}
+			for _, colIndex := range groupCols {
+				group.values = append(group.values, row[colIndex])
+			}
+			groups[string(key)] = group
+		}
+		for i := range aggs {
+			group.accs[i].add(row, aggCols[i])
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		row := group.values
+		for i, agg := range aggs {
+			row = append(row, group.accs[i].result(agg.Func))
+		}
+		rs.Rows = append(rs.Rows, row)
+	}
+	sort.Slice(rs.Rows, func(i, j int) bool {
+		for k := range groupCols {
+			if c := compareValues(rs.Rows[i][k], rs.Rows[j][k]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return rs, nil
+}
+
+// * aggregateAll computes the single row of an aggregation without GROUP BY, answering what it can without a scan.
+func (db *DB) aggregateAll(aggs []Aggregate, aggCols []int, filter func(row []any) bool, preds []Predicate) ([]any, error) {
+	tD := db.records.TableDef
+	out := make([]any, len(aggs))
+	accs := make([]accumulator, len(aggs))
+	var scanned []int
+	for i, agg := range aggs {
+		switch {
+		case agg.Func == AGG_COUNT && agg.Col == "" && len(preds) == 0 && filter == nil:
+			count, err := db.records.Count()
+			if err != nil {
+				return nil, err
+			}
+			out[i] = count
+		case (agg.Func == AGG_MIN || agg.Func == AGG_MAX) && isKeyCol(tD, aggCols[i]):
+			end, err := db.indexEnd(aggCols[i], agg.Func == AGG_MAX, filter, preds)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = end
+		default:
+			scanned = append(scanned, i)
+		}
+	}
+	if len(scanned) == 0 {
+		return out, nil
+	}
+	plan, err := db.Plan(preds...)
+	if err != nil {
+		return nil, err
+	}
+	err = plan.Each(func(row []any) (bool, error) {
+		if filter != nil && !filter(row) {
+			return true, nil
+		}
+		for _, i := range scanned {
+			accs[i].add(row, aggCols[i])
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range scanned {
+		out[i] = accs[i].result(aggs[i].Func)
+	}
+	return out, nil
+}
+
+// * indexEnd returns the smallest, or with last the largest, value of a key column among the matching rows by walking
+// * its tree in order and stopping at the first match. Legacy tables fall back to a scan.
+func (db *DB) indexEnd(colIndex int, last bool, filter func(row []any) bool, preds []Predicate) (any, error) {
+	plan, err := db.Plan(preds...)
+	if err != nil {
+		return nil, err
+	}
+	ordered := plan.orderBy(colIndex, last)
+	var acc accumulator
+	err = plan.Each(func(row []any) (bool, error) {
+		if filter != nil && !filter(row) {
+			return true, nil
+		}
+		acc.add(row, colIndex)
+		return !ordered, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if last {
+		return acc.max, nil
+	}
+	return acc.min, nil
+}
+
+type aggGroup struct {
+	values []any
+	accs   []accumulator
+}
+
+// * accumulator folds the values of one column, colIndex -1 only counts rows.
+type accumulator struct {
+	count    int
+	sum      int
+	min, max any
+}
+
+func (a *accumulator) add(row []any, colIndex int) {
+	a.count++
+	if colIndex == -1 {
+		return
+	}
+	val := row[colIndex]
+	if n, ok := val.(int); ok {
+		a.sum += n
+	}
+	if a.min == nil || compareValues(val, a.min) < 0 {
+		a.min = val
+	}
+	if a.max == nil || compareValues(val, a.max) > 0 {
+		a.max = val
+	}
+}
+
+func (a *accumulator) result(f AggFunc) any {
+	if a.count == 0 && f != AGG_COUNT {
+		return nil
+	}
+	switch f {
+	case AGG_COUNT:
+		return a.count
+	case AGG_SUM:
+		return a.sum
+	case AGG_MIN:
+		return a.min
+	case AGG_MAX:
+		return a.max
+	case AGG_AVG:
+		return float64(a.sum) / float64(a.count)
+	}
+	return nil
+}
+
+// * Count returns the number of rows in the table from the maintained row count, without reading the rows.
+func (db *DB) Count() (int, error) {
+	return db.records.Count()
+}
@@ -0,0 +1,414 @@
+package core
+
+import (
+	"BynxDB/core/utils"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+/*
+* Backups. A backup archive holds every tree of one table as it was at a single moment:
+*
+*	| magic | version u16 | page size u32 | table name | tree count u16 | (tree name | page count u64 | pages...) | crc32 |
+*
+* Names are written as | length u16 | bytes | and the crc32 (Castagnoli) covers everything before it. A tree is copied
+* page for page, so the restored file is laid out exactly like the original was.
+*
+* Backup doesn't hold up writers while it copies: it only waits for the write under way to finish, notes the meta page
+* and freelist of every tree and lets writes continue. From then on a page about to be overwritten is saved first and
+* the copy reads the saved page instead of the file.
+ */
+
+const (
+	BACKUP_MAGIC   = "BYNXBACK"
+	BACKUP_VERSION = 1
+)
+
+// * ErrBadBackup is returned by Restore for archives that are damaged or weren't written by Backup.
+var ErrBadBackup = errors.New("[error] invalid backup archive")
+
+// * writeGate lets a backup start between two writes. Writes nest, a batch going through the single row calls or a
+// * cascade coming back to the table, so only the outermost one is waited for. A table has a single writer.
+type writeGate struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	depth   int
+	holding bool
+}
+
+// * beginWrite marks a write under way until the returned function is called with the error it returns. The outermost
+// * write is made then, or rolled back when it returns an error. An error making it is returned in place of a nil one.
+func (db *DB) beginWrite() func(*error) {
+	g := &db.gate
+	g.mu.Lock()
+	if g.cond == nil {
+		g.cond = sync.NewCond(&g.mu)
+	}
+	for g.depth == 0 && g.holding {
+		g.cond.Wait()
+	}
+	if g.depth == 0 {
+		db.journal.begin()
+	}
+	g.depth++
+	g.mu.Unlock()
+	return func(err *error) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.depth == 1 {
+			if endErr := db.journal.end(*err); endErr != nil && *err == nil {
+				*err = endErr
+			}
+		}
+		g.depth--
+		if g.depth == 0 {
+			g.cond.Broadcast()
+		}
+	}
+}
+
+// * betweenWrites runs fn when no write is under way. Writes starting meanwhile wait for it.
+func (db *DB) betweenWrites(fn func() error) error {
+	g := &db.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cond == nil {
+		g.cond = sync.NewCond(&g.mu)
+	}
+	g.holding = true
+	for g.depth > 0 {
+		g.cond.Wait()
+	}
+	err := fn()
+	g.holding = false
+	g.cond.Broadcast()
+	return err
+}
+
+// * pageSnapshot is the state of a file when a backup started. saved holds the pages overwritten since.
+type pageSnapshot struct {
+	meta Meta
+	// * freeList holds the pages of the freelist chain
+	freeList map[pgNum]*page
+	pages    pgNum
+	saved    map[pgNum][]byte
+}
+
+// * startSnapshot notes the current state of the file for a backup.
+func (d *DAL) startSnapshot() error {
+	d.snapMu.Lock()
+	defer d.snapMu.Unlock()
+	if d.snapshot != nil {
+		return errors.New("[error] a backup is already running")
+	}
+	d.snapshot = &pageSnapshot{
+		meta:     *d.Meta,
+		freeList: map[pgNum]*page{},
+		pages:    d.maxPage,
+		saved:    map[pgNum][]byte{},
+	}
+	for _, p := range d.freelistPages(d.freeList, d.freelistPage) {
+		d.snapshot.freeList[p.Num] = p
+	}
+	return nil
+}
+
+func (d *DAL) endSnapshot() {
+	d.snapMu.Lock()
+	defer d.snapMu.Unlock()
+	d.snapshot = nil
+}
+
+// * savePage keeps the snapshot's copy of a page about to be overwritten. Called with snapMu held.
+func (d *DAL) savePage(pageNum pgNum) error {
+	s := d.snapshot
+	if s == nil || pageNum >= s.pages {
+		return nil
+	}
+	if _, ok := s.saved[pageNum]; ok {
+		return nil
+	}
+	data, err := d.readSnapshotPage(pageNum)
+	if err != nil {
+		return err
+	}
+	s.saved[pageNum] = data
+	return nil
+}
+
+// * snapshotPage returns a page as it was when the backup started. The meta page and the freelist chain are written
+// * from the state noted then, as the copies on disk may lag behind it.
+func (d *DAL) snapshotPage(pageNum pgNum) ([]byte, error) {
+	d.snapMu.Lock()
+	defer d.snapMu.Unlock()
+	s := d.snapshot
+	if pageNum == metaPageNum {
+		return encodePage(d.metaPage(&s.meta), d.pageSize)
+	}
+	if p, ok := s.freeList[pageNum]; ok {
+		return encodePage(p, d.pageSize)
+	}
+	if data, ok := s.saved[pageNum]; ok {
+		return data, nil
+	}
+	return d.readSnapshotPage(pageNum)
+}
+
+// * readSnapshotPage reads a page from the file, a page handed out but never written reads as zeros.
+func (d *DAL) readSnapshotPage(pageNum pgNum) ([]byte, error) {
+	if d.file == nil {
+		return nil, errors.New("[error] table closed during backup")
+	}
+	data := make([]byte, d.pageSize)
+	if _, err := d.file.ReadAt(data, int64(pageNum)*int64(d.pageSize)); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// * snapshotRunning reports whether a backup is copying the file, which then can't be cut or replaced.
+func (d *DAL) snapshotRunning() bool {
+	d.snapMu.Lock()
+	defer d.snapMu.Unlock()
+	return d.snapshot != nil
+}
+
+// * checkNoBackup refuses to cut or replace the files of the table while a backup copies them.
+func (db *DB) checkNoBackup(op string) error {
+	for _, c := range db.collections() {
+		if c.DAL.snapshotRunning() {
+			return errors.New("[error] can't " + op + " " + db.name() + " while a backup is running")
+		}
+	}
+	return nil
+}
+
+// * Backup writes an archive of every tree of the table, as they were when it was called, to w.
+func (db *DB) Backup(w io.Writer) error {
+	utils.Info(1, "==Backup Call==", db.name())
+	collections := db.collections()
+	// * The archive has a single page size, files of a table created on different machines may not share one
+	for _, c := range collections {
+		if c.DAL.pageSize != db.records.DAL.pageSize {
+			return fmt.Errorf("[error] can't back up %s, %s has a page size of %d and the records %d", db.name(), c.Name,
+				c.DAL.pageSize, db.records.DAL.pageSize)
+		}
+	}
+	err := db.betweenWrites(func() error {
+		for i, c := range collections {
+			if err := c.DAL.startSnapshot(); err != nil {
+				for _, started := range collections[:i] {
+					started.DAL.endSnapshot()
+				}
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, c := range collections {
+			c.DAL.endSnapshot()
+		}
+	}()
+
+	hash := crc32.New(crcTable)
+	bw := bufio.NewWriter(io.MultiWriter(w, hash))
+	bw.WriteString(BACKUP_MAGIC)
+	binary.Write(bw, binary.LittleEndian, uint16(BACKUP_VERSION))
+	binary.Write(bw, binary.LittleEndian, uint32(db.records.DAL.pageSize))
+	writeName(bw, db.name())
+	binary.Write(bw, binary.LittleEndian, uint16(len(collections)))
+	for _, c := range collections {
+		pages := c.DAL.snapshot.pages
+		writeName(bw, string(c.Name))
+		binary.Write(bw, binary.LittleEndian, uint64(pages))
+		for pg := pgNum(0); pg < pages; pg++ {
+			data, err := c.DAL.snapshotPage(pg)
+			if err != nil {
+				return err
+			}
+			if _, err := bw.Write(data); err != nil {
+				return err
+			}
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, hash.Sum32())
+}
+
+func writeName(w *bufio.Writer, name string) {
+	binary.Write(w, binary.LittleEndian, uint16(len(name)))
+	w.WriteString(name)
+}
+
+/*
+* Restore writes the table held by a Backup archive into dir, which is DataDir for the tables BynxDB opens, and returns
+* its name. The archive is checked as it is read: its header, the layout of every tree's meta page and the checksum
+* at the end. The files are only moved into place once all of it checked out, and a table already in dir is never
+* overwritten.
+ */
+func Restore(r io.Reader, dir string) (string, error) {
+	return RestoreWithOptions(r, dir, nil)
+}
+
+// * RestoreWithOptions is Restore writing the files to the storage of opts.
+func RestoreWithOptions(r io.Reader, dir string, opts *Options) (string, error) {
+	utils.Info(1, "==Restore Call==", dir)
+	storage := opts.withDefaults().storage()
+	hash := crc32.New(crcTable)
+	br := io.TeeReader(bufio.NewReader(r), hash)
+	bad := func(reason string) error { return fmt.Errorf("%w: %s", ErrBadBackup, reason) }
+
+	magic := make([]byte, len(BACKUP_MAGIC))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != BACKUP_MAGIC {
+		return "", bad("not a backup")
+	}
+	var version uint16
+	var pageSize uint32
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil || version != BACKUP_VERSION {
+		return "", bad(fmt.Sprint("unsupported version ", version))
+	}
+	if err := binary.Read(br, binary.LittleEndian, &pageSize); err != nil || !validPageSize(int(pageSize)) {
+		return "", bad(fmt.Sprint("unsupported page size ", pageSize))
+	}
+	name, err := readName(br)
+	if err != nil || name == "" {
+		return "", bad("missing table name")
+	}
+	var count uint16
+	if err := binary.Read(br, binary.LittleEndian, &count); err != nil || count == 0 {
+		return "", bad("no trees")
+	}
+
+	var tmpPaths, paths []string
+	cleanup := func() {
+		for _, path := range tmpPaths {
+			storage.Remove(path)
+		}
+	}
+	for i := 0; i < int(count); i++ {
+		treeName, err := readName(br)
+		if err != nil || !strings.HasPrefix(treeName, name) || (i == 0) != (treeName == name+"rec") ||
+			strings.ContainsAny(treeName, `/\`) {
+			cleanup()
+			return "", bad("unexpected tree " + treeName)
+		}
+		path := filepath.Join(dir, treeName+".db")
+		if exists, err := storage.Exists(path); err != nil || exists {
+			cleanup()
+			if err != nil {
+				return "", err
+			}
+			return "", errors.New("[error] can't restore over existing table " + name + ": " + path)
+		}
+		tmpPath := path + ".restore"
+		tmpPaths, paths = append(tmpPaths, tmpPath), append(paths, path)
+		if err := restoreTree(br, storage, tmpPath, int(pageSize)); err != nil {
+			cleanup()
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return "", bad("archive cut short")
+			}
+			return "", err
+		}
+	}
+	sum := hash.Sum32()
+	var stored uint32
+	if err := binary.Read(br, binary.LittleEndian, &stored); err != nil || stored != sum {
+		cleanup()
+		return "", bad("checksum mismatch")
+	}
+	// * A journal left behind by a table of the name removed since would roll the restored files back
+	journal := journalPath(dir, name)
+	if exists, err := storage.Exists(journal); err != nil || exists {
+		if err == nil {
+			err = storage.Remove(journal)
+		}
+		if err != nil {
+			cleanup()
+			return "", err
+		}
+	}
+	for i := range paths {
+		if err := storage.Rename(tmpPaths[i], paths[i]); err != nil {
+			cleanup()
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// * restoreTree copies the pages of one tree to path in storage, checking the header of every page written and that the
+// * meta page points inside the tree.
+func restoreTree(r io.Reader, storage Storage, path string, pageSize int) error {
+	var pages uint64
+	if err := binary.Read(r, binary.LittleEndian, &pages); err != nil {
+		return err
+	}
+	file, err := storage.Open(path, false)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	data := make([]byte, pageSize)
+	for pg := uint64(0); pg < pages; pg++ {
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		// * Pages handed out but never written are left as zeros
+		p, err := decodePage(pgNum(pg), data)
+		if err != nil && !bytes.Equal(data, make([]byte, pageSize)) {
+			return fmt.Errorf("%w: %s: %v", ErrBadBackup, filepath.Base(path), err)
+		}
+		if pg == metaPageNum {
+			if err != nil || p.Type != PAGE_META {
+				return fmt.Errorf("%w: %s has no meta page", ErrBadBackup, filepath.Base(path))
+			}
+			header := &fileHeader{}
+			if err := header.deserialize(p.Data); err != nil || header.check() != nil || header.pageSize != pageSize {
+				return fmt.Errorf("%w: %s has an invalid file header", ErrBadBackup, filepath.Base(path))
+			}
+			meta := newMetaPage()
+			meta.Deserialize(p.Data[fileHeaderSize:])
+			for _, p := range []pgNum{meta.Root, meta.freelistPage, meta.TableDefPage} {
+				if p == metaPageNum || uint64(p) >= pages {
+					return fmt.Errorf("%w: meta page of %s points at page %d of %d", ErrBadBackup, filepath.Base(path), p, pages)
+				}
+			}
+		}
+		if _, err := file.WriteAt(data, int64(pg)*int64(pageSize)); err != nil {
+			return err
+		}
+	}
+	if pages == 0 {
+		return fmt.Errorf("%w: empty tree %s", ErrBadBackup, filepath.Base(path))
+	}
+	return file.Sync()
+}
+
+func readName(r io.Reader) (string, error) {
+	var n uint16
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	name := make([]byte, n)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return "", err
+	}
+	return string(name), nil
+}
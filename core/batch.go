@@ -0,0 +1,139 @@
+package core
+
+import (
+	"BynxDB/core/utils"
+	"fmt"
+)
+
+// * BatchError is returned when rows of a batch fail validation. RowErrors is indexed like the input rows and holds
+// * nil for the rows that were valid.
+type BatchError struct {
+	RowErrors []error
+}
+
+func (e *BatchError) Error() string {
+	failed := 0
+	for _, err := range e.RowErrors {
+		if err != nil {
+			failed++
+		}
+	}
+	return fmt.Sprintf("[error] batch rejected: %d of %d rows invalid", failed, len(e.RowErrors))
+}
+
+type encodedRow struct {
+	pKey      []byte
+	value     []byte
+	indexKeys [][]byte
+}
+
+// * InsertBatch inserts many rows with a single commit. All rows are validated first: types, check constraints,
+// * duplicate primary keys and unique values within the batch, conflicts with rows already in the table and foreign
+// * keys. If any row is invalid nothing is inserted and a *BatchError carrying the per-row errors is returned.
+// * Otherwise the rows are applied with the meta page and freelist of every tree written once at the end instead of
+// * on every root split.
+func (db *DB) InsertBatch(rows [][]any) (err error) {
+	utils.Info(2, "==InsertBatch Call==", len(rows))
+	if err := db.checkWritable(); err != nil {
+		return err
+	}
+	defer db.beginWrite()(&err)
+	tD := db.records.TableDef
+	encoded := make([]encodedRow, len(rows))
+	rowErrors := make([]error, len(rows))
+	failed := false
+	reject := func(i int, err error) {
+		rowErrors[i] = err
+		failed = true
+	}
+
+	seenPKeys := map[string]int{}
+	seenIndexKeys := make([]map[string]int, len(tD.UniqueCols))
+	for i := range seenIndexKeys {
+		seenIndexKeys[i] = map[string]int{}
+	}
+rowsLoop:
+	for i, row := range rows {
+		pKey, value, indexKeys, err := db.encodeRow(row)
+		if err == nil {
+			err = db.checkConstraints(row)
+		}
+		if err != nil {
+			reject(i, err)
+			continue
+		}
+		if first, ok := seenPKeys[string(pKey)]; ok {
+			reject(i, fmt.Errorf("[error] duplicate primary key in batch, first seen in row %d", first))
+			continue
+		}
+		it, err := db.records.Find(pKey)
+		if err != nil {
+			return err
+		}
+		if it != nil {
+			reject(i, fmt.Errorf("[error] this key already excists in the key-value store"))
+			continue
+		}
+		for j, indexKey := range indexKeys {
+			col := tD.Cols[tD.UniqueCols[j]]
+			if first, ok := seenIndexKeys[j][string(indexKey)]; ok {
+				reject(i, fmt.Errorf("[error] duplicate value in unique column %s, first seen in row %d", col, first))
+				continue rowsLoop
+			}
+			it, err := db.uniqueColumnsTree[j].Find(indexKey)
+			if err != nil {
+				return err
+			}
+			if it != nil {
+				reject(i, fmt.Errorf("[error] value already exists in unique column: %s", col))
+				continue rowsLoop
+			}
+		}
+		seenPKeys[string(pKey)] = i
+		for j, indexKey := range indexKeys {
+			seenIndexKeys[j][string(indexKey)] = i
+		}
+		encoded[i] = encodedRow{pKey: pKey, value: value, indexKeys: indexKeys}
+	}
+	// * Foreign keys are checked once every primary key of the batch is known, rows may reference each other
+	inBatch := func(key []byte, _ any) bool {
+		_, ok := seenPKeys[string(key)]
+		return ok
+	}
+	for i, row := range rows {
+		if rowErrors[i] != nil {
+			continue
+		}
+		if err := db.checkForeignKeys(row, inBatch); err != nil {
+			reject(i, err)
+		}
+	}
+	if failed {
+		return &BatchError{RowErrors: rowErrors}
+	}
+
+	collections := db.collections()
+	for _, c := range collections {
+		c.DAL.batching = true
+	}
+	var applyErr error
+	for _, row := range encoded {
+		for j, indexKey := range row.indexKeys {
+			if applyErr = db.uniqueColumnsTree[j].Put(indexKey, row.pKey, false); applyErr != nil {
+				break
+			}
+		}
+		if applyErr != nil {
+			break
+		}
+		if applyErr = db.records.Put(row.pKey, row.value, false); applyErr != nil {
+			break
+		}
+	}
+	for _, c := range collections {
+		if err := c.DAL.commit(); err != nil && applyErr == nil {
+			applyErr = err
+		}
+	}
+	return applyErr
+}
@@ -0,0 +1,503 @@
+package core
+
+import (
+	"BynxDB/core/utils"
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sort"
+)
+
+type BulkLoadOptions struct {
+	// * FillFactor is the fraction of the maximum node size every node is packed to.
+	FillFactor float32
+	// * MaxRowsInMemory is the number of rows sorted in memory before a sorted run is spilled to a temporary file.
+	MaxRowsInMemory int
+	// * TempDir holds the spilled runs. Empty means os.TempDir().
+	TempDir string
+}
+
+var DefaultBulkLoadOptions = &BulkLoadOptions{
+	FillFactor:      0.9,
+	MaxRowsInMemory: 100000,
+}
+
+// * BulkLoad fills an empty table from rows. Instead of inserting row by row, the input is sorted (spilling sorted
+// * runs to disk once MaxRowsInMemory is exceeded) and every tree is built bottom-up: leaves are packed to the fill
+// * factor and each interior level is built from the separators of the level below. The records tree and every
+// * unique index are only switched in once all of them were built, so a duplicate key leaves the table empty.
+// * Returns the number of rows loaded.
+func (db *DB) BulkLoad(rows RowIterator, opts *BulkLoadOptions) (n int, err error) {
+	utils.Info(1, "==BulkLoad Call==")
+	if err := db.checkWritable(); err != nil {
+		return 0, err
+	}
+	defer db.beginWrite()(&err)
+	if opts == nil {
+		opts = DefaultBulkLoadOptions
+	}
+	if opts.FillFactor <= 0 || opts.FillFactor > 1 {
+		return 0, errors.New("[error] fill factor has to be in (0, 1]")
+	}
+	collections := db.collections()
+	for _, c := range collections {
+		root, err := c.DAL.Getnode(c.DAL.Root)
+		if err != nil {
+			return 0, err
+		}
+		if len(root.Items) != 0 {
+			return 0, errors.New("[error] bulk load needs an empty table: " + string(c.Name))
+		}
+	}
+
+	sorters := make([]*itemSorter, len(collections))
+	for i := range sorters {
+		sorters[i] = &itemSorter{limit: opts.MaxRowsInMemory, dir: opts.TempDir}
+		defer sorters[i].close()
+	}
+
+	// * The table is empty, so a self referencing row can only point at rows of the input. Those references are
+	// * collected and checked against the loaded primary keys at the end.
+	loaded, wanted := map[string]bool{}, map[string]any{}
+	selfRef := func(key []byte, val any) bool {
+		wanted[string(key)] = val
+		return true
+	}
+	selfReferencing := false
+	for _, fk := range db.records.ForeignKeys {
+		selfReferencing = selfReferencing || fk.RefTable == db.name()
+	}
+
+	count := 0
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		pKey, value, indexKeys, err := db.encodeRow(row)
+		if err != nil {
+			return 0, err
+		}
+		if err := db.checkConstraints(row); err != nil {
+			return 0, err
+		}
+		if err := db.checkForeignKeys(row, selfRef); err != nil {
+			return 0, err
+		}
+		if selfReferencing {
+			loaded[string(pKey)] = true
+		}
+		if err := sorters[0].add(ItemCreate(pKey, value)); err != nil {
+			return 0, err
+		}
+		for i, indexKey := range indexKeys {
+			if err := sorters[i+1].add(ItemCreate(indexKey, pKey)); err != nil {
+				return 0, err
+			}
+		}
+		count++
+	}
+
+	for key, val := range wanted {
+		if !loaded[key] {
+			return 0, errors.New("[error] foreign key violation: " + formatValue(val) + " has no row in " + db.name())
+		}
+	}
+
+	roots := make([]pgNum, len(collections))
+	var built [][]pgNum
+	// * Give back the pages of every tree built so far if a later one fails.
+	abort := func() {
+		for i, pages := range built {
+			for _, pg := range pages {
+				collections[i].DAL.ReleasedPage(pg)
+			}
+		}
+	}
+	for i, c := range collections {
+		stream, err := sorters[i].finish()
+		if err != nil {
+			abort()
+			return 0, err
+		}
+		root, pages, err := buildTree(c.DAL, stream, opts.FillFactor*c.DAL.maxThreshold())
+		built = append(built, pages)
+		if err != nil {
+			abort()
+			if errors.Is(err, errDuplicateKey) {
+				if i == 0 {
+					return 0, errors.New("[error] duplicate primary key in bulk load input")
+				}
+				return 0, errors.New("[error] duplicate value in unique column: " + db.records.Cols[db.records.UniqueCols[i-1]])
+			}
+			return 0, err
+		}
+		roots[i] = root
+	}
+
+	for i, c := range collections {
+		c.DAL.Deletenode(c.DAL.Root)
+		c.DAL.Root = roots[i]
+		c.DAL.RowCount, c.DAL.rowCountKnown = uint64(count), true
+		if err := c.DAL.commit(); err != nil {
+			return 0, err
+		}
+	}
+	utils.Info(1, "Bulk loaded rows: ", count)
+	return count, nil
+}
+
+var errDuplicateKey = errors.New("[error] duplicate key")
+
+// * buildTree builds a B-tree bottom-up from items sorted by key. Returns the root page and every page written.
+func buildTree(dal *DAL, stream itemStream, target float32) (pgNum, []pgNum, error) {
+	var written []pgNum
+	level := newLevelBuilder(dal, target, true, 0)
+	var lastKey []byte
+	for {
+		item, err := stream.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, append(written, level.pages...), err
+		}
+		if lastKey != nil && bytes.Compare(lastKey, item.Key) >= 0 {
+			return 0, append(written, level.pages...), errDuplicateKey
+		}
+		lastKey = item.Key
+		if err := level.add(item, 0); err != nil {
+			return 0, append(written, level.pages...), err
+		}
+	}
+	err := level.finish()
+	written = append(written, level.pages...)
+	if err != nil {
+		return 0, written, err
+	}
+	// * Every level is built from the nodes and separators of the level below until a single node, the root, is left.
+	for len(level.pages) > 1 {
+		below := level
+		level = newLevelBuilder(dal, target, false, below.pages[0])
+		for i, sep := range below.seps {
+			if err := level.add(sep, below.pages[i+1]); err != nil {
+				return 0, append(written, level.pages...), err
+			}
+		}
+		err := level.finish()
+		written = append(written, level.pages...)
+		if err != nil {
+			return 0, written, err
+		}
+	}
+	return level.pages[0], written, nil
+}
+
+// * levelBuilder packs a sorted stream of items into the nodes of one tree level. For interior levels every item comes
+// * with the child to its right. When a node is full, the next item becomes the separator promoted to the level above.
+// * The node before the current one is held back so the last node of the level can share items with it.
+type levelBuilder struct {
+	dal    *DAL
+	target float32
+	leaf   bool
+
+	cur     *Node
+	prev    *Node
+	prevSep *Item
+
+	// * Written nodes of the level and the separators between them, len(seps) == len(pages)-1.
+	pages []pgNum
+	seps  []*Item
+}
+
+func newLevelBuilder(dal *DAL, target float32, leaf bool, firstChild pgNum) *levelBuilder {
+	lb := &levelBuilder{dal: dal, target: target, leaf: leaf}
+	lb.cur = lb.newNode()
+	if !leaf {
+		lb.cur.Childnodes = append(lb.cur.Childnodes, firstChild)
+	}
+	return lb
+}
+
+func (lb *levelBuilder) newNode() *Node {
+	node := NodeCreate()
+	node.DAL = lb.dal
+	node.Items = []*Item{}
+	node.Childnodes = []pgNum{}
+	return node
+}
+
+func itemSize(item *Item) int {
+	return len(item.Key) + len(item.Value) + pageNumSize
+}
+
+func (lb *levelBuilder) add(item *Item, child pgNum) error {
+	// * A node is closed once the item doesn't fit the fill target anymore, but never before it reaches the minimum fill.
+	size := float32(lb.cur.nodeSize() + itemSize(item))
+	full := size > lb.dal.maxThreshold() || (size > lb.target && !lb.cur.isUnderPopulated())
+	if len(lb.cur.Items) > 0 && full {
+		if lb.prev != nil {
+			if err := lb.write(lb.prev); err != nil {
+				return err
+			}
+			lb.seps = append(lb.seps, lb.prevSep)
+		}
+		lb.prev, lb.prevSep = lb.cur, item
+		lb.cur = lb.newNode()
+		if !lb.leaf {
+			lb.cur.Childnodes = append(lb.cur.Childnodes, child)
+		}
+		return nil
+	}
+	lb.cur.Items = append(lb.cur.Items, item)
+	if !lb.leaf {
+		lb.cur.Childnodes = append(lb.cur.Childnodes, child)
+	}
+	return nil
+}
+
+func (lb *levelBuilder) write(node *Node) error {
+	node.Pagenum = lb.dal.GetNextPage()
+	if _, err := lb.dal.Writenode(node); err != nil {
+		lb.dal.ReleasedPage(node.Pagenum)
+		return err
+	}
+	lb.pages = append(lb.pages, node.Pagenum)
+	return nil
+}
+
+func (lb *levelBuilder) finish() error {
+	if lb.prev != nil && lb.cur.isUnderPopulated() {
+		lb.rebalanceLast()
+	}
+	if lb.prev != nil {
+		if err := lb.write(lb.prev); err != nil {
+			return err
+		}
+		lb.seps = append(lb.seps, lb.prevSep)
+	}
+	return lb.write(lb.cur)
+}
+
+// * rebalanceLast evens out the last node of the level, which holds whatever was left over, with its left sibling.
+// * If both fit into one node they are merged, otherwise the items are split in half by size.
+func (lb *levelBuilder) rebalanceLast() {
+	items := make([]*Item, 0, len(lb.prev.Items)+len(lb.cur.Items)+1)
+	items = append(items, lb.prev.Items...)
+	items = append(items, lb.prevSep)
+	items = append(items, lb.cur.Items...)
+	children := make([]pgNum, 0, len(lb.prev.Childnodes)+len(lb.cur.Childnodes))
+	children = append(children, lb.prev.Childnodes...)
+	children = append(children, lb.cur.Childnodes...)
+
+	total := nodeHeaderSize + pageNumSize
+	for _, item := range items {
+		total += itemSize(item)
+	}
+	if float32(total) <= lb.dal.maxThreshold() {
+		lb.cur.Items, lb.cur.Childnodes = items, children
+		lb.prev, lb.prevSep = nil, nil
+		return
+	}
+
+	// * The item at mid becomes the separator, both halves keep at least one item.
+	size := nodeHeaderSize + pageNumSize
+	mid := len(items) - 2
+	for i := 1; i < len(items)-2; i++ {
+		size += itemSize(items[i-1])
+		if size+itemSize(items[i]) > total/2 {
+			mid = i
+			break
+		}
+	}
+	lb.prev.Items = items[:mid:mid]
+	lb.prevSep = items[mid]
+	lb.cur.Items = items[mid+1:]
+	if !lb.leaf {
+		lb.prev.Childnodes = children[: mid+1 : mid+1]
+		lb.cur.Childnodes = children[mid+1:]
+	}
+}
+
+// * External sort
+
+type itemStream interface {
+	next() (*Item, error)
+}
+
+// * itemSorter sorts items by key. Items are buffered in memory and spilled as sorted runs to temporary files once
+// * the buffer is full; the runs are merged when the sorted stream is read back.
+type itemSorter struct {
+	limit int
+	dir   string
+	buf   []*Item
+	runs  []*os.File
+}
+
+func (s *itemSorter) add(item *Item) error {
+	s.buf = append(s.buf, item)
+	if s.limit > 0 && len(s.buf) >= s.limit {
+		return s.spill()
+	}
+	return nil
+}
+
+func (s *itemSorter) sortBuf() {
+	sort.Slice(s.buf, func(i, j int) bool {
+		return bytes.Compare(s.buf[i].Key, s.buf[j].Key) < 0
+	})
+}
+
+func (s *itemSorter) spill() error {
+	s.sortBuf()
+	f, err := os.CreateTemp(s.dir, "bynx-bulk-*.run")
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, f)
+	w := bufio.NewWriter(f)
+	for _, item := range s.buf {
+		if err := writeRunItem(w, item); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	utils.Info(2, "Spilled sorted run: ", f.Name(), len(s.buf))
+	s.buf = s.buf[:0]
+	return nil
+}
+
+func (s *itemSorter) finish() (itemStream, error) {
+	if len(s.runs) == 0 {
+		s.sortBuf()
+		return &sliceStream{items: s.buf}, nil
+	}
+	if len(s.buf) != 0 {
+		if err := s.spill(); err != nil {
+			return nil, err
+		}
+	}
+	merge := &mergeStream{}
+	for _, f := range s.runs {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		r := bufio.NewReader(f)
+		item, err := readRunItem(r)
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		merge.runs = append(merge.runs, &runReader{r: r, head: item})
+	}
+	heap.Init(merge)
+	return merge, nil
+}
+
+func (s *itemSorter) close() {
+	for _, f := range s.runs {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	s.runs = nil
+}
+
+/*
+*	Sorted run record:  | key size (2) | key | value size (2) | value |
+ */
+func writeRunItem(w *bufio.Writer, item *Item) error {
+	var size [2]byte
+	binary.LittleEndian.PutUint16(size[:], uint16(len(item.Key)))
+	w.Write(size[:])
+	w.Write(item.Key)
+	binary.LittleEndian.PutUint16(size[:], uint16(len(item.Value)))
+	w.Write(size[:])
+	_, err := w.Write(item.Value)
+	return err
+}
+
+func readRunItem(r *bufio.Reader) (*Item, error) {
+	readField := func() ([]byte, error) {
+		var size [2]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil, err
+		}
+		field := make([]byte, binary.LittleEndian.Uint16(size[:]))
+		_, err := io.ReadFull(r, field)
+		return field, err
+	}
+	key, err := readField()
+	if err != nil {
+		return nil, err
+	}
+	value, err := readField()
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return ItemCreate(key, value), nil
+}
+
+type sliceStream struct {
+	items []*Item
+	pos   int
+}
+
+func (s *sliceStream) next() (*Item, error) {
+	if s.pos >= len(s.items) {
+		return nil, io.EOF
+	}
+	s.pos++
+	return s.items[s.pos-1], nil
+}
+
+type runReader struct {
+	r    *bufio.Reader
+	head *Item
+}
+
+// * mergeStream is a k-way merge of sorted runs, kept as a min heap on the head item of every run.
+type mergeStream struct {
+	runs []*runReader
+}
+
+func (m *mergeStream) Len() int { return len(m.runs) }
+func (m *mergeStream) Less(i, j int) bool {
+	return bytes.Compare(m.runs[i].head.Key, m.runs[j].head.Key) < 0
+}
+func (m *mergeStream) Swap(i, j int) { m.runs[i], m.runs[j] = m.runs[j], m.runs[i] }
+func (m *mergeStream) Push(x any)    { m.runs = append(m.runs, x.(*runReader)) }
+func (m *mergeStream) Pop() any {
+	last := m.runs[len(m.runs)-1]
+	m.runs = m.runs[:len(m.runs)-1]
+	return last
+}
+
+func (m *mergeStream) next() (*Item, error) {
+	if len(m.runs) == 0 {
+		return nil, io.EOF
+	}
+	run := m.runs[0]
+	item := run.head
+	head, err := readRunItem(run.r)
+	switch {
+	case err == io.EOF:
+		heap.Pop(m)
+	case err != nil:
+		return nil, err
+	default:
+		run.head = head
+		heap.Fix(m, 0)
+	}
+	return item, nil
+}
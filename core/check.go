@@ -0,0 +1,230 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+* CHECK constraints and default values. Both are declared per column by name, so they survive the primary key being
+* moved to index 0, and are stored with the table definition. A check is one of:
+*
+*	CHECK_RANGE       Low <= value <= High           TYPE_INT64
+*	CHECK_MIN         Low <= value                   TYPE_INT64
+*	CHECK_MAX         value <= High                  TYPE_INT64
+*	CHECK_GREATER     Low < value                    TYPE_INT64
+*	CHECK_LESS        value < High                   TYPE_INT64
+*	CHECK_NOT_EMPTY   len(value) > 0                 TYPE_BYTE
+*	CHECK_MAX_LENGTH  len(value) <= High             TYPE_BYTE
+*	CHECK_REGEX       value matches Pattern          TYPE_BYTE
+*
+* Defaults fill the columns left out of a named row (InsertRow, an INSERT listing its columns).
+ */
+
+type CheckKind uint16
+
+const (
+	CHECK_RANGE CheckKind = iota
+	CHECK_MIN
+	CHECK_MAX
+	CHECK_NOT_EMPTY
+	CHECK_MAX_LENGTH
+	CHECK_REGEX
+	CHECK_GREATER
+	CHECK_LESS
+)
+
+type Check struct {
+	Col     string
+	Kind    CheckKind
+	Low     int
+	High    int
+	Pattern string
+}
+
+func CheckRange(col string, low, high int) Check {
+	return Check{Col: col, Kind: CHECK_RANGE, Low: low, High: high}
+}
+
+func CheckMin(col string, low int) Check {
+	return Check{Col: col, Kind: CHECK_MIN, Low: low}
+}
+
+func CheckMax(col string, high int) Check {
+	return Check{Col: col, Kind: CHECK_MAX, High: high}
+}
+
+func CheckGreater(col string, low int) Check {
+	return Check{Col: col, Kind: CHECK_GREATER, Low: low}
+}
+
+func CheckLess(col string, high int) Check {
+	return Check{Col: col, Kind: CHECK_LESS, High: high}
+}
+
+func CheckNotEmpty(col string) Check {
+	return Check{Col: col, Kind: CHECK_NOT_EMPTY}
+}
+
+func CheckMaxLength(col string, length int) Check {
+	return Check{Col: col, Kind: CHECK_MAX_LENGTH, High: length}
+}
+
+func CheckRegex(col string, pattern string) Check {
+	return Check{Col: col, Kind: CHECK_REGEX, Pattern: pattern}
+}
+
+// * String is the condition in SQL: "AGE BETWEEN 0 AND 150", "LENGTH(NAME) <= 20"...
+func (c Check) String() string {
+	switch c.Kind {
+	case CHECK_RANGE:
+		return c.Col + " BETWEEN " + strconv.Itoa(c.Low) + " AND " + strconv.Itoa(c.High)
+	case CHECK_MIN:
+		return c.Col + " >= " + strconv.Itoa(c.Low)
+	case CHECK_MAX:
+		return c.Col + " <= " + strconv.Itoa(c.High)
+	case CHECK_GREATER:
+		return c.Col + " > " + strconv.Itoa(c.Low)
+	case CHECK_LESS:
+		return c.Col + " < " + strconv.Itoa(c.High)
+	case CHECK_NOT_EMPTY:
+		return c.Col + " <> ''"
+	case CHECK_MAX_LENGTH:
+		return "LENGTH(" + c.Col + ") <= " + strconv.Itoa(c.High)
+	case CHECK_REGEX:
+		return c.Col + " REGEXP '" + c.Pattern + "'"
+	}
+	return "UNKNOWN"
+}
+
+// * ConstraintError is returned when a value breaks a CHECK constraint of its column.
+type ConstraintError struct {
+	Col   string
+	Check Check
+	Value any
+}
+
+func (e *ConstraintError) Error() string {
+	return "[error] check constraint violation on " + e.Col + ": " + formatValue(e.Value) + " fails CHECK (" + e.Check.String() + ")"
+}
+
+// * checkConstraintDefs validates the checks and defaults of a table about to be created and normalizes their names.
+// * The definition they end up in has to fit a page of pageSize.
+func checkConstraintDefs(tD *TableDef, pageSize int) error {
+	for i := range tD.Checks {
+		c := &tD.Checks[i]
+		c.Col = strings.ToUpper(c.Col)
+		colIndex, err := tD.ColIndex(c.Col)
+		if err != nil {
+			return err
+		}
+		want := uint16(TYPE_BYTE)
+		switch c.Kind {
+		case CHECK_RANGE, CHECK_MIN, CHECK_MAX, CHECK_GREATER, CHECK_LESS:
+			want = TYPE_INT64
+		case CHECK_NOT_EMPTY, CHECK_MAX_LENGTH:
+		case CHECK_REGEX:
+			if _, err := regexp.Compile(c.Pattern); err != nil {
+				return errors.New("[error] invalid pattern in check on " + c.Col + ": " + err.Error())
+			}
+		default:
+			return errors.New("[error] invalid check on column: " + c.Col)
+		}
+		if tD.Types[colIndex] != want {
+			return errors.New("[error] CHECK (" + c.String() + ") doesn't fit the type of column: " + c.Col)
+		}
+		if (c.Kind == CHECK_RANGE && c.Low > c.High) || (c.Kind == CHECK_MAX_LENGTH && c.High < 0) ||
+			(c.Kind == CHECK_GREATER && c.Low == math.MaxInt) || (c.Kind == CHECK_LESS && c.High == math.MinInt) {
+			return errors.New("[error] CHECK (" + c.String() + ") can never hold")
+		}
+	}
+	defaults := map[string]any{}
+	for col, val := range tD.Defaults {
+		col = strings.ToUpper(col)
+		colIndex, err := tD.ColIndex(col)
+		if err != nil {
+			return err
+		}
+		if colIndex == tD.PKeyIndex {
+			return errors.New("[error] the primary key can't have a default: " + col)
+		}
+		if err := checkType(tD, colIndex, val); err != nil {
+			return err
+		}
+		defaults[col] = val
+	}
+	if len(tD.Defaults) != 0 {
+		tD.Defaults = defaults
+	}
+	// * A default has to pass the checks of its column itself
+	for _, c := range tD.Checks {
+		if val, ok := tD.Defaults[c.Col]; ok && !c.holds(val, nil) {
+			return &ConstraintError{Col: c.Col, Check: c, Value: val}
+		}
+	}
+	return tD.checkFits(pageSize)
+}
+
+// * compileChecks compiles the patterns of the regex checks, indexed like tD.Checks.
+func compileChecks(tD *TableDef) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, len(tD.Checks))
+	for i, c := range tD.Checks {
+		if c.Kind != CHECK_REGEX {
+			continue
+		}
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		patterns[i] = re
+	}
+	return patterns, nil
+}
+
+// * holds reports whether val meets the check. re is the compiled pattern of a regex check, nil compiles it.
+func (c Check) holds(val any, re *regexp.Regexp) bool {
+	switch data := val.(type) {
+	case int:
+		switch c.Kind {
+		case CHECK_RANGE:
+			return c.Low <= data && data <= c.High
+		case CHECK_MIN:
+			return c.Low <= data
+		case CHECK_MAX:
+			return data <= c.High
+		case CHECK_GREATER:
+			return c.Low < data
+		case CHECK_LESS:
+			return data < c.High
+		}
+	case []byte:
+		switch c.Kind {
+		case CHECK_NOT_EMPTY:
+			return len(data) > 0
+		case CHECK_MAX_LENGTH:
+			return len(data) <= c.High
+		case CHECK_REGEX:
+			if re == nil {
+				ok, _ := regexp.Match(c.Pattern, data)
+				return ok
+			}
+			return re.Match(data)
+		}
+	}
+	return false
+}
+
+// * checkConstraints checks every value of a positional row against the checks of its column.
+func (db *DB) checkConstraints(row []any) error {
+	tD := db.records.TableDef
+	for i, c := range tD.Checks {
+		colIndex, _ := tD.ColIndex(c.Col)
+		if !c.holds(row[colIndex], db.patterns[i]) {
+			return &ConstraintError{Col: c.Col, Check: c, Value: row[colIndex]}
+		}
+	}
+	return nil
+}
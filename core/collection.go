@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 )
 
 type Collection struct {
@@ -52,74 +53,159 @@ func getProjectRoot() (string, error) {
 	}
 }
 
+// * DATA_DIR_ENV names the environment variable moving the files of the tables out of the db directory of the project.
+const DATA_DIR_ENV = "BYNX_DATA_DIR"
+
+// * DataDir returns the directory the files of the tables are stored in, the one DATA_DIR_ENV names when it is set.
+func DataDir() (string, error) {
+	if dir := os.Getenv(DATA_DIR_ENV); dir != "" {
+		return dir, nil
+	}
+	rootDir, err := getProjectRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(rootDir, "db"), nil
+}
+
+// * collectionPath returns the file a collection is stored in.
+func collectionPath(name []byte) (string, error) {
+	dir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, string(name)+".db"), nil
+}
+
+// * withDefaults fills the fields of opts left zero with the default options.
+func (opts *Options) withDefaults() *Options {
+	merged := *options
+	if opts == nil {
+		return &merged
+	}
+	if opts.PageSize != 0 {
+		merged.PageSize = opts.PageSize
+	}
+	if opts.MinFillPercent != 0 {
+		merged.MinFillPercent = opts.MinFillPercent
+	}
+	if opts.MaxFillPercent != 0 {
+		merged.MaxFillPercent = opts.MaxFillPercent
+	}
+	merged.ReadOnly, merged.LockTimeout, merged.Storage = opts.ReadOnly, opts.LockTimeout, opts.Storage
+	return &merged
+}
+
 func CollectionCreate(name []byte, tD *TableDef) (*Collection, error) {
+	return collectionCreate(name, tD, options)
+}
+
+func collectionCreate(name []byte, tD *TableDef, opts *Options) (*Collection, error) {
 	utils.Info(1, "Init "+string(name)+" Collections.")
 	c := &Collection{
 		Name:     name,
 		TableDef: tD,
 	}
-	rootDir, err := getProjectRoot()
+	dbPath, err := collectionPath(name)
 	if err != nil {
 		return nil, err
 	}
-	dbPath := filepath.Join(rootDir, "db", string(name)+".db")
-	dal, err := DalCreate(dbPath, options)
+	dal, err := DalCreate(dbPath, opts)
 	if err != nil {
-		// fmt.println(err)
-		os.Exit(1)
+		return nil, err
 	}
 	c.DAL = dal
 	if c.DAL.TableDefPage != 0 {
 		utils.Info(1, "Old table def: ", c.DAL.TableDefPage)
-		tableDefPage, err := c.DAL.Readpage(c.DAL.TableDefPage)
+		tableDefPage, err := c.DAL.readPageOf(c.DAL.TableDefPage, PAGE_TABLEDEF)
 		if err != nil {
 			// fmt.println("Error in reading tableDef")
+			dal.Close()
 			return nil, err
 		}
 		c.TableDef = &TableDef{}
-		c.TableDef.Deserialize(tableDefPage.Data)
+		if err := c.TableDef.Deserialize(tableDefPage.Data); err != nil {
+			dal.Close()
+			return nil, corruptPage(c.DAL.TableDefPage, "%v", err)
+		}
 	} else {
 		utils.Info(1, "Creating new TableDef")
-		for i := range tD.UniqueCols {
-			if tD.UniqueCols[i] == tD.PKeyIndex {
-				tD.UniqueCols = append(tD.UniqueCols[:i], tD.UniqueCols[i+1:]...)
-				break
+		// * The primary key needs no index, it moves to index 0 and the column that was at index 0 lives where the
+		// * primary key was. A column listed twice gets one index.
+		uniqueCols := []int{}
+		for _, col := range tD.UniqueCols {
+			if col == tD.PKeyIndex {
+				continue
+			}
+			if col == 0 {
+				col = tD.PKeyIndex
+			}
+			if !containsInt(uniqueCols, col) {
+				uniqueCols = append(uniqueCols, col)
 			}
 		}
+		tD.UniqueCols = uniqueCols
 		if tD.PKeyIndex != 0 {
 			tD.Cols[tD.PKeyIndex], tD.Cols[0] = tD.Cols[0], tD.Cols[tD.PKeyIndex]
 			tD.Types[tD.PKeyIndex], tD.Types[0] = tD.Types[0], tD.Types[tD.PKeyIndex]
 		}
-		tableDefPage := c.DAL.Allocateemptypage()
+		tD.KeyEncoding = KEY_ENCODING_ORDERED
+		if err := tD.checkFits(c.DAL.pageSize); err != nil {
+			dal.Close()
+			return nil, err
+		}
+		tableDefPage := c.DAL.Allocateemptypage(PAGE_TABLEDEF)
 		tableDefPage.Num = c.DAL.GetNextPage()
 		tableDefPage.Data = c.TableDef.Serialize(tableDefPage.Data)
 		c.DAL.TableDefPage = tableDefPage.Num
 		utils.Info(1, "TableDefPage: ", tableDefPage.Num)
 
-		c.DAL.Writepage(tableDefPage)
+		if err := c.DAL.Writepage(tableDefPage); err != nil {
+			dal.Close()
+			return nil, err
+		}
 		tD.PKeyIndex = 0
 		if c.DAL.Root == 0 {
 			c.DAL.Root = c.DAL.GetNextPage()
 		}
 		utils.Info(1, "Collection: new Root Page: ", c.DAL.Root)
 
-		rootPage := c.DAL.Allocateemptypage()
-		rootPage.Num = c.DAL.Root
-		c.DAL.Writepage(rootPage)
-
 		rootNode := &Node{}
 		rootNode.Pagenum = c.DAL.Root
-		c.DAL.Writenode(rootNode)
-		c.DAL.Writemeta(c.DAL.Meta)
+		if _, err := c.DAL.Writenode(rootNode); err != nil {
+			dal.Close()
+			return nil, err
+		}
+		// * The freelist hands out the pages after the root from now on, a write rolled back reads it again
+		if err := c.DAL.commit(); err != nil {
+			dal.Close()
+			return nil, err
+		}
 	}
 
 	return c, nil
 }
 
+// * writeTableDef rewrites the table definition page after the definition changed.
+func (c *Collection) writeTableDef() error {
+	if err := c.TableDef.checkFits(c.DAL.pageSize); err != nil {
+		return err
+	}
+	p := c.DAL.Allocateemptypage(PAGE_TABLEDEF)
+	p.Num = c.DAL.TableDefPage
+	p.Data = c.TableDef.Serialize(p.Data)
+	return c.DAL.Writepage(p)
+}
+
 func (c *Collection) Close() {
 	utils.Info(1, "Closing ", string(c.Name), "Collection")
-	c.DAL.Writemeta(c.DAL.Meta)
-	c.DAL.Writefreelist()
+	if !c.DAL.readOnly {
+		if _, err := c.DAL.Writemeta(c.DAL.Meta); err != nil {
+			utils.Error("Could not write the meta page of ", string(c.Name), ": ", err)
+		} else if _, err := c.DAL.Writefreelist(); err != nil {
+			utils.Error("Could not write the freelist of ", string(c.Name), ": ", err)
+		}
+	}
 	c.DAL.Close()
 }
 
@@ -164,6 +250,87 @@ func (c *Collection) FetchAll(pageNum pgNum) ([]*Item, error) {
 	return items, nil
 }
 
+// * Scan visits the items with low <= key <= high in key order, a nil bound is open. Subtrees outside the bounds are
+// * not read. fn returns false to stop the scan.
+func (c *Collection) Scan(low []byte, high []byte, fn func(item *Item) (bool, error)) error {
+	_, err := c.scanNode(c.DAL.Root, low, high, fn)
+	return err
+}
+
+func (c *Collection) scanNode(pageNum pgNum, low []byte, high []byte, fn func(item *Item) (bool, error)) (bool, error) {
+	node, err := c.DAL.Getnode(pageNum)
+	if err != nil {
+		return false, err
+	}
+	// * Children left of the first item >= low only hold smaller keys
+	start := 0
+	if low != nil {
+		start = sort.Search(len(node.Items), func(i int) bool {
+			return bytes.Compare(node.Items[i].Key, low) >= 0
+		})
+	}
+	for i := start; i <= len(node.Items); i++ {
+		if !node.Isleaf() {
+			more, err := c.scanNode(node.Childnodes[i], low, high, fn)
+			if err != nil || !more {
+				return more, err
+			}
+		}
+		if i == len(node.Items) {
+			break
+		}
+		item := node.Items[i]
+		if high != nil && bytes.Compare(item.Key, high) > 0 {
+			return false, nil
+		}
+		more, err := fn(item)
+		if err != nil || !more {
+			return more, err
+		}
+	}
+	return true, nil
+}
+
+// * ScanReverse is Scan in descending key order.
+func (c *Collection) ScanReverse(low []byte, high []byte, fn func(item *Item) (bool, error)) error {
+	_, err := c.scanNodeReverse(c.DAL.Root, low, high, fn)
+	return err
+}
+
+func (c *Collection) scanNodeReverse(pageNum pgNum, low []byte, high []byte, fn func(item *Item) (bool, error)) (bool, error) {
+	node, err := c.DAL.Getnode(pageNum)
+	if err != nil {
+		return false, err
+	}
+	// * Children right of the first item > high only hold bigger keys
+	end := len(node.Items)
+	if high != nil {
+		end = sort.Search(len(node.Items), func(i int) bool {
+			return bytes.Compare(node.Items[i].Key, high) > 0
+		})
+	}
+	for i := end; i >= 0; i-- {
+		if !node.Isleaf() {
+			more, err := c.scanNodeReverse(node.Childnodes[i], low, high, fn)
+			if err != nil || !more {
+				return more, err
+			}
+		}
+		if i == 0 {
+			break
+		}
+		item := node.Items[i-1]
+		if low != nil && bytes.Compare(item.Key, low) < 0 {
+			return false, nil
+		}
+		more, err := fn(item)
+		if err != nil || !more {
+			return more, err
+		}
+	}
+	return true, nil
+}
+
 func (c *Collection) FindInBetween(low []byte, high []byte) ([]*Item, error) {
 	// fmt.println("-- Range query --")
 	root, err := c.DAL.Getnode(c.DAL.Root)
@@ -242,11 +409,12 @@ func (c *Collection) Put(key []byte, value []byte, update bool) error {
 	} else {
 		utils.Info(2, "Inserting Item at: ", insertionIndex)
 		nodeToInsertIn.addItem(i, insertionIndex)
+		c.DAL.RowCount++
 	}
 	utils.Info(3, "Writing NodeToInsert: ", c.nodeState(nodeToInsertIn))
 	_, err = c.DAL.Writenode(nodeToInsertIn)
 	if err != nil {
-		return nil
+		return err
 	}
 	ancestors, err := c.GetNodes(ancestorsIndexes)
 	if err != nil {
@@ -259,27 +427,30 @@ func (c *Collection) Put(key []byte, value []byte, update bool) error {
 		node := ancestors[i+1]
 		nodeIndex := ancestorsIndexes[i+1]
 		utils.Info(3, "Check: ", c.nodeState(node))
-		if node.isOverPopulated() {
+		if node.needsSplit() {
 			utils.Info(2, "Calling split on: ", len(node.Items))
-			pnode.split(node, nodeIndex)
+			if err := pnode.split(node, nodeIndex); err != nil {
+				return err
+			}
 		}
 	}
 
 	rootNode := ancestors[0]
 	utils.Info(3, "Checking Root: ", c.nodeState(rootNode))
-	if rootNode.isOverPopulated() {
+	if rootNode.needsSplit() {
 		newNode := c.DAL.nodeCreate([]*Item{}, []pgNum{rootNode.Pagenum})
 		utils.Info(2, "Calling split on: ", len(rootNode.Items))
-		newNode.split(rootNode, 0)
+		if err := newNode.split(rootNode, 0); err != nil {
+			return err
+		}
 		newRoot, err := c.DAL.Writenode(newNode)
 		if err != nil {
 			return err
 		}
 		c.DAL.Root = newRoot.Pagenum
 		c.DAL.Meta.Root = newRoot.Pagenum
-		c.DAL.Writemeta(c.DAL.Meta)
 	}
-	return nil
+	return c.DAL.commitMeta()
 }
 
 func (c *Collection) GetNodes(indexes []int) ([]*Node, error) {
@@ -307,7 +478,7 @@ func (c *Collection) Remove(key []byte) error {
 	// * removed and the tree is one level shorter.
 	rootNode, err := c.DAL.Getnode(c.DAL.Root)
 	if err != nil {
-		return nil
+		return err
 	}
 
 	removeItemIndex, nodeToRemoveFrom, anscestorIndexes, err := rootNode.Findkey(key, true)
@@ -317,7 +488,9 @@ func (c *Collection) Remove(key []byte) error {
 	}
 
 	if nodeToRemoveFrom.Isleaf() {
-		nodeToRemoveFrom.removeItemFromLeaf(removeItemIndex)
+		if err := nodeToRemoveFrom.removeItemFromLeaf(removeItemIndex); err != nil {
+			return err
+		}
 	} else {
 		affectedNodes, err := nodeToRemoveFrom.removeItemFromInternal(removeItemIndex)
 		if err != nil {
@@ -344,11 +517,30 @@ func (c *Collection) Remove(key []byte) error {
 
 	rootNode = ancestors[0]
 	// * If the root has no items after rebalancing, there's no need to save it because we ignore it.
+	// * Its only remaining child (the result of the last merge) becomes the new root.
 	if len(rootNode.Items) == 0 && len(rootNode.Childnodes) > 0 {
-		c.DAL.Root = ancestors[1].Pagenum
+		c.DAL.Root = rootNode.Childnodes[0]
+		c.DAL.Deletenode(rootNode.Pagenum)
 	}
+	c.DAL.RowCount--
+	return c.DAL.commitMeta()
+}
 
-	return nil
+// * Count returns the number of items in the tree. The count is kept in the meta page, only a file written before it
+// * was kept is walked once to find it. Count doesn't write, the count found goes to the meta page with the next write.
+func (c *Collection) Count() (int, error) {
+	if !c.DAL.rowCountKnown {
+		count := 0
+		err := c.Scan(nil, nil, func(*Item) (bool, error) {
+			count++
+			return true, nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		c.DAL.RowCount, c.DAL.rowCountKnown = uint64(count), true
+	}
+	return int(c.DAL.RowCount), nil
 }
 
 func (c *Collection) nodeState(node *Node) string {
@@ -356,7 +548,7 @@ func (c *Collection) nodeState(node *Node) string {
 	logString := ""
 	for _, item := range node.Items {
 		row := decodeRow(c.TableDef, item.Value)
-		itKey, _ := checkTypeAndDecodeCol(c.TableDef, 0, item.Key)
+		itKey := decodeKey(c.TableDef, 0, item.Key)
 		row = append([]any{itKey}, row...)
 		logString += utils.AnyToStr(row...)
 	}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"BynxDB/core/utils"
+	"errors"
+	"os"
+)
+
+/*
+* Compact rewrites the files of the table densely and returns the number of bytes reclaimed. Pages released by deletes
+* only go back to the freelist and the file never shrinks.
+* Compacting copies the live pages of every tree into a new file, laid out like a new table: the meta, freelist and
+* table definition pages first, then the nodes of the tree in depth first order with their child pointers renumbered.
+* The new file replaces the old one with a rename once it is fully written, so a crash leaves one of the two.
+ */
+func (db *DB) Compact() (reclaimed int64, err error) {
+	utils.Info(1, "==Compact Call==", db.name())
+	if err := db.checkWritable(); err != nil {
+		return 0, err
+	}
+	defer db.beginWrite()(&err)
+	if err := db.checkNoBackup("compact"); err != nil {
+		return 0, err
+	}
+	for _, c := range db.collections() {
+		n, err := c.compact()
+		reclaimed += n
+		if err != nil {
+			return reclaimed, err
+		}
+	}
+	return reclaimed, nil
+}
+
+// * compact rewrites the collection into a new file and switches the DAL over to it.
+func (c *Collection) compact() (int64, error) {
+	d := c.DAL
+	path, err := collectionPath(c.Name)
+	if err != nil {
+		return 0, err
+	}
+	// * The meta page and freelist on disk have to be current before the size is taken, and the write made before
+	// * the file is replaced, the journal holding pages of the old one
+	if err := d.commit(); err != nil {
+		return 0, err
+	}
+	if err := d.journal.commit(); err != nil {
+		return 0, err
+	}
+	size, err := d.file.Size()
+	if err != nil {
+		return 0, err
+	}
+
+	// * A file left behind by a compaction that failed is started over
+	tmpPath := path + ".compact"
+	if err := d.storage.Remove(tmpPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	file, err := d.storage.Open(tmpPath, false)
+	if err != nil {
+		return 0, err
+	}
+	out := &DAL{
+		storage:        d.storage,
+		file:           file,
+		pageSize:       d.pageSize,
+		MinFillPercent: d.MinFillPercent,
+		MaxFillPercent: d.MaxFillPercent,
+		freeList:       freeListCreate(),
+		Meta:           &Meta{RowCount: d.RowCount, rowCountKnown: d.rowCountKnown},
+	}
+	fail := func(err error) (int64, error) {
+		file.Close()
+		d.storage.Remove(tmpPath)
+		return 0, err
+	}
+	out.freelistPage = out.GetNextPage()
+	tableDefPage, err := d.Readpage(d.TableDefPage)
+	if err != nil {
+		return fail(err)
+	}
+	tableDefPage.Num = out.GetNextPage()
+	out.TableDefPage = tableDefPage.Num
+	if err := out.Writepage(tableDefPage); err != nil {
+		return fail(err)
+	}
+	if out.Root, err = copyNode(d, out, d.Root); err != nil {
+		return fail(err)
+	}
+	if err := out.commit(); err != nil {
+		return fail(err)
+	}
+	if err := file.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := d.storage.Rename(tmpPath, path); err != nil {
+		return fail(err)
+	}
+
+	// * The open file now is the collection's file, the lock moved over to it
+	d.file.Close()
+	d.file, d.freeList, d.Meta = file, out.freeList, out.Meta
+	compacted, err := file.Size()
+	if err != nil {
+		return 0, err
+	}
+	utils.Info(1, "Compacted ", string(c.Name), ": ", size, " -> ", compacted)
+	return size - compacted, nil
+}
+
+// * copyNode copies the subtree at pageNum from one DAL to the next pages of another and returns its new page.
+func copyNode(from *DAL, to *DAL, pageNum pgNum) (pgNum, error) {
+	node, err := from.Getnode(pageNum)
+	if err != nil {
+		return 0, err
+	}
+	node.DAL = to
+	node.Pagenum = to.GetNextPage()
+	for i, child := range node.Childnodes {
+		if node.Childnodes[i], err = copyNode(from, to, child); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := to.Writenode(node); err != nil {
+		return 0, err
+	}
+	return node.Pagenum, nil
+}
@@ -3,8 +3,11 @@ package core
 import (
 	"BynxDB/core/utils"
 	"bytes"
-	"encoding/binary"
 	"errors"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"sort"
 
 	// "log"
 	"strings"
@@ -13,17 +16,77 @@ import (
 type DB struct {
 	records           *Collection
 	uniqueColumnsTree []*Collection
+	// * Primary keys whose ON DELETE actions are being applied
+	deleting map[string]bool
+	// * Compiled patterns of the regex checks, indexed like TableDef.Checks
+	patterns []*regexp.Regexp
+	// * Lets a backup start between two writes
+	gate writeGate
+	// * The options the files were opened with, the tables reached through foreign keys are opened with them too
+	opts *Options
+	// * Makes every write all or nothing, nil when opened read only
+	journal *journal
+}
+
+// * ErrNotFound is returned by the point lookups when no row matches.
+var ErrNotFound = errors.New("[error] row not found")
+
+// * ErrReadOnly is returned by the calls changing a table opened with Options.ReadOnly.
+var ErrReadOnly = errors.New("[error] table is open read only")
+
+func (db *DB) checkWritable() error {
+	if db.records.DAL.readOnly {
+		return ErrReadOnly
+	}
+	return nil
 }
 
 func DbInit(name string, tD *TableDef) (*DB, error) {
+	return DbInitWithOptions(name, tD, nil)
+}
+
+// * DbInitWithOptions is DbInit opening the files with opts, nil or zero fields taking the defaults.
+func DbInitWithOptions(name string, tD *TableDef, opts *Options) (*DB, error) {
 	utils.Info(1, "Init "+name+" DB.")
+	opts = opts.withDefaults()
 	name = strings.ToUpper(name)
 	for ind, colName := range tD.Cols {
 		tD.Cols[ind] = strings.ToUpper(colName)
 	}
 	db := &DB{}
-	var err error
-	db.records, err = CollectionCreate([]byte(name+"rec"), tD)
+	exists, err := tableExists(opts.storage(), name)
+	if err != nil {
+		return nil, err
+	}
+	db.opts = opts
+	if !exists && opts.ReadOnly {
+		return nil, errors.New("[error] no such table: " + name)
+	}
+	recordsPath, err := collectionPath([]byte(name + "rec"))
+	if err != nil {
+		return nil, err
+	}
+	journalFile := journalPath(filepath.Dir(recordsPath), name)
+	if exists {
+		// * A write left unfinished is rolled back before the files are read, the table locked until they are open
+		unlock, err := opts.storage().Lock(recordsPath, !opts.ReadOnly, opts.LockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+		if err := recoverJournal(opts.storage(), journalFile, opts); err != nil {
+			return nil, err
+		}
+	}
+	if !exists {
+		if err := checkForeignKeyDefs(name, tD, opts); err != nil {
+			return nil, err
+		}
+		if err := checkConstraintDefs(tD, opts.PageSize); err != nil {
+			return nil, err
+		}
+	}
+	db.records, err = collectionCreate([]byte(name+"rec"), tD, opts)
 	if err != nil {
 		utils.Error("Failed to Create Collection: ", name+"rec")
 		return nil, err
@@ -35,47 +98,145 @@ func DbInit(name string, tD *TableDef) (*DB, error) {
 				Types: []uint16{db.records.TableDef.Types[colIndex], db.records.TableDef.Types[0]},
 				Cols:  []string{db.records.TableDef.Cols[colIndex], db.records.TableDef.Cols[0]},
 			}
-			tmpCol, err := CollectionCreate([]byte(name+db.records.TableDef.Cols[colIndex]), indexTableDef)
+			tmpCol, err := collectionCreate([]byte(name+db.records.TableDef.Cols[colIndex]), indexTableDef, opts)
 			if err != nil {
 				utils.Error("Failed to Create Collection: ", name+db.records.TableDef.Cols[colIndex])
+				for _, c := range db.collections() {
+					c.DAL.Close()
+				}
 				return nil, err
 			}
 			db.uniqueColumnsTree = append(db.uniqueColumnsTree, tmpCol)
 		}
 	}
+	if db.patterns, err = compileChecks(db.records.TableDef); err != nil {
+		return nil, err
+	}
+	if !opts.ReadOnly {
+		db.journal = newJournal(opts.storage(), journalFile)
+		for _, c := range db.collections() {
+			path, err := collectionPath(c.Name)
+			if err != nil {
+				return nil, err
+			}
+			db.journal.add(c.DAL, path)
+		}
+	}
 	utils.Info(1, "Loaded Database: ", "Freelist: ", db.records.DAL.freelistPage, "TableDef: ", db.records.DAL.TableDefPage, "Root: ", db.records.DAL.Root)
+	registerTable(db)
+	if !exists {
+		if err := db.linkParents(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
 	return db, nil
 }
 
-func (db *DB) Insert(valuesToInsert ...any) error {
+// * TableExists reports whether a table with the given name has been created.
+func TableExists(name string) (bool, error) {
+	return TableExistsWithOptions(name, nil)
+}
+
+// * TableExistsWithOptions is TableExists looking in the storage of opts.
+func TableExistsWithOptions(name string, opts *Options) (bool, error) {
+	return tableExists(opts.withDefaults().storage(), name)
+}
+
+func tableExists(storage Storage, name string) (bool, error) {
+	path, err := collectionPath([]byte(strings.ToUpper(name) + "rec"))
+	if err != nil {
+		return false, err
+	}
+	return storage.Exists(path)
+}
+
+// * Tables lists the names of the tables in the db directory, sorted.
+func Tables() ([]string, error) {
+	return TablesWithOptions(nil)
+}
+
+// * TablesWithOptions is Tables listing the tables in the storage of opts.
+func TablesWithOptions(opts *Options) ([]string, error) {
+	path, err := collectionPath([]byte("rec"))
+	if err != nil {
+		return nil, err
+	}
+	files, err := opts.withDefaults().storage().List(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	// * Index files are named after their upper case column, only record files end in a lower case "rec"
+	for _, file := range files {
+		if name, ok := strings.CutSuffix(file, "rec.db"); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// * OpenDB opens an existing table using the TableDef stored with it.
+func OpenDB(name string) (*DB, error) {
+	return OpenDBWithOptions(name, nil)
+}
+
+// * OpenDBWithOptions is OpenDB opening the files with opts.
+func OpenDBWithOptions(name string, opts *Options) (*DB, error) {
+	exists, err := tableExists(opts.withDefaults().storage(), name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("[error] no such table: " + name)
+	}
+	return DbInitWithOptions(name, &TableDef{}, opts)
+}
+
+// * TableDef returns a copy of the stored table definition, primary key first.
+func (db *DB) TableDef() *TableDef {
+	tD := db.records.TableDef
+	return &TableDef{
+		Types:        append([]uint16{}, tD.Types...),
+		Cols:         append([]string{}, tD.Cols...),
+		UniqueCols:   append([]int{}, tD.UniqueCols...),
+		KeyEncoding:  tD.KeyEncoding,
+		ForeignKeys:  append([]ForeignKey{}, tD.ForeignKeys...),
+		ReferencedBy: append([]string{}, tD.ReferencedBy...),
+		Defaults:     maps.Clone(tD.Defaults),
+		Checks:       append([]Check{}, tD.Checks...),
+	}
+}
+
+func (db *DB) Insert(valuesToInsert ...any) (err error) {
 	utils.Info(2, "==Insert Call==", utils.AnyToStr(valuesToInsert...))
-	if len(valuesToInsert) != len(db.records.TableDef.Cols) {
-		return errors.New("[Error]:too few or too many columns")
+	if err := db.checkWritable(); err != nil {
+		return err
 	}
-	pKey := make([]byte, 0)
-	value := make([]byte, 0)
-	// * Encoding Primary Key
-	pKey, err := checkTypeAndEncodeByte(db.records.TableDef, 0, valuesToInsert[0], pKey)
+	defer db.beginWrite()(&err)
+	pKey, value, indexKeys, err := db.encodeRow(valuesToInsert)
 	if err != nil {
-		utils.Error("Unable to encode Pkey")
 		return err
 	}
-	// * Encoding Rest of the columns
-	for i := 1; i < len(valuesToInsert); i++ {
-		value, err = checkTypeAndEncodeByte(db.records.TableDef, i, valuesToInsert[i], value)
-		if err != nil {
-			return err
-		}
+	if err := db.checkConstraints(valuesToInsert); err != nil {
+		return err
+	}
+	if err := db.checkForeignKeys(valuesToInsert, nil); err != nil {
+		return err
+	}
+	// * A row already there is refused before any index is written
+	it, err := db.records.Find(pKey)
+	if err != nil {
+		return err
+	}
+	if it != nil {
+		return errors.New("[error] this key already excists in the key-value store")
 	}
 	for i, col := range db.records.UniqueCols {
 		indexCollection := db.uniqueColumnsTree[i]
-		indexKey, err := checkTypeAndEncodeByte(db.records.TableDef, col, valuesToInsert[col], []byte{})
-		if err != nil {
-			utils.Error("Unable to encode Column: ", db.records.TableDef.Cols[col])
-			return err
-		}
 		utils.Info(2, "Checking Unique Column: ", db.records.TableDef.Cols[col])
-		err = indexCollection.Put(indexKey, pKey, false)
+		err = indexCollection.Put(indexKeys[i], pKey, false)
 		if err != nil {
 			utils.Error("Unable To Insert in Unique index: ", db.records.TableDef.Cols[col], err)
 			return err
@@ -89,8 +250,41 @@ func (db *DB) Insert(valuesToInsert ...any) error {
 	return nil
 }
 
+// * encodeRow type checks a positional row and encodes it into the primary key, the record value and the keys of
+// * every unique index, in the order of UniqueCols.
+func (db *DB) encodeRow(valuesToInsert []any) ([]byte, []byte, [][]byte, error) {
+	tD := db.records.TableDef
+	if len(valuesToInsert) != len(tD.Cols) {
+		return nil, nil, nil, errors.New("[Error]:too few or too many columns")
+	}
+	value := make([]byte, 0)
+	// * Encoding Primary Key
+	pKey, err := encodeKey(tD, 0, valuesToInsert[0])
+	if err != nil {
+		utils.Error("Unable to encode Pkey")
+		return nil, nil, nil, err
+	}
+	// * Encoding Rest of the columns
+	for i := 1; i < len(valuesToInsert); i++ {
+		value, err = checkTypeAndEncodeByte(tD, i, valuesToInsert[i], value)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	indexKeys := make([][]byte, 0, len(tD.UniqueCols))
+	for _, col := range tD.UniqueCols {
+		indexKey, err := encodeKey(tD, col, valuesToInsert[col])
+		if err != nil {
+			utils.Error("Unable to encode Column: ", tD.Cols[col])
+			return nil, nil, nil, err
+		}
+		indexKeys = append(indexKeys, indexKey)
+	}
+	return pKey, value, indexKeys, nil
+}
+
 func (db *DB) PKeyQuery(val any) ([]any, error) {
-	key, err := checkTypeAndEncodeByte(db.records.TableDef, 0, val, []byte{})
+	key, err := encodeKey(db.records.TableDef, 0, val)
 	if err != nil {
 		utils.Error(err)
 		return nil, err
@@ -102,7 +296,7 @@ func (db *DB) PKeyQuery(val any) ([]any, error) {
 		return nil, err
 	}
 	if it == nil {
-		return nil, errors.New("[error] row not found")
+		return nil, ErrNotFound
 	}
 	row := decodeRow(db.records.TableDef, it.Value)
 
@@ -110,45 +304,23 @@ func (db *DB) PKeyQuery(val any) ([]any, error) {
 	return row, nil
 }
 
+// * PointQuery returns the rows whose column colIndex equals val. Lookups on the primary key or a unique column
+// * return ErrNotFound when nothing matches, scans of other columns an empty result.
 func (db *DB) PointQuery(colIndex int, val any) ([][]any, error) {
-	if colIndex == 0 {
-		if row, err := db.PKeyQuery(val); err != nil {
-			return nil, err
-		} else {
-			return [][]any{row}, nil
-		}
-	}
-	for _, col := range db.records.UniqueCols {
-		if colIndex == col {
-			row, err := db.PointQueryUniqueCol(colIndex, val)
-			if err != nil {
-				return nil, err
-			}
-			return [][]any{row}, err
-		}
+	if colIndex < 0 || colIndex >= len(db.records.TableDef.Cols) {
+		return nil, errors.New("[error] column index out of range")
 	}
-	_, err := checkTypeAndEncodeByte(db.records.TableDef, colIndex, val, []byte{})
+	plan, err := db.Plan(Eq(db.records.TableDef.Cols[colIndex], val))
 	if err != nil {
 		utils.Error(err)
 		return nil, err
 	}
-	items, err := db.records.FetchAll(0)
+	rows, err := plan.Rows()
 	if err != nil {
-		utils.Error(err)
 		return nil, err
 	}
-	var rows [][]any
-	for _, item := range items {
-		row := decodeRow(db.records.TableDef, item.Value)
-		if db.records.TableDef.Types[colIndex] == TYPE_BYTE && bytes.Equal(row[colIndex-1].([]byte), val.([]byte)) {
-			itKey, _ := checkTypeAndDecodeCol(db.records.TableDef, 0, item.Key)
-			row = append([]any{itKey}, row...)
-			rows = append(rows, row)
-		} else if db.records.TableDef.Types[colIndex] == TYPE_INT64 && row[colIndex-1] == val {
-			itKey, _ := checkTypeAndDecodeCol(db.records.TableDef, 0, item.Key)
-			row = append([]any{itKey}, row...)
-			rows = append(rows, row)
-		}
+	if len(rows) == 0 && plan.Access != ACCESS_FULL_SCAN {
+		return nil, ErrNotFound
 	}
 	return rows, nil
 }
@@ -165,144 +337,212 @@ func (db *DB) PointQueryUniqueCol(colIndex int, val any) ([]any, error) {
 		return nil, err
 	}
 	if it == nil {
-		utils.Error(err)
-		return nil, errors.New("[error] value not found")
-	}
-	// // fmt.println(db.records.TableDef.Types[0])
-	if db.records.TableDef.Types[0] == TYPE_INT64 {
-		return db.PKeyQuery(int(binary.LittleEndian.Uint64(it.Value)))
+		return nil, ErrNotFound
 	}
-	return db.PKeyQuery(it.Value)
+	return db.PKeyQuery(decodeKey(db.records.TableDef, 0, it.Value))
 }
 
+// * SelectEntireTable returns every row in the order of the records tree, which is primary key order unless the table
+// * uses KEY_ENCODING_LEGACY.
 func (db *DB) SelectEntireTable() ([][]any, error) {
-	items, err := db.records.FetchAll(0)
-	if err != nil {
-		return nil, err
-	}
-	var rows [][]any
-	for _, item := range items {
-		row := decodeRow(db.records.TableDef, item.Value)
-		itKey, _ := checkTypeAndDecodeCol(db.records.TableDef, 0, item.Key)
-		row = append([]any{itKey}, row...)
-		rows = append(rows, row)
-	}
-	return rows, nil
+	return db.Query()
 }
 
+// * RangeQuery returns the rows whose column colIndex lies between low and high, both inclusive.
 func (db *DB) RangeQuery(colIndex int, low any, high any) ([][]any, error) {
-	lowKey := make([]byte, 0)
-	highKey := make([]byte, 0)
-	lowKey, err := checkTypeAndEncodeByte(db.records.TableDef, colIndex, low, lowKey)
-	if err != nil {
-		return nil, err
+	if colIndex < 0 || colIndex >= len(db.records.TableDef.Cols) {
+		return nil, errors.New("[error] column index out of range")
 	}
-	// lowKey = lowKey[2:]
-	highKey, err = checkTypeAndEncodeByte(db.records.TableDef, colIndex, high, highKey)
-	if err != nil {
-		return nil, err
+	return db.Query(Between(db.records.TableDef.Cols[colIndex], low, high))
+}
+
+func (db *DB) UpdatePoint(colIndex int, valToChange any, newVal any) (err error) {
+	if err := db.checkWritable(); err != nil {
+		return err
 	}
-	// highKey = highKey[2:]
-	items, err := db.records.FetchAll(0)
+	defer db.beginWrite()(&err)
+	rowsToUpdate, err := db.PointQuery(colIndex, valToChange)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var rows [][]any
-	for _, item := range items {
-		var keyToCom []byte
-		row := decodeRow(db.records.TableDef, item.Value)
-		if colIndex == 0 {
-			keyToCom = item.Key
-		} else {
-			if db.records.TableDef.Types[colIndex] == TYPE_INT64 {
-				keyToCom = binary.LittleEndian.AppendUint64(keyToCom, uint64(row[colIndex-1].(int)))
-			} else {
-				keyToCom = row[colIndex-1].([]byte)
-			}
+	if len(rowsToUpdate) == 0 {
+		return errors.New("no row found to update")
+	}
+	colName := db.records.TableDef.Cols[colIndex]
+	for _, row := range rowsToUpdate {
+		err = db.Update(row[0], map[string]any{colName: newVal})
+		if err != nil {
+			return err
 		}
-		var lowCom, highCom int
-		switch db.records.TableDef.Types[colIndex] {
-		case TYPE_BYTE:
-			utils.Info(4, "Range Query Type Byte")
-			lowCom = bytes.Compare(lowKey, keyToCom)
-			highCom = bytes.Compare(highKey, keyToCom)
-			utils.Info(4, string(keyToCom), (keyToCom), (lowKey), (highKey), lowCom, highCom)
-		case TYPE_INT64:
-
-			utils.Info(4, "Range Query Type INT", keyToCom, lowKey, highKey)
+	}
+	return nil
+}
 
-			lowCom = int(binary.LittleEndian.Uint64(lowKey)) - int(binary.LittleEndian.Uint64(keyToCom))
-			highCom = int(binary.LittleEndian.Uint64(highKey)) - int(binary.LittleEndian.Uint64(keyToCom))
+// * Atomic runs fn as a single write: the changes it makes to the table through db are made together, or rolled back
+// * together when it returns an error. Cascades into other tables are made on their own.
+func (db *DB) Atomic(fn func() error) (err error) {
+	if err := db.checkWritable(); err != nil {
+		return err
+	}
+	defer db.beginWrite()(&err)
+	return fn()
+}
 
+// * Update changes the given columns of the row identified by pKeyVal. The changes map column names to their new
+// * values and may include the primary key itself, in which case the record is removed and re-inserted under the new
+// * key. Unique index entries whose column value changed are moved to the new value, the others are re-pointed at
+// * the new primary key. All conflicts are checked before any tree is modified.
+func (db *DB) Update(pKeyVal any, changes map[string]any) (err error) {
+	utils.Info(2, "==Update Call==", utils.AnyToStr(pKeyVal))
+	if err := db.checkWritable(); err != nil {
+		return err
+	}
+	defer db.beginWrite()(&err)
+	tD := db.records.TableDef
+	oldRow, err := db.PKeyQuery(pKeyVal)
+	if err != nil {
+		return err
+	}
+	newRow := make([]any, len(oldRow))
+	copy(newRow, oldRow)
+	for colName, val := range changes {
+		colIndex, err := tD.ColIndex(colName)
+		if err != nil {
+			return err
 		}
-		// // fmt.println(lowCom, highCom, keyToCom)
-		if lowCom <= 0 && highCom >= 0 {
-			itKey, _ := checkTypeAndDecodeCol(db.records.TableDef, 0, item.Key)
-			row = append([]any{itKey}, row...)
-			rows = append(rows, row)
-		}
+		newRow[colIndex] = val
 	}
-	return rows, nil
-}
 
-func (db *DB) UpdatePoint(colIndex int, valToChange any, newVal any) error {
-	rowsToUpdate, err := db.PointQuery(colIndex, valToChange)
+	oldPKey, err := encodeKey(tD, 0, oldRow[0])
 	if err != nil {
 		return err
 	}
-	if len(rowsToUpdate) == 0 {
-		return errors.New("no row found to update")
+	newPKey, err := encodeKey(tD, 0, newRow[0])
+	if err != nil {
+		return err
+	}
+	value := make([]byte, 0)
+	for i := 1; i < len(newRow); i++ {
+		value, err = checkTypeAndEncodeByte(tD, i, newRow[i], value)
+		if err != nil {
+			return err
+		}
 	}
-	for ind := range rowsToUpdate {
-		rowsToUpdate[ind][colIndex] = newVal
 
-		pKey := make([]byte, 0)
-		value := make([]byte, 0)
-		pKey, err = checkTypeAndEncodeByte(db.records.TableDef, 0, rowsToUpdate[ind][0], pKey)
+	pKeyChanged := !bytes.Equal(oldPKey, newPKey)
+	if err := db.checkConstraints(newRow); err != nil {
+		return err
+	}
+	if err := db.checkForeignKeys(newRow, nil); err != nil {
+		return err
+	}
+	if pKeyChanged {
+		if err := db.checkParentKeyChange(oldRow[0]); err != nil {
+			return err
+		}
+		it, err := db.records.Find(newPKey)
 		if err != nil {
 			return err
 		}
-		for i := 1; i < len(rowsToUpdate[ind]); i++ {
-			value, err = checkTypeAndEncodeByte(db.records.TableDef, i, rowsToUpdate[ind][i], value)
-			if err != nil {
-				return err
-			}
+		if it != nil {
+			return errors.New("[error] this key already excists in the key-value store")
 		}
-		for i, col := range db.records.UniqueCols {
-			indexCollection := db.uniqueColumnsTree[i]
-			indexKey, err := checkTypeAndEncodeByte(db.records.TableDef, col, rowsToUpdate[ind][col], []byte{})
-			if err != nil {
-				return err
-			}
-			err = indexCollection.Put(indexKey, pKey, true)
-			if err != nil {
+	}
+
+	oldIndexKeys := make([][]byte, len(tD.UniqueCols))
+	newIndexKeys := make([][]byte, len(tD.UniqueCols))
+	for i, col := range tD.UniqueCols {
+		oldIndexKeys[i], err = encodeKey(tD, col, oldRow[col])
+		if err != nil {
+			return err
+		}
+		newIndexKeys[i], err = encodeKey(tD, col, newRow[col])
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(oldIndexKeys[i], newIndexKeys[i]) {
+			continue
+		}
+		it, err := db.uniqueColumnsTree[i].Find(newIndexKeys[i])
+		if err != nil {
+			return err
+		}
+		if it != nil {
+			return errors.New("[error] value already exists in unique column: " + tD.Cols[col])
+		}
+	}
+
+	if pKeyChanged {
+		utils.Info(2, "Moving record to new primary key")
+		if err := db.records.Remove(oldPKey); err != nil {
+			return err
+		}
+		err = db.records.Put(newPKey, value, false)
+	} else {
+		err = db.records.Put(oldPKey, value, true)
+	}
+	if err != nil {
+		utils.Error("Unable to Put in records Table ", err)
+		return err
+	}
+
+	for i := range tD.UniqueCols {
+		indexCollection := db.uniqueColumnsTree[i]
+		if !bytes.Equal(oldIndexKeys[i], newIndexKeys[i]) {
+			if err := indexCollection.Remove(oldIndexKeys[i]); err != nil {
 				return err
 			}
+			err = indexCollection.Put(newIndexKeys[i], newPKey, false)
+		} else if pKeyChanged {
+			err = indexCollection.Put(newIndexKeys[i], newPKey, true)
 		}
-		err = db.records.Put(pKey, value, true)
 		if err != nil {
+			utils.Error("Unable To Update Unique index: ", tD.Cols[tD.UniqueCols[i]], err)
 			return err
 		}
 	}
 	return nil
 }
 
-func (db *DB) Delete(colIndex int, val any) error {
+func (db *DB) Delete(colIndex int, val any) (err error) {
 	utils.Info(4, "Deleting: ", val, " In column: ", colIndex)
-	key, err := checkTypeAndEncodeByte(db.records.TableDef, colIndex, val, []byte{})
+	if err := db.checkWritable(); err != nil {
+		return err
+	}
+	defer db.beginWrite()(&err)
+	key, err := encodeKey(db.records.TableDef, colIndex, val)
 	// * Primary key column
 	if colIndex == 0 {
 		if err != nil {
 			return err
 		}
+		if len(db.records.ReferencedBy) != 0 {
+			it, err := db.records.Find(key)
+			if err != nil {
+				return err
+			}
+			if it != nil {
+				if err := db.onParentDelete(val); err != nil {
+					return err
+				}
+				// * A cascade around a cycle of tables may have deleted the row already
+				if it, err = db.records.Find(key); err != nil || it == nil {
+					return err
+				}
+			}
+		}
 		if len(db.uniqueColumnsTree) != 0 {
 			rowToDel, err := db.PKeyQuery(val)
 			if err != nil {
 				return err
 			}
 			for i, uniqueColIndex := range db.records.UniqueCols {
-				keyToDel, _ := checkTypeAndEncodeByte(db.records.TableDef, uniqueColIndex, rowToDel[uniqueColIndex-1], []byte{})
-				err := db.uniqueColumnsTree[i].Remove(keyToDel)
+				// * PKeyQuery returns the primary key at index 0, so the row is indexed like the TableDef
+				keyToDel, err := encodeKey(db.records.TableDef, uniqueColIndex, rowToDel[uniqueColIndex])
+				if err != nil {
+					return err
+				}
+				err = db.uniqueColumnsTree[i].Remove(keyToDel)
 				if err != nil {
 					return err
 				}
@@ -321,42 +561,32 @@ func (db *DB) Delete(colIndex int, val any) error {
 				if item == nil {
 					return errors.New("value not found")
 				}
-				pKey, _ := checkTypeAndDecodeCol(db.records.TableDef, 0, item.Value)
+				pKey := decodeKey(db.records.TableDef, 0, item.Value)
 				utils.Info(4, "Deleting pKey: ", pKey)
 				return db.Delete(0, pKey)
 			}
 		}
 		// * non-unique column
-		rows, err := db.PointQuery(colIndex, val)
-		if err != nil {
-			return err
-		}
-		// fmt.println("Rows to delete: ", len(rows))
-		for _, row := range rows {
-			// fmt.println("Deleting: ", row)
-			for i, uniqueColIndex := range db.records.UniqueCols {
-				colKeyToDel, _ := checkTypeAndEncodeByte(db.records.TableDef, uniqueColIndex, row[uniqueColIndex], []byte{})
-				err := db.uniqueColumnsTree[i].Remove(colKeyToDel)
-				if err != nil {
-					return err
-				}
-			}
-			pKey, _ := checkTypeAndEncodeByte(db.records.TableDef, 0, row[0], []byte{})
-			err := db.records.Remove(pKey)
-			if err != nil {
-				return err
-			}
+		if colIndex < 0 || colIndex >= len(db.records.TableDef.Cols) {
+			return errors.New("[error] column index out of range")
 		}
-		return nil
+		_, err := db.DeleteWhere(Eq(db.records.TableDef.Cols[colIndex], val))
+		return err
 	}
 }
 
+func (db *DB) collections() []*Collection {
+	return append([]*Collection{db.records}, db.uniqueColumnsTree...)
+}
+
 func (db *DB) Close() {
 	utils.Info(1, "--Closing DB--")
+	unregisterTable(db)
 	for _, uniqueTree := range db.uniqueColumnsTree {
 		uniqueTree.Close()
 	}
 	db.records.Close()
+	db.journal.close()
 }
 
 func decodeRow(tD *TableDef, buf []byte) []any {
@@ -431,7 +661,7 @@ func checkUniqueColAndEncode(tD *TableDef, colIndex int, val any) ([]byte, int,
 	if collectionIndex == -1 {
 		return nil, 0, errors.New("[error] not a unique column")
 	}
-	key, err := checkTypeAndEncodeByte(tD, colIndex, val, []byte{})
+	key, err := encodeKey(tD, colIndex, val)
 	if err != nil {
 		return nil, 0, err
 	}
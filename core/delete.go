@@ -0,0 +1,124 @@
+package core
+
+import (
+	"BynxDB/core/utils"
+	"errors"
+)
+
+// * Rows collected by one walk of a delete. Deleting changes the tree under a running scan, so matching rows are
+// * gathered a batch at a time, removed, and the walk starts again after the last one.
+const deleteBatchSize = 1000
+
+// * DeleteRange deletes the rows whose named column lies between low and high, both inclusive, and returns how many
+// * were deleted.
+func (db *DB) DeleteRange(col string, low any, high any) (int, error) {
+	return db.DeleteWhere(Between(col, low, high))
+}
+
+/*
+* DeleteWhere deletes the rows matching every predicate and returns how many were deleted. The rows are reached
+* through the plan Query would use, so a range on the primary key or a unique column only walks the range. Matching
+* rows are removed in batches together with their unique index entries, and the meta page and freelist of every tree
+* are written once per batch. On tables with ordered keys each walk resumes after the last row of the previous batch,
+* otherwise it starts over and finds the rows left.
+ */
+func (db *DB) DeleteWhere(preds ...Predicate) (n int, err error) {
+	utils.Info(2, "==DeleteWhere Call==", preds)
+	if err := db.checkWritable(); err != nil {
+		return 0, err
+	}
+	defer db.beginWrite()(&err)
+	tD := db.records.TableDef
+	deleted := 0
+	var resume *Predicate
+	for {
+		walk := preds
+		if resume != nil {
+			walk = append(append([]Predicate{}, preds...), *resume)
+		}
+		plan, err := db.Plan(walk...)
+		if err != nil {
+			return deleted, err
+		}
+		// * The walk resumes on the column of the searched tree, a full scan walks the primary key
+		treeCol := 0
+		if plan.Access == ACCESS_INDEX_RANGE {
+			treeCol, _ = tD.ColIndex(plan.Column)
+		}
+		var rows [][]any
+		err = plan.Each(func(row []any) (bool, error) {
+			rows = append(rows, row)
+			return len(rows) < deleteBatchSize, nil
+		})
+		if err != nil {
+			return deleted, err
+		}
+		n, err := db.deleteRows(rows)
+		deleted += n
+		if err != nil || len(rows) < deleteBatchSize {
+			return deleted, err
+		}
+		if orderedKeys(tD) {
+			after := Gt(tD.Cols[treeCol], rows[len(rows)-1][treeCol])
+			resume = &after
+		}
+	}
+}
+
+// * deleteRows removes positional rows read from the table along with their unique index entries. Rows of a table
+// * other tables reference go through Delete, which applies the ON DELETE actions.
+func (db *DB) deleteRows(rows [][]any) (int, error) {
+	tD := db.records.TableDef
+	if len(tD.ReferencedBy) != 0 {
+		deleted := 0
+		for _, row := range rows {
+			// * A cascade from an earlier row of a self referencing table may have deleted it already
+			if _, err := db.PKeyQuery(row[0]); errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err := db.Delete(0, row[0]); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+		return deleted, nil
+	}
+
+	collections := db.collections()
+	for _, c := range collections {
+		c.DAL.batching = true
+	}
+	deleted := 0
+	var err error
+	for _, row := range rows {
+		if err = db.deleteRow(row); err != nil {
+			break
+		}
+		deleted++
+	}
+	for _, c := range collections {
+		if commitErr := c.DAL.commit(); commitErr != nil && err == nil {
+			err = commitErr
+		}
+	}
+	return deleted, err
+}
+
+// * deleteRow removes a positional row and its unique index entries.
+func (db *DB) deleteRow(row []any) error {
+	tD := db.records.TableDef
+	for i, colIndex := range tD.UniqueCols {
+		indexKey, err := encodeKey(tD, colIndex, row[colIndex])
+		if err != nil {
+			return err
+		}
+		if err := db.uniqueColumnsTree[i].Remove(indexKey); err != nil {
+			return err
+		}
+	}
+	pKey, err := encodeKey(tD, 0, row[0])
+	if err != nil {
+		return err
+	}
+	return db.records.Remove(pKey)
+}
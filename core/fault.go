@@ -0,0 +1,247 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+/*
+* FaultStorage wraps a Storage to fail its writes on purpose, testing how tables cope with a failing disk or a dying
+* process. Every write, truncation, removal and rename going through it is counted; the one numbered FailAt fails with
+* ErrInjected, the first half of it landing when Torn is set. With Crash set every write after it fails too, as
+* nothing reaches the disk once the process died: the wrapped storage is left as a new process would find it.
+*
+* DropUnsynced makes the crash a power loss as well: the writes and truncations made to a file since it was last synced
+* are dropped, each one or not at random, as a disk writes its cache back in no particular order. Removals and renames
+* are taken to be made when they return.
+ */
+type FaultStorage struct {
+	Storage
+	FailAt       int
+	Torn         bool
+	Crash        bool
+	DropUnsynced bool
+
+	mu     sync.Mutex
+	ops    int
+	failed bool
+	// * dropped is set once the crash dropped the writes that weren't synced
+	dropped bool
+	// * unsynced holds, by path, what a file held when it was last synced and the changes made to it since
+	unsynced map[string]*unsyncedFile
+}
+
+type unsyncedFile struct {
+	data    []byte
+	changes []unsyncedChange
+}
+
+// * unsyncedChange is a write of data at offset, or a truncation to offset when data is nil.
+type unsyncedChange struct {
+	offset int64
+	data   []byte
+}
+
+// * ErrInjected is matched by the errors of the writes a FaultStorage fails.
+var ErrInjected = errors.New("[error] injected fault")
+
+func NewFaultStorage(storage Storage) *FaultStorage {
+	return &FaultStorage{Storage: storage}
+}
+
+// * Ops returns the number of writes counted so far. Counted without a fault, it bounds FailAt for a workload.
+func (s *FaultStorage) Ops() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ops
+}
+
+// * Failed reports whether the fault was injected.
+func (s *FaultStorage) Failed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed
+}
+
+// * Crashed reports whether the process is taken to have died.
+func (s *FaultStorage) Crashed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed && s.Crash
+}
+
+// * fault counts a write and returns whether it fails, and whether a torn write lands partly.
+func (s *FaultStorage) fault() (fail bool, torn bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failed && s.Crash {
+		return true, false
+	}
+	s.ops++
+	if s.FailAt == 0 || s.ops != s.FailAt {
+		return false, false
+	}
+	s.failed = true
+	return true, s.Torn
+}
+
+// * injected returns the error of a write failed on purpose, once the part of it that lands is made. A crash drops the
+// * writes that weren't synced then.
+func (s *FaultStorage) injected() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Crash && s.DropUnsynced && !s.dropped {
+		s.dropUnsynced()
+	}
+	return ErrInjected
+}
+
+// * track notes a change about to be made to the file at path, reading what it holds when it is the first one since
+// * the file was synced.
+func (s *FaultStorage) track(file StorageFile, path string, change unsyncedChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.DropUnsynced || s.dropped {
+		return nil
+	}
+	if s.unsynced == nil {
+		s.unsynced = map[string]*unsyncedFile{}
+	}
+	f, ok := s.unsynced[path]
+	if !ok {
+		size, err := file.Size()
+		if err != nil {
+			return err
+		}
+		f = &unsyncedFile{data: make([]byte, size)}
+		if _, err := file.ReadAt(f.data, 0); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		s.unsynced[path] = f
+	}
+	f.changes = append(f.changes, change)
+	return nil
+}
+
+// * dropUnsynced brings every file changed since it was synced back to what it held then, with a random part of the
+// * changes made since. The random source is seeded by FailAt, a fault is repeated whole.
+func (s *FaultStorage) dropUnsynced() {
+	rng := rand.New(rand.NewSource(int64(s.FailAt)))
+	for _, path := range slices.Sorted(maps.Keys(s.unsynced)) {
+		f := s.unsynced[path]
+		data := f.data
+		for _, c := range f.changes {
+			if rng.Intn(2) == 0 {
+				continue
+			}
+			switch end := c.offset + int64(len(c.data)); {
+			case c.data == nil && c.offset <= int64(len(data)):
+				data = data[:c.offset]
+			case end > int64(len(data)):
+				data = append(data, make([]byte, end-int64(len(data)))...)
+			}
+			copy(data[c.offset:], c.data)
+		}
+		// * The files are brought back through the wrapped storage, which doesn't fail
+		if file, err := s.Storage.Open(path, false); err == nil {
+			file.Truncate(int64(len(data)))
+			file.WriteAt(data, 0)
+			file.Close()
+		}
+	}
+	s.unsynced, s.dropped = nil, true
+}
+
+func (s *FaultStorage) Open(path string, readOnly bool) (StorageFile, error) {
+	if !readOnly && s.Crashed() {
+		return nil, ErrInjected
+	}
+	file, err := s.Storage.Open(path, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	return &faultFile{StorageFile: file, storage: s, path: filepath.Clean(path)}, nil
+}
+
+func (s *FaultStorage) Remove(path string) error {
+	if fail, _ := s.fault(); fail {
+		return s.injected()
+	}
+	if err := s.Storage.Remove(path); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.unsynced, filepath.Clean(path))
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *FaultStorage) Rename(oldPath string, newPath string) error {
+	if fail, _ := s.fault(); fail {
+		return s.injected()
+	}
+	if err := s.Storage.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldPath, newPath = filepath.Clean(oldPath), filepath.Clean(newPath)
+	delete(s.unsynced, newPath)
+	if f, ok := s.unsynced[oldPath]; ok {
+		s.unsynced[newPath] = f
+		delete(s.unsynced, oldPath)
+	}
+	return nil
+}
+
+type faultFile struct {
+	StorageFile
+	storage *FaultStorage
+	path    string
+}
+
+func (f *faultFile) WriteAt(p []byte, off int64) (int, error) {
+	fail, torn := f.storage.fault()
+	if fail && !torn {
+		return 0, f.storage.injected()
+	}
+	if fail {
+		p = p[:len(p)/2]
+	}
+	if err := f.storage.track(f.StorageFile, f.path, unsyncedChange{offset: off, data: append([]byte{}, p...)}); err != nil {
+		return 0, err
+	}
+	n, err := f.StorageFile.WriteAt(p, off)
+	if err != nil || !fail {
+		return n, err
+	}
+	return n, f.storage.injected()
+}
+
+func (f *faultFile) Truncate(size int64) error {
+	if fail, _ := f.storage.fault(); fail {
+		return f.storage.injected()
+	}
+	if err := f.storage.track(f.StorageFile, f.path, unsyncedChange{offset: size}); err != nil {
+		return err
+	}
+	return f.StorageFile.Truncate(size)
+}
+
+func (f *faultFile) Sync() error {
+	if f.storage.Crashed() {
+		return ErrInjected
+	}
+	if err := f.StorageFile.Sync(); err != nil {
+		return err
+	}
+	f.storage.mu.Lock()
+	delete(f.storage.unsynced, f.path)
+	f.storage.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,362 @@
+package core
+
+import (
+	"BynxDB/core/utils"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+/*
+* Foreign keys. A column of a child table references the primary key of a parent table. Inserts and updates of the
+* child have to point at an existing parent row, and deleting a parent row that is still referenced does what the
+* foreign key's OnDelete says:
+*
+*	FK_RESTRICT  the delete fails
+*	FK_CASCADE   the referencing child rows are deleted too
+*	FK_SET_NULL  the referencing column is set to its zero value (0 or empty)
+*
+* BynxDB has no NULL, so for FK_SET_NULL keys the zero value of the column stands for "no parent" and is never
+* checked against the parent table. The parent's TableDef lists the tables referencing it in ReferencedBy, so a delete
+* only looks at the tables that can hold references.
+ */
+
+type FKAction uint16
+
+const (
+	FK_RESTRICT FKAction = iota
+	FK_CASCADE
+	FK_SET_NULL
+)
+
+var fkActionNames = map[FKAction]string{FK_RESTRICT: "RESTRICT", FK_CASCADE: "CASCADE", FK_SET_NULL: "SET NULL"}
+
+func (a FKAction) String() string {
+	return fkActionNames[a]
+}
+
+// * ForeignKey references the primary key of RefTable from the column Col.
+type ForeignKey struct {
+	Col      string
+	RefTable string
+	OnDelete FKAction
+}
+
+// * Tables opened by DbInit, by storage and name, so a foreign key reaches the instance already open instead of
+// * opening the files a second time.
+var openTables = struct {
+	sync.Mutex
+	dbs map[tableKey]*DB
+}{dbs: map[tableKey]*DB{}}
+
+type tableKey struct {
+	storage Storage
+	name    string
+}
+
+func (db *DB) key() tableKey {
+	return tableKey{storage: db.opts.storage(), name: db.name()}
+}
+
+func registerTable(db *DB) {
+	openTables.Lock()
+	defer openTables.Unlock()
+	openTables.dbs[db.key()] = db
+}
+
+func unregisterTable(db *DB) {
+	openTables.Lock()
+	defer openTables.Unlock()
+	if openTables.dbs[db.key()] == db {
+		delete(openTables.dbs, db.key())
+	}
+}
+
+// * table returns an open table of the storage of opts, opening it with opts when no instance is open. done closes
+// * what table opened.
+func table(name string, opts *Options) (db *DB, done func(), err error) {
+	openTables.Lock()
+	db, ok := openTables.dbs[tableKey{storage: opts.storage(), name: strings.ToUpper(name)}]
+	openTables.Unlock()
+	if ok {
+		return db, func() {}, nil
+	}
+	if db, err = OpenDBWithOptions(name, opts); err != nil {
+		return nil, nil, err
+	}
+	return db, db.Close, nil
+}
+
+// * checkForeignKeyDefs validates the foreign keys of a table about to be created and normalizes their names.
+// * A table may reference itself.
+func checkForeignKeyDefs(name string, tD *TableDef, opts *Options) error {
+	for i := range tD.ForeignKeys {
+		fk := &tD.ForeignKeys[i]
+		fk.Col, fk.RefTable = strings.ToUpper(fk.Col), strings.ToUpper(fk.RefTable)
+		colIndex, err := tD.ColIndex(fk.Col)
+		if err != nil {
+			return err
+		}
+		if _, ok := fkActionNames[fk.OnDelete]; !ok {
+			return errors.New("[error] invalid ON DELETE action for foreign key: " + fk.Col)
+		}
+		if colIndex == tD.PKeyIndex && fk.OnDelete == FK_SET_NULL {
+			return errors.New("[error] SET NULL can't be used on the primary key: " + fk.Col)
+		}
+		refType := tD.Types[tD.PKeyIndex]
+		if fk.RefTable != name {
+			parent, done, err := table(fk.RefTable, opts)
+			if err != nil {
+				return err
+			}
+			refType = parent.records.Types[0]
+			_, err = withReference(parent.records, name)
+			done()
+			if err != nil {
+				return fmt.Errorf("[error] %s can't list another referencing table: %w", fk.RefTable, err)
+			}
+		} else if !slices.Contains(tD.ReferencedBy, name) {
+			// * The table is created listing itself, its definition has to fit with it
+			tD.ReferencedBy = append(tD.ReferencedBy, name)
+		}
+		if tD.Types[colIndex] != refType {
+			return errors.New("[error] foreign key " + fk.Col + " doesn't have the type of the primary key of " + fk.RefTable)
+		}
+	}
+	return nil
+}
+
+// * withReference returns the ReferencedBy list of the table of records with name added, nil when it lists name
+// * already. The definition has to fit its page with it.
+func withReference(records *Collection, name string) ([]string, error) {
+	if slices.Contains(records.ReferencedBy, name) {
+		return nil, nil
+	}
+	tD := *records.TableDef
+	tD.ReferencedBy = append(slices.Clip(tD.ReferencedBy), name)
+	if err := tD.checkFits(records.DAL.pageSize); err != nil {
+		return nil, err
+	}
+	return tD.ReferencedBy, nil
+}
+
+// * linkParents records a new table in the ReferencedBy list of every table it references.
+func (db *DB) linkParents() error {
+	name := db.name()
+	for _, fk := range db.records.ForeignKeys {
+		parent, done, err := table(fk.RefTable, db.opts)
+		if err != nil {
+			return err
+		}
+		refs, err := withReference(parent.records, name)
+		if err == nil && refs != nil {
+			parent.records.ReferencedBy = refs
+			err = parent.records.writeTableDef()
+		}
+		done()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// * unlinkParents takes a table about to be dropped out of the ReferencedBy list of the tables it references.
+func (db *DB) unlinkParents() error {
+	name := db.name()
+	for _, fk := range db.records.ForeignKeys {
+		if fk.RefTable == name {
+			continue
+		}
+		parent, done, err := table(fk.RefTable, db.opts)
+		if err != nil {
+			return err
+		}
+		tD := parent.records.TableDef
+		refs := tD.ReferencedBy[:0]
+		for _, ref := range tD.ReferencedBy {
+			if ref != name {
+				refs = append(refs, ref)
+			}
+		}
+		tD.ReferencedBy = refs
+		err = parent.records.writeTableDef()
+		done()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// * checkForeignKeys checks that the foreign key values of a positional row reference existing parent rows. When rows
+// * are written together a self referencing row may point at another row of the same write: pending is asked first
+// * with the encoded key and accepts the ones it vouches for. It is nil for single rows.
+func (db *DB) checkForeignKeys(row []any, pending func(key []byte, val any) bool) error {
+	tD := db.records.TableDef
+	for _, fk := range tD.ForeignKeys {
+		colIndex, _ := tD.ColIndex(fk.Col)
+		val := row[colIndex]
+		if fk.OnDelete == FK_SET_NULL && isZeroValue(val) {
+			continue
+		}
+		// * A row of a self referencing table may reference itself
+		if fk.RefTable == db.name() {
+			if compareValues(val, row[0]) == 0 {
+				continue
+			}
+			if key, err := encodeKey(tD, 0, val); err == nil && pending != nil && pending(key, val) {
+				continue
+			}
+		}
+		parent, done, err := table(fk.RefTable, db.opts)
+		if err != nil {
+			return err
+		}
+		_, err = parent.PKeyQuery(val)
+		done()
+		if errors.Is(err, ErrNotFound) {
+			return errors.New("[error] foreign key violation: " + fk.Col + " = " + formatValue(val) + " has no row in " + fk.RefTable)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// * childRef is a foreign key of another table pointing at this one.
+type childRef struct {
+	child *DB
+	fk    ForeignKey
+}
+
+// * childRefs returns the foreign keys referencing the table. done closes the child tables it had to open.
+func (db *DB) childRefs() (refs []childRef, done func(), err error) {
+	var closers []func()
+	done = func() {
+		for _, closeChild := range closers {
+			closeChild()
+		}
+	}
+	for _, name := range db.records.ReferencedBy {
+		child, closeChild, err := table(name, db.opts)
+		if err != nil {
+			done()
+			return nil, nil, err
+		}
+		closers = append(closers, closeChild)
+		for _, fk := range child.records.ForeignKeys {
+			if fk.RefTable == db.name() {
+				refs = append(refs, childRef{child: child, fk: fk})
+			}
+		}
+	}
+	return refs, done, nil
+}
+
+// * referencing returns the rows of ref.child pointing at pKey. A self referencing row doesn't count.
+func (db *DB) referencing(ref childRef, pKey any) ([][]any, error) {
+	rows, err := ref.child.Query(Eq(ref.fk.Col, pKey))
+	if err != nil || ref.child != db {
+		return rows, err
+	}
+	var others [][]any
+	for _, row := range rows {
+		if compareValues(row[0], pKey) != 0 {
+			others = append(others, row)
+		}
+	}
+	return others, nil
+}
+
+// * onParentDelete applies the ON DELETE action of every foreign key referencing the row with primary key pKey.
+// * All RESTRICT keys are checked before any child row is changed.
+func (db *DB) onParentDelete(pKey any) error {
+	if len(db.records.ReferencedBy) == 0 {
+		return nil
+	}
+	// * Cascades around a cycle of tables come back to the row being deleted, which is left to the first delete
+	key := formatValue(pKey)
+	if db.deleting[key] {
+		return nil
+	}
+	if db.deleting == nil {
+		db.deleting = map[string]bool{}
+	}
+	db.deleting[key] = true
+	defer delete(db.deleting, key)
+	refs, done, err := db.childRefs()
+	if err != nil {
+		return err
+	}
+	defer done()
+	children := make([][][]any, len(refs))
+	for i, ref := range refs {
+		if children[i], err = db.referencing(ref, pKey); err != nil {
+			return err
+		}
+		if len(children[i]) != 0 && ref.fk.OnDelete == FK_RESTRICT {
+			return errors.New("[error] foreign key violation: " + formatValue(pKey) + " is referenced by " +
+				ref.child.name() + "." + ref.fk.Col)
+		}
+	}
+	for i, ref := range refs {
+		for _, row := range children[i] {
+			switch ref.fk.OnDelete {
+			case FK_CASCADE:
+				utils.Info(2, "Cascading delete to ", ref.child.name(), row[0])
+				err = ref.child.Delete(0, row[0])
+			case FK_SET_NULL:
+				colIndex, _ := ref.child.records.ColIndex(ref.fk.Col)
+				err = ref.child.Update(row[0], map[string]any{ref.fk.Col: zeroValue(ref.child.records.Types[colIndex])})
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// * checkParentKeyChange refuses to change the primary key of a row that is still referenced.
+func (db *DB) checkParentKeyChange(pKey any) error {
+	if len(db.records.ReferencedBy) == 0 {
+		return nil
+	}
+	refs, done, err := db.childRefs()
+	if err != nil {
+		return err
+	}
+	defer done()
+	for _, ref := range refs {
+		rows, err := db.referencing(ref, pKey)
+		if err != nil {
+			return err
+		}
+		if len(rows) != 0 {
+			return errors.New("[error] foreign key violation: can't change primary key " + formatValue(pKey) +
+				", it is referenced by " + ref.child.name() + "." + ref.fk.Col)
+		}
+	}
+	return nil
+}
+
+func zeroValue(typ uint16) any {
+	if typ == TYPE_BYTE {
+		return []byte{}
+	}
+	return 0
+}
+
+func isZeroValue(val any) bool {
+	switch data := val.(type) {
+	case int:
+		return data == 0
+	case []byte:
+		return len(data) == 0
+	}
+	return false
+}
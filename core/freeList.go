@@ -35,39 +35,109 @@ func (fL *freeList) ReleasedPage(pageNum pgNum) {
 	fL.releasedPages = append(fL.releasedPages, pageNum)
 }
 
-func (fL *freeList) serialize(buf []byte) []byte {
-	pos := 0
-
-	binary.LittleEndian.PutUint16(buf[pos:], uint16((fL.maxPage)))
+/*
+* The freelist is written to a chain of pages, each one laid out as
+*
+*	| max page u64 | released page count u16 | released pages u64... | ... | next page u64 |
+*
+* the next page in the last bytes of the page, 0 ending the chain. The first page is the freelist page of the meta
+* page. The released pages that don't fit it go to the next pages of the chain, which are taken from the front of the
+* list itself, the last ones to be handed out again. They stay on the list: the freelist is written again by every
+* write, after the pages it hands out. Files of format version 1 and older hold the max page in a u16, which wraps
+* once the file grows past 65535 pages; migrations read them with the version they were written in.
+ */
+
+// * unwrapMaxPage returns the max page of a file of pages pages whose freelist recorded it in a u16, which wraps past
+// * 65535 pages. The file holds every page up to the max page, written or released.
+func unwrapMaxPage(recorded pgNum, pages pgNum) pgNum {
+	for recorded < pages {
+		recorded += 1 << 16
+	}
+	return recorded
+}
 
-	pos += 2
+// * freelistHeaderSize returns the size of the max page and released page count of a freelist page of version.
+func freelistHeaderSize(version uint16) int {
+	if version < 2 {
+		return 4
+	}
+	return pageNumSize + 2
+}
 
-	// * Released page count
-	binary.LittleEndian.PutUint16(buf[pos:], uint16(len(fL.releasedPages)))
-	pos += 2
+// * freelistCapacity returns the number of released pages a freelist page of version with dataSize bytes of data holds.
+func freelistCapacity(dataSize int, version uint16) int {
+	return (dataSize - freelistHeaderSize(version) - pageNumSize) / pageNumSize
+}
 
-	for _, page := range fL.releasedPages {
-		binary.LittleEndian.PutUint64(buf[pos:], uint64(page))
-		pos += pageNumSize
+// * overflowPages returns the pages of the chain after the first one, for pages of version with dataSize bytes of data.
+func (fL *freeList) overflowPages(dataSize int, version uint16) []pgNum {
+	perPage := freelistCapacity(dataSize, version)
+	n := 0
+	for len(fL.releasedPages) > perPage*(n+1) {
+		n++
 	}
+	return fL.releasedPages[:n]
+}
 
-	return buf
-
+// * serialize writes the freelist to the data of the pages of its chain in version, bufs[i+1] being the page next[i].
+func (fL *freeList) serialize(bufs [][]byte, next []pgNum, version uint16) {
+	releasedPages := fL.releasedPages
+	for i, buf := range bufs {
+		pos := 0
+		if version < 2 {
+			binary.LittleEndian.PutUint16(buf[pos:], uint16(fL.maxPage))
+			pos += 2
+		} else {
+			binary.LittleEndian.PutUint64(buf[pos:], uint64(fL.maxPage))
+			pos += pageNumSize
+		}
+
+		count := min(len(releasedPages), freelistCapacity(len(buf), version))
+		// * Released page count
+		binary.LittleEndian.PutUint16(buf[pos:], uint16(count))
+		pos += 2
+
+		for _, page := range releasedPages[:count] {
+			binary.LittleEndian.PutUint64(buf[pos:], uint64(page))
+			pos += pageNumSize
+		}
+		releasedPages = releasedPages[count:]
+
+		nextPage := pgNum(0)
+		if i < len(next) {
+			nextPage = next[i]
+		}
+		binary.LittleEndian.PutUint64(buf[len(buf)-pageNumSize:], uint64(nextPage))
+	}
 }
 
-func (fL *freeList) deserialize(buf []byte) {
+// * deserialize adds the released pages of one page of the chain, written in version, and returns the next one, 0 for
+// * the last page. Freelists written before the chain hold zeros where the next page goes, or released pages when the
+// * page is full.
+func (fL *freeList) deserialize(buf []byte, version uint16) (pgNum, error) {
 	pos := 0
-	fL.maxPage = pgNum(binary.LittleEndian.Uint16(buf[pos:]))
-	pos += 2
+	if version < 2 {
+		fL.maxPage = pgNum(binary.LittleEndian.Uint16(buf[pos:]))
+		pos += 2
+	} else {
+		fL.maxPage = pgNum(binary.LittleEndian.Uint64(buf[pos:]))
+		pos += pageNumSize
+	}
 
 	releasedPageCount := int(binary.LittleEndian.Uint16(buf[pos:]))
 	pos += 2
+	if pos+releasedPageCount*pageNumSize > len(buf) {
+		return 0, fmt.Errorf("%d released pages don't fit the page", releasedPageCount)
+	}
 
 	for i := 0; i < releasedPageCount; i++ {
 		fL.releasedPages = append(fL.releasedPages, pgNum(binary.LittleEndian.Uint64(buf[pos:])))
 		pos += pageNumSize
 	}
-
+	if pos > len(buf)-pageNumSize {
+		return 0, nil
+	}
+	return pgNum(binary.LittleEndian.Uint64(buf[len(buf)-pageNumSize:])), nil
 }
 
 func (fl *freeList) State() (ret string) {
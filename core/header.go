@@ -0,0 +1,93 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+* The meta page of every file starts with the file header, ahead of the fields of Meta:
+*
+*	| magic (8 bytes) | format version u16 | reserved u16 | page size u32 |
+*
+* The page size is the one the file was created with. It is read before anything else and used for the file from then
+* on, so a file moves between machines whatever their page size. The format version changes with the layout of any
+* page; files of older versions are upgraded by Migrate, files of newer ones are refused.
+ */
+
+const (
+	FILE_MAGIC     = "BYNXFILE"
+	FORMAT_VERSION = 2
+	MIN_PAGE_SIZE  = 1 << 10
+	// * Offsets in a node are 16 bits wide
+	MAX_PAGE_SIZE  = 1 << 16
+	fileHeaderSize = 16
+)
+
+// * ErrIncompatible is matched by the errors returned for files that aren't BynxDB files this version can open.
+var ErrIncompatible = errors.New("[error] incompatible database file")
+
+type fileHeader struct {
+	version  uint16
+	pageSize int
+}
+
+func (h *fileHeader) serialize(buf []byte) {
+	copy(buf, FILE_MAGIC)
+	binary.LittleEndian.PutUint16(buf[8:], h.version)
+	binary.LittleEndian.PutUint32(buf[12:], uint32(h.pageSize))
+}
+
+// * deserialize reads a header, failing for data that doesn't start with the magic.
+func (h *fileHeader) deserialize(buf []byte) error {
+	if len(buf) < fileHeaderSize || string(buf[:len(FILE_MAGIC)]) != FILE_MAGIC {
+		return errors.New("not a BynxDB file, or one written before format version 1")
+	}
+	h.version = binary.LittleEndian.Uint16(buf[8:])
+	h.pageSize = int(binary.LittleEndian.Uint32(buf[12:]))
+	return nil
+}
+
+// * check tells whether this version can open a file with the header.
+func (h *fileHeader) check() error {
+	switch {
+	case h.version > FORMAT_VERSION:
+		return fmt.Errorf("format version %d is newer than %d, the latest this version reads", h.version, FORMAT_VERSION)
+	case h.version < FORMAT_VERSION:
+		return fmt.Errorf("format version %d is outdated, migrate the file to version %d", h.version, FORMAT_VERSION)
+	case !validPageSize(h.pageSize):
+		return fmt.Errorf("unsupported page size %d", h.pageSize)
+	}
+	return nil
+}
+
+func validPageSize(size int) bool {
+	return size >= MIN_PAGE_SIZE && size <= MAX_PAGE_SIZE && size&(size-1) == 0
+}
+
+// * readFileHeader reads the header of an existing file straight from the meta page, as its page size isn't known yet.
+func readFileHeader(file io.ReaderAt, path string) (*fileHeader, error) {
+	h, err := peekFileHeader(file)
+	if err == nil {
+		err = h.check()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrIncompatible, path, err)
+	}
+	return h, nil
+}
+
+// * peekFileHeader reads the header of a file without checking that it can be opened.
+func peekFileHeader(file io.ReaderAt) (*fileHeader, error) {
+	buf := make([]byte, pageHeaderSize+fileHeaderSize)
+	if _, err := file.ReadAt(buf, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	h := &fileHeader{}
+	if err := h.deserialize(buf[pageHeaderSize:]); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
@@ -0,0 +1,27 @@
+package core
+
+import "io"
+
+// * RowIterator streams positional rows one at a time. Next returns io.EOF once the rows are exhausted.
+type RowIterator interface {
+	Next() ([]any, error)
+}
+
+type sliceIterator struct {
+	rows [][]any
+	pos  int
+}
+
+// * SliceIterator returns a RowIterator over rows that are already in memory.
+func SliceIterator(rows [][]any) RowIterator {
+	return &sliceIterator{rows: rows}
+}
+
+func (it *sliceIterator) Next() ([]any, error) {
+	if it.pos >= len(it.rows) {
+		return nil, io.EOF
+	}
+	row := it.rows[it.pos]
+	it.pos++
+	return row, nil
+}
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+/*
+* Joins. The rows of an outer RowIterator are matched with the rows of an inner table on outer[outerCol] = innerCol.
+* The joined row is the outer row followed by the inner row, so joins chain by using one join as the outer side of
+* the next. Two strategies:
+*
+*	INDEX NESTED LOOP  the inner column is the primary key or a unique column, one tree search per outer row
+*	HASH JOIN          any other inner column, the inner table is read once into a hash table probed per outer row
+*
+* A left join keeps outer rows without a match, with nil for every inner column.
+ */
+
+type JoinType int
+
+const (
+	JOIN_INNER JoinType = iota
+	JOIN_LEFT
+)
+
+type JoinStrategy int
+
+const (
+	JOIN_INDEX_NESTED_LOOP JoinStrategy = iota
+	JOIN_HASH
+)
+
+var joinStrategyNames = map[JoinStrategy]string{
+	JOIN_INDEX_NESTED_LOOP: "INDEX NESTED LOOP JOIN",
+	JOIN_HASH:              "HASH JOIN",
+}
+
+func (s JoinStrategy) String() string {
+	return joinStrategyNames[s]
+}
+
+// * JoinIterator is a RowIterator over joined rows.
+type JoinIterator struct {
+	Type     JoinType
+	Strategy JoinStrategy
+
+	outer    RowIterator
+	outerCol int
+	inner    *DB
+	innerCol int
+	// * Rows of the inner table by encoded join value, built on the first Next of a hash join
+	hash    map[string][][]any
+	built   bool
+	pending [][]any
+}
+
+// * Join joins outer with the rows of the db table whose innerCol equals the value at outerCol of the outer row.
+func (db *DB) Join(outer RowIterator, outerCol int, innerCol string, joinType JoinType) (*JoinIterator, error) {
+	tD := db.records.TableDef
+	colIndex, err := tD.ColIndex(innerCol)
+	if err != nil {
+		return nil, err
+	}
+	if outerCol < 0 {
+		return nil, errors.New("[error] invalid outer join column")
+	}
+	j := &JoinIterator{
+		Type:     joinType,
+		Strategy: JOIN_HASH,
+		outer:    outer,
+		outerCol: outerCol,
+		inner:    db,
+		innerCol: colIndex,
+	}
+	if isKeyCol(tD, colIndex) {
+		j.Strategy = JOIN_INDEX_NESTED_LOOP
+	}
+	return j, nil
+}
+
+// * Explain describes the join strategy.
+func (j *JoinIterator) Explain() string {
+	explain := j.Strategy.String()
+	if j.Type == JOIN_LEFT {
+		explain = "LEFT " + explain
+	}
+	return explain + " on " + j.inner.name() + "." + j.inner.records.Cols[j.innerCol]
+}
+
+func (j *JoinIterator) Next() ([]any, error) {
+	for len(j.pending) == 0 {
+		outerRow, err := j.outer.Next()
+		if err != nil {
+			return nil, err
+		}
+		if j.outerCol >= len(outerRow) {
+			return nil, errors.New("[error] outer join column out of range")
+		}
+		matches, err := j.matches(outerRow[j.outerCol])
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 && j.Type == JOIN_LEFT {
+			matches = [][]any{make([]any, len(j.inner.records.Cols))}
+		}
+		for _, innerRow := range matches {
+			j.pending = append(j.pending, append(append([]any{}, outerRow...), innerRow...))
+		}
+	}
+	row := j.pending[0]
+	j.pending = j.pending[1:]
+	return row, nil
+}
+
+// * matches returns the inner rows joining val. A nil outer value, from an unmatched left join, matches nothing.
+func (j *JoinIterator) matches(val any) ([][]any, error) {
+	tD := j.inner.records.TableDef
+	if val == nil {
+		return nil, nil
+	}
+	if err := checkType(tD, j.innerCol, val); err != nil {
+		return nil, err
+	}
+	if j.Strategy == JOIN_INDEX_NESTED_LOOP {
+		plan, err := j.inner.Plan(Eq(tD.Cols[j.innerCol], val))
+		if err != nil {
+			return nil, err
+		}
+		return plan.Rows()
+	}
+	if !j.built {
+		if err := j.build(); err != nil {
+			return nil, err
+		}
+	}
+	key, _ := checkTypeAndEncodeByte(tD, j.innerCol, val, nil)
+	return j.hash[string(key)], nil
+}
+
+func (j *JoinIterator) build() error {
+	tD := j.inner.records.TableDef
+	j.hash = map[string][][]any{}
+	plan, err := j.inner.Plan()
+	if err != nil {
+		return err
+	}
+	err = plan.Each(func(row []any) (bool, error) {
+		key, _ := checkTypeAndEncodeByte(tD, j.innerCol, row[j.innerCol], nil)
+		j.hash[string(key)] = append(j.hash[string(key)], row)
+		return true, nil
+	})
+	j.built = err == nil
+	return err
+}
+
+// * Rows drains the join.
+func (j *JoinIterator) Rows() ([][]any, error) {
+	var rows [][]any
+	for {
+		row, err := j.Next()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// * JoinColumns names the columns of joined rows, each prefixed with its table: DEPT.ID.
+func JoinColumns(outer []string, inner *DB) []string {
+	cols := append([]string{}, outer...)
+	for _, col := range inner.records.Cols {
+		cols = append(cols, inner.name()+"."+col)
+	}
+	return cols
+}
+
+// * name is the table name, the records collection without its suffix.
+func (db *DB) name() string {
+	return strings.TrimSuffix(string(db.records.Name), "rec")
+}
@@ -0,0 +1,465 @@
+package core
+
+import (
+	"BynxDB/core/utils"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+)
+
+/*
+* The journal makes every write to a table all or nothing. Before a page of one of the files of the table is
+* overwritten for the first time during a write, it is copied to the journal, <NAME>.journal next to the files, along
+* with the size the file had. Once the write is done the meta page and freelist of every file it changed are brought up
+* to date and the journal is removed, which is the moment the write is made. A write returning an error is rolled back
+* right away: the pages are copied back, the files cut to their sizes and the meta page and freelist read again. A
+* table opened with a journal left behind, by a process that died or a roll back that failed, is rolled back first.
+*
+* Records are appended to the journal one at a time:
+*
+*	| crc32 u32 | kind u8 | file name length u16 | file name | size or offset u64 | data length u32 | data |
+*
+* The crc32 (Castagnoli) covers the rest of the record. A record torn by a crash is dropped along with what follows,
+* the page it was saving was never overwritten. So that it covers the machine losing power as well as the process
+* dying, the journal is synced before a page it saved is overwritten, and the files changed are synced before the
+* journal is removed. A write is atomic within a table, a cascade into another table is made on its own.
+ */
+
+const (
+	JOURNAL_FILE byte = iota + 1
+	JOURNAL_PAGE
+)
+
+const (
+	journalSuffix     = ".journal"
+	journalRecordSize = 4 + 1 + 2 + 8 + 4
+)
+
+type journal struct {
+	storage Storage
+	path    string
+	// * names holds the file name of every DAL of the table
+	names map[*DAL]string
+	// * active is set while a write is under way, pages are only saved then
+	active bool
+	file   StorageFile
+	offset int64
+	// * synced is set once the records appended so far reached the disk
+	synced bool
+	// * The files changed by the write, in the order they were first changed
+	files []*journaledFile
+	// * err is the write that failed. The change can't be finished and is rolled back when the table is opened again.
+	err error
+}
+
+type journaledFile struct {
+	dal   *DAL
+	size  int64
+	saved map[pgNum]bool
+	// * truncate is the size the file is cut to once the write is made, -1 to leave it
+	truncate int64
+}
+
+type journalRecord struct {
+	kind byte
+	name string
+	num  uint64
+	data []byte
+}
+
+func journalPath(dir string, name string) string {
+	return filepath.Join(dir, name+journalSuffix)
+}
+
+func newJournal(storage Storage, path string) *journal {
+	return &journal{storage: storage, path: path, names: map[*DAL]string{}}
+}
+
+// * add has the journal save the pages of the DAL, the file at path.
+func (j *journal) add(d *DAL, path string) {
+	j.names[d] = filepath.Base(path)
+	d.journal = j
+}
+
+func (j *journal) begin() {
+	if j != nil {
+		j.active = true
+	}
+}
+
+// * end makes the write under way, or rolls it back when it failed with err.
+func (j *journal) end(err error) error {
+	if j == nil {
+		return nil
+	}
+	defer func() { j.active = false }()
+	if err != nil && j.err == nil {
+		return j.rollback()
+	}
+	return j.commit()
+}
+
+func (j *journal) fail(err error) error {
+	if j != nil && j.err == nil {
+		j.err = err
+	}
+	return err
+}
+
+func (j *journal) failed() error {
+	return fmt.Errorf("[error] an earlier write to the table failed, open it again to roll it back: %w", j.err)
+}
+
+// * touch notes the size of the file of the DAL the first time the write changes it.
+func (j *journal) touch(d *DAL) (*journaledFile, error) {
+	for _, f := range j.files {
+		if f.dal == d {
+			return f, nil
+		}
+	}
+	size, err := d.file.Size()
+	if err != nil {
+		return nil, j.fail(err)
+	}
+	if err := j.append(journalRecord{kind: JOURNAL_FILE, name: j.names[d], num: uint64(size)}); err != nil {
+		return nil, err
+	}
+	f := &journaledFile{dal: d, size: size, saved: map[pgNum]bool{}, truncate: -1}
+	j.files = append(j.files, f)
+	return f, nil
+}
+
+// * save copies a page of the DAL to the journal before it is overwritten. Pages past the end of the file when the
+// * write started go away with the file being cut, they aren't saved. Once a write failed nothing is written anymore.
+func (j *journal) save(d *DAL, pageNum pgNum) error {
+	if j == nil {
+		return nil
+	}
+	if j.err != nil {
+		return j.failed()
+	}
+	if !j.active {
+		return nil
+	}
+	f, err := j.touch(d)
+	if err != nil {
+		return err
+	}
+	offset := int64(pageNum) * int64(d.pageSize)
+	if f.saved[pageNum] || offset >= f.size {
+		return j.sync()
+	}
+	buf := make([]byte, d.pageSize)
+	n, err := d.file.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return j.fail(err)
+	}
+	if err := j.append(journalRecord{kind: JOURNAL_PAGE, name: j.names[d], num: uint64(offset), data: buf[:n]}); err != nil {
+		return err
+	}
+	f.saved[pageNum] = true
+	return j.sync()
+}
+
+// * sync makes the records appended so far reach the disk, ahead of the page about to be overwritten: a page past the
+// * end of the file needs the size it had, any other one the copy saved.
+func (j *journal) sync() error {
+	if j.synced {
+		return nil
+	}
+	if err := j.file.Sync(); err != nil {
+		return j.fail(err)
+	}
+	j.synced = true
+	return nil
+}
+
+// * truncateLater cuts the file of the DAL once the write is made, the pages past size are kept until then for a roll
+// * back. Returns false when no write is under way and the file has to be cut now.
+func (j *journal) truncateLater(d *DAL, size int64) (bool, error) {
+	if j == nil {
+		return false, nil
+	}
+	if j.err != nil {
+		return true, j.failed()
+	}
+	if !j.active {
+		return false, nil
+	}
+	f, err := j.touch(d)
+	if err != nil {
+		return true, err
+	}
+	f.truncate = size
+	return true, nil
+}
+
+func (j *journal) append(r journalRecord) error {
+	if j.file == nil {
+		file, err := j.storage.Open(j.path, false)
+		if err != nil {
+			return j.fail(err)
+		}
+		j.file, j.offset = file, 0
+		// * Records of an older journal past the new ones would be rolled back too
+		if err := file.Truncate(0); err != nil {
+			return j.fail(err)
+		}
+	}
+	buf := make([]byte, journalRecordSize+len(r.name)+len(r.data))
+	pos := 4
+	buf[pos] = r.kind
+	pos++
+	binary.LittleEndian.PutUint16(buf[pos:], uint16(len(r.name)))
+	pos += 2
+	pos += copy(buf[pos:], r.name)
+	binary.LittleEndian.PutUint64(buf[pos:], r.num)
+	pos += 8
+	binary.LittleEndian.PutUint32(buf[pos:], uint32(len(r.data)))
+	pos += 4
+	copy(buf[pos:], r.data)
+	binary.LittleEndian.PutUint32(buf, crc32.Checksum(buf[4:], crcTable))
+	if _, err := j.file.WriteAt(buf, j.offset); err != nil {
+		return j.fail(err)
+	}
+	j.offset += int64(len(buf))
+	j.synced = false
+	return nil
+}
+
+// * commit makes the write done so far: the meta page and freelist of every file changed are written and synced, and
+// * the journal is removed. The write may go on, its next page starts a new journal.
+func (j *journal) commit() error {
+	if j == nil {
+		return nil
+	}
+	if j.err != nil {
+		return j.failed()
+	}
+	if len(j.files) == 0 {
+		return nil
+	}
+	for i := 0; i < len(j.files); i++ {
+		if err := j.files[i].dal.commit(); err != nil {
+			return j.fail(err)
+		}
+	}
+	if err := j.syncFiles(); err != nil {
+		return err
+	}
+	j.file.Close()
+	j.file = nil
+	if err := j.storage.Remove(j.path); err != nil {
+		return j.fail(err)
+	}
+	files := j.files
+	j.files = nil
+	// * The write is made, a file that isn't cut only keeps pages nothing points to. Pages handed out after the file
+	// * was to be cut are kept.
+	for _, f := range files {
+		if f.truncate >= 0 {
+			size := max(f.truncate, int64(f.dal.maxPage)*int64(f.dal.pageSize))
+			err := f.dal.file.Truncate(size)
+			if err == nil {
+				err = f.dal.file.Sync()
+			}
+			if err != nil {
+				utils.Warn("Could not cut ", j.names[f.dal], ": ", err)
+			}
+		}
+	}
+	return nil
+}
+
+// * syncFiles makes the files changed by the write reach the disk, before the journal that could undo them is removed.
+func (j *journal) syncFiles() error {
+	for _, f := range j.files {
+		if err := f.dal.file.Sync(); err != nil {
+			return j.fail(err)
+		}
+	}
+	return nil
+}
+
+// * rollback undoes the write under way after it failed: the pages saved are copied back, the files cut to their sizes
+// * and the meta page and freelist of every file changed read again. The journal is removed once all of it is done,
+// * a roll back that fails leaves it for the table to be rolled back when it is opened again.
+func (j *journal) rollback() error {
+	if len(j.files) == 0 {
+		return nil
+	}
+	buf := make([]byte, j.offset)
+	if _, err := j.file.ReadAt(buf, 0); err != nil && !errors.Is(err, io.EOF) {
+		return j.fail(err)
+	}
+	dals := map[string]*DAL{}
+	for d, name := range j.names {
+		dals[name] = d
+	}
+	records := readJournal(buf)
+	for _, r := range records {
+		if r.kind == JOURNAL_PAGE {
+			if err := dals[r.name].restorePage(r.data, int64(r.num)); err != nil {
+				return j.fail(err)
+			}
+		}
+	}
+	for _, f := range j.files {
+		d := f.dal
+		if err := d.file.Truncate(f.size); err != nil {
+			return j.fail(err)
+		}
+		meta, err := d.Readmeta()
+		if err != nil {
+			return j.fail(err)
+		}
+		d.Meta = meta
+		freeList, err := d.Readfreelist()
+		if err != nil {
+			return j.fail(err)
+		}
+		d.freeList, d.batching = freeList, false
+	}
+	if err := j.syncFiles(); err != nil {
+		return err
+	}
+	j.file.Close()
+	j.file = nil
+	j.files = nil
+	if err := j.storage.Remove(j.path); err != nil {
+		return j.fail(err)
+	}
+	utils.Info(1, "Rolled back ", len(records), " journal records of a failed write: ", j.path)
+	return nil
+}
+
+// * close lets go of the journal file of a write that failed, it stays for the roll back.
+func (j *journal) close() {
+	if j != nil && j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+}
+
+// * readJournal returns the records of a journal up to the first one torn or damaged.
+func readJournal(buf []byte) []journalRecord {
+	var records []journalRecord
+	for pos := 0; len(buf)-pos >= journalRecordSize; {
+		rec := buf[pos:]
+		nameLen := int(binary.LittleEndian.Uint16(rec[5:]))
+		if len(rec) < journalRecordSize+nameLen {
+			break
+		}
+		dataLen := int(binary.LittleEndian.Uint32(rec[7+nameLen+8:]))
+		size := journalRecordSize + nameLen + dataLen
+		if dataLen > len(rec) || len(rec) < size || crc32.Checksum(rec[4:size], crcTable) != binary.LittleEndian.Uint32(rec) {
+			break
+		}
+		records = append(records, journalRecord{
+			kind: rec[4],
+			name: string(rec[7 : 7+nameLen]),
+			num:  binary.LittleEndian.Uint64(rec[7+nameLen:]),
+			data: rec[7+nameLen+12 : size],
+		})
+		pos += size
+	}
+	return records
+}
+
+// * recoverJournal rolls back the write the journal at path was left behind by. The files it names are locked for the
+// * roll back, the first one locked by the caller already.
+func recoverJournal(storage Storage, path string, opts *Options) error {
+	exists, err := storage.Exists(path)
+	if err != nil || !exists {
+		return err
+	}
+	file, err := storage.Open(path, true)
+	if err != nil {
+		return err
+	}
+	size, err := file.Size()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	buf := make([]byte, size)
+	_, err = file.ReadAt(buf, 0)
+	file.Close()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	records := readJournal(buf)
+	if opts.ReadOnly {
+		if len(records) != 0 {
+			return errors.New("[error] a write to the table has to be rolled back, it can't be opened read only: " + path)
+		}
+		return nil
+	}
+
+	type rollback struct {
+		file StorageFile
+		size int64
+	}
+	files := map[string]*rollback{}
+	var order []string
+	defer func() {
+		for _, rb := range files {
+			if rb != nil {
+				rb.file.Close()
+			}
+		}
+	}()
+	for _, r := range records {
+		rb, ok := files[r.name]
+		if !ok {
+			filePath := filepath.Join(filepath.Dir(path), r.name)
+			// * A file removed since has nothing to roll back
+			if exists, err := storage.Exists(filePath); err != nil || !exists {
+				if err != nil {
+					return err
+				}
+				files[r.name] = nil
+				continue
+			}
+			unlock, err := storage.Lock(filePath, true, opts.LockTimeout)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			file, err := storage.Open(filePath, false)
+			if err != nil {
+				return err
+			}
+			rb = &rollback{file: file, size: -1}
+			files[r.name] = rb
+			order = append(order, r.name)
+		}
+		if rb == nil {
+			continue
+		}
+		switch r.kind {
+		case JOURNAL_FILE:
+			rb.size = int64(r.num)
+		case JOURNAL_PAGE:
+			if _, err := rb.file.WriteAt(r.data, int64(r.num)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range order {
+		rb := files[name]
+		if rb.size >= 0 {
+			if err := rb.file.Truncate(rb.size); err != nil {
+				return err
+			}
+		}
+		if err := rb.file.Sync(); err != nil {
+			return err
+		}
+	}
+	utils.Info(1, "Rolled back ", len(records), " journal records: ", path)
+	return storage.Remove(path)
+}
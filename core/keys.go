@@ -0,0 +1,81 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// * signBit flips the order of negative and positive integers so their big endian bytes sort like the values
+const signBit = uint64(1) << 63
+
+// * encodeKey encodes a column value as the key of a record or index tree, following the encoding of the table.
+func encodeKey(tD *TableDef, colIndex int, val any) ([]byte, error) {
+	if tD.KeyEncoding == KEY_ENCODING_LEGACY {
+		return checkTypeAndEncodeByte(tD, colIndex, val, []byte{})
+	}
+	if err := checkType(tD, colIndex, val); err != nil {
+		return nil, err
+	}
+	switch data := val.(type) {
+	case int:
+		return binary.BigEndian.AppendUint64(nil, uint64(data)^signBit), nil
+	default:
+		return append([]byte{}, val.([]byte)...), nil
+	}
+}
+
+// * decodeKey is the inverse of encodeKey.
+func decodeKey(tD *TableDef, colIndex int, key []byte) any {
+	if tD.KeyEncoding == KEY_ENCODING_LEGACY {
+		val, _ := checkTypeAndDecodeCol(tD, colIndex, key)
+		return val
+	}
+	switch tD.Types[colIndex] {
+	case TYPE_INT64:
+		return int(binary.BigEndian.Uint64(key) ^ signBit)
+	case TYPE_BYTE:
+		return append([]byte{}, key...)
+	}
+	return nil
+}
+
+// * orderedKeys reports whether the byte order of the keys follows the value order, which range scans rely on.
+func orderedKeys(tD *TableDef) bool {
+	return tD.KeyEncoding == KEY_ENCODING_ORDERED
+}
+
+// * checkType checks that val has the Go type used for the column.
+func checkType(tD *TableDef, colIndex int, val any) error {
+	switch val.(type) {
+	case int:
+		if tD.Types[colIndex] == TYPE_INT64 {
+			return nil
+		}
+	case []byte:
+		if tD.Types[colIndex] == TYPE_BYTE {
+			return nil
+		}
+	default:
+		return errors.New("[Error]:wrong data type passed to function")
+	}
+	return errors.New("[Error]:wrong type for coloumn: " + tD.Cols[colIndex])
+}
+
+// * compareValues orders two values of the same column type, integers numerically and bytes lexicographically.
+func compareValues(a, b any) int {
+	switch x := a.(type) {
+	case int:
+		y := b.(int)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case []byte:
+		return bytes.Compare(x, b.([]byte))
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+* Files are locked with an advisory lock, exclusive for writers and shared for read only openers, so a second process
+* opening a table fails with ErrLocked or waits for Options.LockTimeout. A process locks a file once: a table opened
+* again in the same process, by a foreign key lookup or the SQL engine, shares the lock of the first opener. The lock
+* is held on a descriptor of its own, which stays open while any DAL has the file open.
+ */
+
+// * ErrLocked is matched by the errors returned for files another process has locked.
+var ErrLocked = errors.New("[error] database is locked by another process")
+
+const lockPollInterval = 10 * time.Millisecond
+
+type fileLock struct {
+	file      *os.File
+	refs      int
+	exclusive bool
+}
+
+var fileLocks = struct {
+	sync.Mutex
+	locks map[string]*fileLock
+}{locks: map[string]*fileLock{}}
+
+// * lockFile locks the file at path, waiting up to timeout for another process to let go of it.
+func lockFile(path string, exclusive bool, timeout time.Duration) error {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	fileLocks.Lock()
+	defer fileLocks.Unlock()
+	if l, ok := fileLocks.locks[key]; ok {
+		if exclusive && !l.exclusive {
+			return fmt.Errorf("%w: %s is open read only in this process", ErrLocked, path)
+		}
+		l.refs++
+		return nil
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		if err := waitLock(file, exclusive, deadline); err != nil {
+			file.Close()
+			return fmt.Errorf("%w: %s", err, path)
+		}
+		// * A file renamed over path while waiting, by a compaction or a migration, has to be locked instead
+		if same, err := isOpenAt(file, path); err != nil || same {
+			if err != nil {
+				file.Close()
+				return err
+			}
+			fileLocks.locks[key] = &fileLock{file: file, refs: 1, exclusive: exclusive}
+			return nil
+		}
+		file.Close()
+	}
+}
+
+func waitLock(file *os.File, exclusive bool, deadline time.Time) error {
+	for {
+		locked, err := flock(file, exclusive)
+		if err != nil || locked {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+func isOpenAt(file *os.File, path string) (bool, error) {
+	opened, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	current, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(opened, current), nil
+}
+
+// * unlockFile drops a reference to the lock of the file at path, the last one unlocks it.
+func unlockFile(path string) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	fileLocks.Lock()
+	defer fileLocks.Unlock()
+	l, ok := fileLocks.locks[key]
+	if !ok {
+		return
+	}
+	if l.refs--; l.refs == 0 {
+		l.file.Close()
+		delete(fileLocks.locks, key)
+	}
+}
+
+// * renameLocked moves the file at tmpPath over the locked file at path, locking the new file before it is visible
+// * at path so no other process gets in between.
+func renameLocked(tmpPath string, path string) error {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	fileLocks.Lock()
+	defer fileLocks.Unlock()
+	l, ok := fileLocks.locks[key]
+	if !ok {
+		return os.Rename(tmpPath, path)
+	}
+	file, err := os.Open(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := waitLock(file, l.exclusive, time.Now()); err != nil {
+		file.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		file.Close()
+		return err
+	}
+	l.file.Close()
+	l.file = file
+	return nil
+}
@@ -15,6 +15,10 @@ type Meta struct {
 	freelistPage pgNum
 	TableDefPage pgNum
 	Root         pgNum
+	// * RowCount is the number of items in the tree. Files written before it was kept read as unknown until the first
+	// * count walks the tree.
+	RowCount      uint64
+	rowCountKnown bool
 }
 
 func newMetaPage() *Meta {
@@ -29,6 +33,12 @@ func (m *Meta) Serialize(buf []byte) {
 	pos += pageNumSize
 	binary.LittleEndian.PutUint64(buf[pos:], uint64(m.TableDefPage))
 	pos += pageNumSize
+	// * Stored off by one, zero means unknown
+	var rowCount uint64
+	if m.rowCountKnown {
+		rowCount = m.RowCount + 1
+	}
+	binary.LittleEndian.PutUint64(buf[pos:], rowCount)
 }
 
 func (m *Meta) Deserialize(buf []byte) {
@@ -41,6 +51,11 @@ func (m *Meta) Deserialize(buf []byte) {
 	pos += pageNumSize
 
 	m.TableDefPage = pgNum(binary.LittleEndian.Uint64(buf[pos:]))
+	pos += pageNumSize
+
+	if rowCount := binary.LittleEndian.Uint64(buf[pos:]); rowCount != 0 {
+		m.RowCount, m.rowCountKnown = rowCount-1, true
+	}
 	utils.Info(2, "Deserialized Meta: ", m.State())
 }
 
@@ -48,5 +63,8 @@ func (m *Meta) State() (ret string) {
 	ret += "Root Page: " + fmt.Sprint(m.Root)
 	ret += " Freelist Page: " + fmt.Sprint(m.freelistPage)
 	ret += " TableDefPage: " + fmt.Sprint(m.TableDefPage)
+	if m.rowCountKnown {
+		ret += " Rows: " + fmt.Sprint(m.RowCount)
+	}
 	return
 }
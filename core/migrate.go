@@ -0,0 +1,423 @@
+package core
+
+import (
+	"BynxDB/core/utils"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+* Migrate upgrades files written by older versions to FORMAT_VERSION, one version at a time. The format versions are:
+*
+*	0	the original layout: pages without a header, the meta page holding the root, freelist and table definition
+*		pages and the row count, the page size not recorded anywhere
+*	1	every page starts with a checksummed header and the meta page with the file header
+*	2	the freelist holds the max page in a u64, the u16 of the versions before wraps past 65535 pages
+*
+* A change to the layout of any page adds a version and a migration from the one before it to migrations.
+ */
+
+// * MigrateOptions tells Migrate how to upgrade a file.
+type MigrateOptions struct {
+	// * DryRun upgrades into a temporary file to check that it would succeed, leaving the file as it is.
+	DryRun bool
+	// * PageSize is the page size of files of version 0, which don't record it. Zero means the one of this machine.
+	PageSize int
+	// * Dir receives the upgraded copies, leaving the files as they are. Empty upgrades the files in place.
+	Dir string
+}
+
+// * MigrateResult describes the upgrade of one file. Steps lists the migrations applied, none for an up to date file.
+type MigrateResult struct {
+	Path  string
+	Dest  string
+	From  int
+	To    int
+	Steps []string
+	Pages uint64
+}
+
+// * migration rewrites src, a file of version from, as dst in the version after it, returning the pages written.
+type migration struct {
+	from    int
+	what    string
+	upgrade func(src *os.File, dst *os.File, pageSize int) (uint64, error)
+}
+
+var migrations = []migration{
+	{from: 0, what: "add page headers and the file header", upgrade: upgradeV0},
+	{from: 1, what: "widen the max page of the freelist", upgrade: upgradeV1},
+}
+
+func (opts *MigrateOptions) legacyPageSize() int {
+	if opts.PageSize == 0 {
+		return os.Getpagesize()
+	}
+	return opts.PageSize
+}
+
+// * FileVersion returns the format version of a file and its page size, pageSize being assumed for version 0 files.
+func FileVersion(path string, pageSize int) (int, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	if h, err := peekFileHeader(file); err == nil {
+		if h.version > FORMAT_VERSION || !validPageSize(h.pageSize) {
+			return 0, 0, fmt.Errorf("%w: %s: %v", ErrIncompatible, path, h.check())
+		}
+		return int(h.version), h.pageSize, nil
+	}
+	if err := checkLegacyFile(file, pageSize); err != nil {
+		return 0, 0, fmt.Errorf("%w: %s: %v", ErrIncompatible, path, err)
+	}
+	return 0, pageSize, nil
+}
+
+// * checkLegacyFile tells whether a file without a file header looks like a version 0 file with pages of pageSize.
+func checkLegacyFile(file *os.File, pageSize int) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if !validPageSize(pageSize) || info.Size() == 0 || info.Size()%int64(pageSize) != 0 {
+		return fmt.Errorf("not a BynxDB file with pages of %d bytes", pageSize)
+	}
+	buf := make([]byte, pageSize)
+	if _, err := file.ReadAt(buf, 0); err != nil {
+		return err
+	}
+	meta := newMetaPage()
+	meta.Deserialize(buf)
+	pages := pgNum(info.Size() / int64(pageSize))
+	for _, p := range []pgNum{meta.Root, meta.freelistPage, meta.TableDefPage} {
+		if p == metaPageNum || p >= pages {
+			return fmt.Errorf("not a BynxDB file, its meta page points at page %d of %d", p, pages)
+		}
+	}
+	return nil
+}
+
+/*
+* Migrate upgrades the file at path to FORMAT_VERSION. Every migration writes a new file next to the destination, the
+* upgraded file is opened to check it and only then renamed over the destination, so a failed upgrade leaves the file
+* as it was. Files already at FORMAT_VERSION are left alone, or copied as they are when opts.Dir is set. The table the
+* file belongs to must not be open.
+ */
+func Migrate(path string, opts *MigrateOptions) (*MigrateResult, error) {
+	return migrateFile(path, opts, nil)
+}
+
+// * migrateFile runs Migrate, calling inspect with the upgraded file before it is moved into place or removed.
+func migrateFile(path string, opts *MigrateOptions, inspect func(path string) error) (*MigrateResult, error) {
+	utils.Info(1, "==Migrate Call==", path)
+	// * Nothing may write the file while it is read, or replace it while it is upgraded in place
+	inPlace := opts.Dir == "" && !opts.DryRun
+	if err := lockFile(path, inPlace, 0); err != nil {
+		return nil, err
+	}
+	defer unlockFile(path)
+	version, pageSize, err := FileVersion(path, opts.legacyPageSize())
+	if err != nil {
+		return nil, err
+	}
+	res := &MigrateResult{Path: path, Dest: path, From: version, To: FORMAT_VERSION}
+	if opts.Dir != "" {
+		res.Dest = filepath.Join(opts.Dir, filepath.Base(path))
+		if _, err := os.Stat(res.Dest); !errors.Is(err, os.ErrNotExist) {
+			return nil, errors.New("[error] can't migrate over existing file " + res.Dest)
+		}
+	}
+	for _, m := range migrations[version:] {
+		res.Steps = append(res.Steps, m.what)
+	}
+
+	if version == FORMAT_VERSION {
+		if inspect != nil {
+			if err := inspect(path); err != nil {
+				return nil, err
+			}
+		}
+		if opts.Dir == "" || opts.DryRun {
+			return res, nil
+		}
+		return res, copyFile(path, res.Dest)
+	}
+
+	// * A dry run writes next to the file, as opts.Dir may not exist
+	tmpBase := res.Dest
+	if opts.DryRun {
+		tmpBase = path
+	} else if err := os.MkdirAll(filepath.Dir(res.Dest), 0777); err != nil {
+		return nil, err
+	}
+	current := path
+	removeCurrent := func() {
+		if current != path {
+			os.Remove(current)
+		}
+	}
+	for _, m := range migrations[version:] {
+		tmpPath := fmt.Sprint(tmpBase, ".migrate", m.from+1)
+		if res.Pages, err = runMigration(m, current, tmpPath, pageSize); err != nil {
+			os.Remove(tmpPath)
+			removeCurrent()
+			return nil, fmt.Errorf("[error] migrating %s to version %d: %w", path, m.from+1, err)
+		}
+		removeCurrent()
+		current = tmpPath
+	}
+	defer removeCurrent()
+
+	dal, err := DalCreate(current, readOnlyOptions())
+	if err != nil {
+		return nil, fmt.Errorf("[error] migrated %s doesn't open: %w", path, err)
+	}
+	dal.Close()
+	if inspect != nil {
+		if err := inspect(current); err != nil {
+			return nil, err
+		}
+	}
+	if opts.DryRun {
+		return res, nil
+	}
+	if err := os.Rename(current, res.Dest); err != nil {
+		return nil, err
+	}
+	current = path
+	utils.Info(1, "Migrated ", path, " from version ", version, " to ", FORMAT_VERSION)
+	return res, nil
+}
+
+func runMigration(m migration, srcPath string, dstPath string, pageSize int) (uint64, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(dstPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+	pages, err := m.upgrade(src, dst, pageSize)
+	if err != nil {
+		return 0, err
+	}
+	return pages, dst.Sync()
+}
+
+func readOnlyOptions() *Options {
+	opts := *options
+	opts.ReadOnly = true
+	return &opts
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+/*
+* MigrateTable upgrades the records file of a table and the files of its unique indexes, found through the table
+* definition of the upgraded records file. The results come records file first.
+ */
+func MigrateTable(name string, opts *MigrateOptions) ([]*MigrateResult, error) {
+	name = strings.ToUpper(name)
+	recPath, err := collectionPath([]byte(name + "rec"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(recPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errors.New("[error] no such table: " + name)
+		}
+		return nil, err
+	}
+	tD := &TableDef{}
+	res, err := migrateFile(recPath, opts, func(path string) error {
+		tD, err = readTableDefFile(path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := []*MigrateResult{res}
+	for _, colIndex := range tD.UniqueCols {
+		indexPath, err := collectionPath([]byte(name + tD.Cols[colIndex]))
+		if err != nil {
+			return results, err
+		}
+		res, err := Migrate(indexPath, opts)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// * readTableDefFile reads the table definition stored in a file of the latest version.
+func readTableDefFile(path string) (*TableDef, error) {
+	dal, err := DalCreate(path, readOnlyOptions())
+	if err != nil {
+		return nil, err
+	}
+	defer dal.Close()
+	p, err := dal.readPageOf(dal.TableDefPage, PAGE_TABLEDEF)
+	if err != nil {
+		return nil, err
+	}
+	tD := &TableDef{}
+	if err := tD.Deserialize(p.Data); err != nil {
+		return nil, corruptPage(dal.TableDefPage, "%v", err)
+	}
+	return tD, nil
+}
+
+// * upgradeV0 adds the page headers: every page reachable from the meta page is decoded in the old layout and written
+// * again through a DAL. Released pages are left as zeros, nothing reads them before they are written again.
+func upgradeV0(src *os.File, dst *os.File, pageSize int) (uint64, error) {
+	info, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+	pages := pgNum(info.Size() / int64(pageSize))
+	read := func(pageNum pgNum) ([]byte, error) {
+		if pageNum == metaPageNum || pageNum >= pages {
+			return nil, fmt.Errorf("page %d outside the file of %d pages", pageNum, pages)
+		}
+		buf := make([]byte, pageSize)
+		_, err := src.ReadAt(buf, int64(pageNum)*int64(pageSize))
+		return buf, err
+	}
+
+	meta := newMetaPage()
+	buf := make([]byte, pageSize)
+	if _, err := src.ReadAt(buf, 0); err != nil {
+		return 0, err
+	}
+	meta.Deserialize(buf)
+	out := &DAL{file: diskFile{dst}, pageSize: pageSize, MinFillPercent: options.MinFillPercent,
+		MaxFillPercent: options.MaxFillPercent, freeList: freeListCreate(), Meta: meta, version: 1}
+
+	if buf, err = read(meta.freelistPage); err != nil {
+		return 0, err
+	}
+	// * Version 0 has no freelist chain
+	if _, err := out.freeList.deserialize(buf, 0); err != nil {
+		return 0, fmt.Errorf("freelist page %d: %v", meta.freelistPage, err)
+	}
+	out.maxPage = unwrapMaxPage(out.maxPage, pages)
+
+	if buf, err = read(meta.TableDefPage); err != nil {
+		return 0, err
+	}
+	tD := &TableDef{}
+	if err := tD.Deserialize(buf); err != nil {
+		return 0, fmt.Errorf("table definition page %d: %v", meta.TableDefPage, err)
+	}
+	p := out.Allocateemptypage(PAGE_TABLEDEF)
+	p.Num = meta.TableDefPage
+	p.Data = tD.Serialize(p.Data)
+	if err := out.Writepage(p); err != nil {
+		return 0, err
+	}
+
+	seen := map[pgNum]bool{}
+	var copyNode func(pageNum pgNum) error
+	copyNode = func(pageNum pgNum) error {
+		if seen[pageNum] {
+			return fmt.Errorf("node page %d reached twice", pageNum)
+		}
+		seen[pageNum] = true
+		buf, err := read(pageNum)
+		if err != nil {
+			return err
+		}
+		node := NodeCreate()
+		if err := node.Deserialize(buf); err != nil {
+			return fmt.Errorf("node page %d: %v", pageNum, err)
+		}
+		if size := node.nodeSize(); size > pageSize-pageHeaderSize {
+			return fmt.Errorf("node page %d holds %d bytes, more than a page with a header fits", pageNum, size)
+		}
+		node.Pagenum = pageNum
+		node.DAL = out
+		if _, err := out.Writenode(node); err != nil {
+			return err
+		}
+		for _, child := range node.Childnodes {
+			if err := copyNode(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := copyNode(meta.Root); err != nil {
+		return 0, err
+	}
+	if _, err := out.Writefreelist(); err != nil {
+		return 0, err
+	}
+	if _, err := out.Writemeta(out.Meta); err != nil {
+		return 0, err
+	}
+	// * The file keeps its length, released pages at the end included
+	if err := dst.Truncate(int64(out.maxPage) * int64(pageSize)); err != nil {
+		return 0, err
+	}
+	return uint64(out.maxPage), nil
+}
+
+// * upgradeV1 writes the freelist again with the max page in a u64, taking it from the length of the file where the
+// * u16 wrapped. The chain may take one more of the released pages, every other page is copied as it is.
+func upgradeV1(src *os.File, dst *os.File, pageSize int) (uint64, error) {
+	if _, err := io.Copy(dst, src); err != nil {
+		return 0, err
+	}
+	info, err := dst.Stat()
+	if err != nil {
+		return 0, err
+	}
+	out := &DAL{file: diskFile{dst}, pageSize: pageSize, MinFillPercent: options.MinFillPercent,
+		MaxFillPercent: options.MaxFillPercent, version: 1}
+	if out.Meta, err = out.Readmeta(); err != nil {
+		return 0, err
+	}
+	if out.freeList, err = out.Readfreelist(); err != nil {
+		return 0, err
+	}
+	out.maxPage = unwrapMaxPage(out.maxPage, pgNum(info.Size()/int64(pageSize)))
+	out.version = 2
+	if _, err := out.Writefreelist(); err != nil {
+		return 0, err
+	}
+	if _, err := out.Writemeta(out.Meta); err != nil {
+		return 0, err
+	}
+	return uint64(out.maxPage), nil
+}
@@ -4,6 +4,7 @@ import (
 	"BynxDB/core/utils"
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 )
 
@@ -89,14 +90,27 @@ func (n *Node) Serialize(buf []byte) []byte {
 	return buf
 }
 
-func (n *Node) Deserialize(buf []byte) {
+// * Deserialize decodes a node page. Counts and offsets pointing outside the page are an error.
+func (n *Node) Deserialize(buf []byte) error {
 	leftPos := 0
 
+	if len(buf) < nodeHeaderSize || buf[0] > 1 {
+		return errors.New("not a node")
+	}
 	Isleaf := uint16(buf[0])
 	ItemsCount := int(binary.LittleEndian.Uint16(buf[1:3]))
 
 	leftPos += 3
 
+	// * The fixed size part of every item, with its child pointer on internal nodes
+	slotSize := 2
+	if Isleaf == 0 {
+		slotSize += pageNumSize
+	}
+	if leftPos+ItemsCount*slotSize+pageNumSize > len(buf) {
+		return fmt.Errorf("%d items don't fit the page", ItemsCount)
+	}
+
 	for i := 0; i < ItemsCount; i++ {
 		if Isleaf == 0 {
 			pageNum := binary.LittleEndian.Uint64(buf[leftPos:])
@@ -104,18 +118,27 @@ func (n *Node) Deserialize(buf []byte) {
 			n.Childnodes = append(n.Childnodes, pgNum(pageNum))
 		}
 
-		offset := binary.LittleEndian.Uint16(buf[leftPos:])
+		offset := int(binary.LittleEndian.Uint16(buf[leftPos:]))
 		leftPos += 2
 
-		kLen := uint16(buf[int(offset)])
+		if offset < leftPos || offset >= len(buf) {
+			return fmt.Errorf("item %d at offset %d outside the page", i, offset)
+		}
+		kLen := int(buf[offset])
 		offset += 1
 
+		if offset+kLen >= len(buf) {
+			return fmt.Errorf("key of item %d runs past the page", i)
+		}
 		Key := buf[offset : offset+kLen]
 		offset += kLen
 
-		vLen := uint16(buf[int(offset)])
+		vLen := int(buf[offset])
 		offset += 1
 
+		if offset+vLen > len(buf) {
+			return fmt.Errorf("value of item %d runs past the page", i)
+		}
 		Value := buf[offset : offset+vLen]
 		offset += vLen
 
@@ -126,17 +149,20 @@ func (n *Node) Deserialize(buf []byte) {
 		pageNum := pgNum(binary.LittleEndian.Uint64(buf[leftPos:]))
 		n.Childnodes = append(n.Childnodes, pageNum)
 	}
+	return nil
 }
 
 func (n *Node) Writenode(node *Node) (*Node, error) {
-	node, _ = n.DAL.Writenode(node)
-	return node, nil
+	return n.DAL.Writenode(node)
 }
 
-func (n *Node) Writenodes(nodes ...*Node) {
+func (n *Node) Writenodes(nodes ...*Node) error {
 	for _, node := range nodes {
-		n.Writenode(node)
+		if _, err := n.Writenode(node); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func (n *Node) Getnode(pageNum pgNum) (*Node, error) {
@@ -237,9 +263,14 @@ func (n *Node) isOverPopulated() bool {
 	return n.DAL.isOverPopulated(n)
 }
 
+// * needsSplit reports whether the node is over populated and holds enough items to be split in two.
+func (n *Node) needsSplit() bool {
+	return n.isOverPopulated() && n.DAL.getSplitIndex(n, true) != -1
+}
+
 // * note: split() is responsible for creating new levels & by extenstion new nodes in the B-tree
 
-func (parentNode *Node) split(nodeToSplit *Node, nodeToSplitIndex int) {
+func (parentNode *Node) split(nodeToSplit *Node, nodeToSplitIndex int) error {
 	//* split rebalances the tree after adding. After insertion the modified node has to be checked to make sure it
 	//* didn't exceed the maximum number of elements. If it did, then it has to be split and rebalanced. The transformation
 	//* is depicted in the graph below. If it's not a leaf node, then the children has to be moved as well as shown.
@@ -255,14 +286,18 @@ func (parentNode *Node) split(nodeToSplit *Node, nodeToSplitIndex int) {
 
 	middleItem := nodeToSplit.Items[splitIndex]
 	var newNode *Node
+	var err error
 	if nodeToSplit.Isleaf() {
-		newNode, _ = parentNode.Writenode(parentNode.DAL.nodeCreate(nodeToSplit.Items[splitIndex+1:], []pgNum{}))
+		newNode, err = parentNode.Writenode(parentNode.DAL.nodeCreate(nodeToSplit.Items[splitIndex+1:], []pgNum{}))
 		nodeToSplit.Items = nodeToSplit.Items[:splitIndex]
 	} else {
-		newNode, _ = parentNode.Writenode(parentNode.DAL.nodeCreate(nodeToSplit.Items[splitIndex+1:], nodeToSplit.Childnodes[splitIndex+1:]))
+		newNode, err = parentNode.Writenode(parentNode.DAL.nodeCreate(nodeToSplit.Items[splitIndex+1:], nodeToSplit.Childnodes[splitIndex+1:]))
 		nodeToSplit.Items = nodeToSplit.Items[:splitIndex]
 		nodeToSplit.Childnodes = nodeToSplit.Childnodes[:splitIndex+1]
 	}
+	if err != nil {
+		return err
+	}
 
 	parentNode.addItem(middleItem, nodeToSplitIndex)
 	// fmt.println("Writing child nodes: ", len(parentNode.Childnodes), nodeToSplitIndex)
@@ -272,11 +307,10 @@ func (parentNode *Node) split(nodeToSplit *Node, nodeToSplitIndex int) {
 	} else {
 		parentNode.Childnodes = append(parentNode.Childnodes[:nodeToSplitIndex+1], parentNode.Childnodes[nodeToSplitIndex:]...)
 		// fmt.println(parentNode.Childnodes)
-		parentNode.Childnodes[nodeToSplitIndex] = newNode.Pagenum
+		parentNode.Childnodes[nodeToSplitIndex+1] = newNode.Pagenum
 	}
 
-	parentNode.Writenodes(parentNode, nodeToSplit)
-
+	return parentNode.Writenodes(parentNode, nodeToSplit)
 }
 
 // * Deletion Auxi Functions
@@ -286,18 +320,18 @@ func (n *Node) canSpareAnElement() bool {
 	return splitIndex != -1
 }
 
-func (n *Node) removeItemFromLeaf(index int) {
+func (n *Node) removeItemFromLeaf(index int) error {
 	noOfItems := len(n.Items)
 	if index >= noOfItems {
-		return
+		return nil
 	} else if index == noOfItems-1 {
 		n.Items = n.Items[:index]
-		n.Writenode(n)
 	} else {
 
 		n.Items = append(n.Items[:index], n.Items[index+1:]...)
-		n.Writenode(n)
 	}
+	_, err := n.Writenode(n)
+	return err
 }
 
 func (n *Node) removeItemFromInternal(index int) ([]int, error) {
@@ -324,7 +358,12 @@ func (n *Node) removeItemFromInternal(index int) ([]int, error) {
 		affectedNodes = append(affectedNodes, traversingIndex)
 	}
 	n.Items[index] = aNode.Items[len(aNode.Items)-1]
-	aNode.removeItemFromLeaf(len(aNode.Items) - 1)
+	if err := aNode.removeItemFromLeaf(len(aNode.Items) - 1); err != nil {
+		return nil, err
+	}
+	if _, err := n.Writenode(n); err != nil {
+		return nil, err
+	}
 
 	return affectedNodes, nil
 }
@@ -400,13 +439,16 @@ func (n *Node) merge(bNode *Node, bNodeIndex int) error {
 	pNodeItem := n.Items[bNodeIndex-1]
 	n.Items = append(n.Items[:bNodeIndex-1], n.Items[bNodeIndex:]...)
 	aNode.Items = append(aNode.Items, pNodeItem)
+	aNode.Items = append(aNode.Items, bNode.Items...)
 	n.Childnodes = append(n.Childnodes[:bNodeIndex], n.Childnodes[bNodeIndex+1:]...)
 
 	if !aNode.Isleaf() {
 		aNode.Childnodes = append(aNode.Childnodes, bNode.Childnodes...)
 	}
 
-	n.Writenodes(aNode, n)
+	if err := n.Writenodes(aNode, n); err != nil {
+		return err
+	}
 	n.DAL.Deletenode(bNode.Pagenum)
 
 	return nil
@@ -427,8 +469,7 @@ func (n *Node) rebalanceRemove(unbalancedNode *Node, unbalancedNodeIndex int) er
 		}
 		if leftNode.canSpareAnElement() {
 			rightRotate(leftNode, unbalancedNode, pNode, unbalancedNodeIndex)
-			pNode.Writenodes(leftNode, unbalancedNode, pNode)
-			return nil
+			return pNode.Writenodes(leftNode, unbalancedNode, pNode)
 		}
 	}
 
@@ -441,7 +482,7 @@ func (n *Node) rebalanceRemove(unbalancedNode *Node, unbalancedNodeIndex int) er
 		}
 		if rightNode.canSpareAnElement() {
 			leftRotate(unbalancedNode, rightNode, pNode, unbalancedNodeIndex)
-			pNode.Writenodes(rightNode, pNode, unbalancedNode)
+			return pNode.Writenodes(rightNode, pNode, unbalancedNode)
 		}
 	}
 	//* The merge function merges a given node with its node to the right. So by default, we merge an unbalanced node
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+/*
+* Every page on disk starts with a header:
+*
+*	| checksum u32 | page type u8 | reserved (3 bytes) | page number u64 |
+*
+* The checksum is the CRC32C of the rest of the page, header included. The page number catches a page written or read
+* at the wrong offset, and the type a pointer to a page holding something else. page.Data is what follows the header.
+ */
+
+type pageType uint8
+
+const (
+	PAGE_META pageType = iota + 1
+	PAGE_FREELIST
+	PAGE_TABLEDEF
+	PAGE_NODE
+)
+
+const pageHeaderSize = 16
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var pageTypeNames = map[pageType]string{PAGE_META: "meta", PAGE_FREELIST: "freelist", PAGE_TABLEDEF: "table definition", PAGE_NODE: "node"}
+
+func (t pageType) String() string {
+	if name, ok := pageTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprint("unknown type ", uint8(t))
+}
+
+// * ErrCorrupt is matched by the errors returned for pages that don't hold what they should.
+var ErrCorrupt = errors.New("[error] corrupt page")
+
+// * CorruptPageError tells which page is corrupt and how.
+type CorruptPageError struct {
+	Page   uint64
+	Reason string
+}
+
+func (e *CorruptPageError) Error() string {
+	return fmt.Sprintf("[error] corrupt page %d: %s", e.Page, e.Reason)
+}
+
+func (e *CorruptPageError) Unwrap() error {
+	return ErrCorrupt
+}
+
+func corruptPage(pageNum pgNum, format string, args ...any) error {
+	return &CorruptPageError{Page: uint64(pageNum), Reason: fmt.Sprintf(format, args...)}
+}
+
+// * encodePage lays out a page as written to disk: the header followed by its data.
+func encodePage(p *page, pageSize int) ([]byte, error) {
+	if p.Type == 0 {
+		return nil, fmt.Errorf("[error] page %d written without a type", p.Num)
+	}
+	if len(p.Data) > pageSize-pageHeaderSize {
+		return nil, fmt.Errorf("[error] %s page %d overflows, %d bytes", p.Type, p.Num, len(p.Data))
+	}
+	buf := make([]byte, pageSize)
+	buf[4] = byte(p.Type)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(p.Num))
+	copy(buf[pageHeaderSize:], p.Data)
+	binary.LittleEndian.PutUint32(buf, crc32.Checksum(buf[4:], crcTable))
+	return buf, nil
+}
+
+// * decodePage checks the header of a page read from disk as pageNum.
+func decodePage(pageNum pgNum, buf []byte) (*page, error) {
+	if sum := crc32.Checksum(buf[4:], crcTable); sum != binary.LittleEndian.Uint32(buf) {
+		return nil, corruptPage(pageNum, "checksum mismatch")
+	}
+	if stored := pgNum(binary.LittleEndian.Uint64(buf[8:])); stored != pageNum {
+		return nil, corruptPage(pageNum, "holds page %d", stored)
+	}
+	return &page{Num: pageNum, Type: pageType(buf[4]), Data: buf[pageHeaderSize:]}, nil
+}
@@ -0,0 +1,473 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+* Query planner. A query is a conjunction of predicates over named columns. The planner looks at the records tree
+* (primary key) and the unique index trees and picks the cheapest way to reach the matching rows:
+*
+*	PKEY LOOKUP       equality on the primary key, one record tree search
+*	INDEX LOOKUP      equality on a unique column, one index search plus one record search
+*	PKEY RANGE SCAN   bounds on the primary key, in order walk of the records tree between them
+*	INDEX RANGE SCAN  bounds on a unique column, walk of the index tree plus a record search per entry
+*	FULL SCAN         every record
+*
+* Predicates not answered by the access path are applied as residual filters. Range scans need ordered keys, so
+* tables stored with KEY_ENCODING_LEGACY only use lookups and full scans.
+ */
+
+type Op int
+
+const (
+	OP_EQ Op = iota
+	OP_NE
+	OP_LT
+	OP_LE
+	OP_GT
+	OP_GE
+	OP_BETWEEN // * Value <= col <= High
+)
+
+var opSymbols = map[Op]string{OP_EQ: "=", OP_NE: "<>", OP_LT: "<", OP_LE: "<=", OP_GT: ">", OP_GE: ">="}
+
+// * Predicate compares a column with a value, High is only used by OP_BETWEEN.
+type Predicate struct {
+	Col   string
+	Op    Op
+	Value any
+	High  any
+}
+
+func Eq(col string, val any) Predicate { return Predicate{Col: col, Op: OP_EQ, Value: val} }
+func Ne(col string, val any) Predicate { return Predicate{Col: col, Op: OP_NE, Value: val} }
+func Lt(col string, val any) Predicate { return Predicate{Col: col, Op: OP_LT, Value: val} }
+func Le(col string, val any) Predicate { return Predicate{Col: col, Op: OP_LE, Value: val} }
+func Gt(col string, val any) Predicate { return Predicate{Col: col, Op: OP_GT, Value: val} }
+func Ge(col string, val any) Predicate { return Predicate{Col: col, Op: OP_GE, Value: val} }
+func Between(col string, low any, high any) Predicate {
+	return Predicate{Col: col, Op: OP_BETWEEN, Value: low, High: high}
+}
+
+func (p Predicate) String() string {
+	if p.Op == OP_BETWEEN {
+		return p.Col + " BETWEEN " + formatValue(p.Value) + " AND " + formatValue(p.High)
+	}
+	return p.Col + " " + opSymbols[p.Op] + " " + formatValue(p.Value)
+}
+
+func formatValue(val any) string {
+	switch data := val.(type) {
+	case int:
+		return strconv.Itoa(data)
+	case []byte:
+		return "'" + strings.ReplaceAll(string(data), "'", "''") + "'"
+	}
+	return fmt.Sprint(val)
+}
+
+// * Matches evaluates the predicate against a value of its column.
+func (p Predicate) Matches(val any) bool {
+	c := compareValues(val, p.Value)
+	switch p.Op {
+	case OP_EQ:
+		return c == 0
+	case OP_NE:
+		return c != 0
+	case OP_LT:
+		return c < 0
+	case OP_LE:
+		return c <= 0
+	case OP_GT:
+		return c > 0
+	case OP_GE:
+		return c >= 0
+	case OP_BETWEEN:
+		return c >= 0 && compareValues(val, p.High) <= 0
+	}
+	return false
+}
+
+func (p Predicate) isRange() bool {
+	return p.Op == OP_LT || p.Op == OP_LE || p.Op == OP_GT || p.Op == OP_GE || p.Op == OP_BETWEEN
+}
+
+type AccessPath int
+
+const (
+	ACCESS_FULL_SCAN AccessPath = iota
+	ACCESS_PKEY_LOOKUP
+	ACCESS_INDEX_LOOKUP
+	ACCESS_PKEY_RANGE
+	ACCESS_INDEX_RANGE
+)
+
+var accessNames = map[AccessPath]string{
+	ACCESS_FULL_SCAN:    "FULL SCAN",
+	ACCESS_PKEY_LOOKUP:  "PKEY LOOKUP",
+	ACCESS_INDEX_LOOKUP: "INDEX LOOKUP",
+	ACCESS_PKEY_RANGE:   "PKEY RANGE SCAN",
+	ACCESS_INDEX_RANGE:  "INDEX RANGE SCAN",
+}
+
+func (a AccessPath) String() string {
+	return accessNames[a]
+}
+
+// * Relative costs of the access paths. Without statistics a bounded range is assumed to touch fewer rows than a
+// * range open on one side, and every path is cheaper than reading the whole table.
+const (
+	costPKeyLookup     = 1
+	costIndexLookup    = 2
+	costPKeyRange      = 10
+	costIndexRange     = 20
+	costPKeyHalfRange  = 40
+	costIndexHalfRange = 60
+	costFullScan       = 100
+)
+
+// * Plan is the chosen way to answer a query. Low and High bound the key column of a range scan, nil when open.
+type Plan struct {
+	Access        AccessPath
+	Column        string // * Column of the tree searched, empty for a full scan
+	Value         any    // * Key of a lookup
+	Low, High     any
+	LowInclusive  bool
+	HighInclusive bool
+	Residual      []Predicate
+	Cost          int
+
+	db        *DB
+	index     *Collection
+	residuals []boundPredicate
+	reverse   bool // * Walk the tree in descending key order
+}
+
+type boundPredicate struct {
+	Predicate
+	colIndex int
+}
+
+// * Plan chooses the access path for the conjunction of preds.
+func (db *DB) Plan(preds ...Predicate) (*Plan, error) {
+	tD := db.records.TableDef
+	bound := make([]boundPredicate, len(preds))
+	for i, p := range preds {
+		colIndex, err := tD.ColIndex(p.Col)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkType(tD, colIndex, p.Value); err != nil {
+			return nil, err
+		}
+		if p.Op == OP_BETWEEN {
+			if err := checkType(tD, colIndex, p.High); err != nil {
+				return nil, err
+			}
+		}
+		p.Col = tD.Cols[colIndex]
+		bound[i] = boundPredicate{Predicate: p, colIndex: colIndex}
+	}
+
+	best := &Plan{Access: ACCESS_FULL_SCAN, Cost: costFullScan}
+	var bestUsed []int
+	// * Candidate trees: the records tree for the primary key, then every index tree
+	keyCols := append([]int{0}, tD.UniqueCols...)
+	for treeIndex, colIndex := range keyCols {
+		plan, used := planColumn(tD, bound, colIndex, treeIndex == 0)
+		if plan != nil && plan.Cost < best.Cost {
+			best, bestUsed = plan, used
+			if treeIndex != 0 {
+				best.index = db.uniqueColumnsTree[treeIndex-1]
+			}
+		}
+	}
+
+	best.db = db
+	for i, p := range bound {
+		if !containsInt(bestUsed, i) {
+			best.residuals = append(best.residuals, p)
+			best.Residual = append(best.Residual, p.Predicate)
+		}
+	}
+	return best, nil
+}
+
+// * planColumn returns the cheapest plan searching the tree keyed by colIndex, and the predicates it answers.
+func planColumn(tD *TableDef, preds []boundPredicate, colIndex int, pKey bool) (*Plan, []int) {
+	for i, p := range preds {
+		if p.colIndex == colIndex && p.Op == OP_EQ {
+			plan := &Plan{Access: ACCESS_INDEX_LOOKUP, Cost: costIndexLookup, Column: tD.Cols[colIndex], Value: p.Value}
+			if pKey {
+				plan.Access, plan.Cost = ACCESS_PKEY_LOOKUP, costPKeyLookup
+			}
+			return plan, []int{i}
+		}
+	}
+	if !orderedKeys(tD) {
+		return nil, nil
+	}
+	plan := &Plan{Column: tD.Cols[colIndex]}
+	var used []int
+	for i, p := range preds {
+		if p.colIndex != colIndex || !p.isRange() {
+			continue
+		}
+		used = append(used, i)
+		switch p.Op {
+		case OP_GT, OP_GE:
+			plan.tightenLow(p.Value, p.Op == OP_GE)
+		case OP_LT, OP_LE:
+			plan.tightenHigh(p.Value, p.Op == OP_LE)
+		case OP_BETWEEN:
+			plan.tightenLow(p.Value, true)
+			plan.tightenHigh(p.High, true)
+		}
+	}
+	if len(used) == 0 {
+		return nil, nil
+	}
+	bounded := plan.Low != nil && plan.High != nil
+	switch {
+	case pKey && bounded:
+		plan.Access, plan.Cost = ACCESS_PKEY_RANGE, costPKeyRange
+	case pKey:
+		plan.Access, plan.Cost = ACCESS_PKEY_RANGE, costPKeyHalfRange
+	case bounded:
+		plan.Access, plan.Cost = ACCESS_INDEX_RANGE, costIndexRange
+	default:
+		plan.Access, plan.Cost = ACCESS_INDEX_RANGE, costIndexHalfRange
+	}
+	return plan, used
+}
+
+func (p *Plan) tightenLow(val any, inclusive bool) {
+	if p.Low == nil {
+		p.Low, p.LowInclusive = val, inclusive
+		return
+	}
+	c := compareValues(val, p.Low)
+	if c > 0 || (c == 0 && !inclusive) {
+		p.Low, p.LowInclusive = val, inclusive
+	}
+}
+
+func (p *Plan) tightenHigh(val any, inclusive bool) {
+	if p.High == nil {
+		p.High, p.HighInclusive = val, inclusive
+		return
+	}
+	c := compareValues(val, p.High)
+	if c < 0 || (c == 0 && !inclusive) {
+		p.High, p.HighInclusive = val, inclusive
+	}
+}
+
+func containsInt(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// * Explain describes the plan, one line for the access path and one for the residual filter if there is any.
+func (p *Plan) Explain() string {
+	var sb strings.Builder
+	sb.WriteString(p.Access.String())
+	switch p.Access {
+	case ACCESS_PKEY_LOOKUP, ACCESS_INDEX_LOOKUP:
+		sb.WriteString(" on " + p.Column + " = " + formatValue(p.Value))
+	case ACCESS_PKEY_RANGE, ACCESS_INDEX_RANGE:
+		sb.WriteString(" on " + p.Column + " " + p.rangeString())
+	}
+	if p.reverse {
+		sb.WriteString(" DESC")
+	}
+	sb.WriteString(" (cost " + strconv.Itoa(p.Cost) + ")")
+	if len(p.Residual) != 0 {
+		filters := make([]string, len(p.Residual))
+		for i, pred := range p.Residual {
+			filters[i] = pred.String()
+		}
+		sb.WriteString("\nFILTER " + strings.Join(filters, " AND "))
+	}
+	return sb.String()
+}
+
+func (p *Plan) rangeString() string {
+	lowBracket, highBracket := "(", ")"
+	low, high := "-inf", "+inf"
+	if p.Low != nil {
+		low = formatValue(p.Low)
+		if p.LowInclusive {
+			lowBracket = "["
+		}
+	}
+	if p.High != nil {
+		high = formatValue(p.High)
+		if p.HighInclusive {
+			highBracket = "]"
+		}
+	}
+	return lowBracket + low + ", " + high + highBracket
+}
+
+// * Each calls fn with every matching row in the order of the searched tree. fn returns false to stop.
+func (p *Plan) Each(fn func(row []any) (bool, error)) error {
+	tD := p.db.records.TableDef
+	emit := func(item *Item) (bool, error) {
+		row := append([]any{decodeKey(tD, 0, item.Key)}, decodeRow(tD, item.Value)...)
+		for _, pred := range p.residuals {
+			if !pred.Matches(row[pred.colIndex]) {
+				return true, nil
+			}
+		}
+		return fn(row)
+	}
+	// * record resolves an index entry to the record it points at
+	record := func(pKey []byte) (*Item, error) {
+		item, err := p.db.records.Find(pKey)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil {
+			return nil, errors.New("[error] index entry without record in: " + p.Column)
+		}
+		return item, nil
+	}
+
+	switch p.Access {
+	case ACCESS_PKEY_LOOKUP, ACCESS_INDEX_LOOKUP:
+		colIndex, _ := tD.ColIndex(p.Column)
+		key, err := encodeKey(tD, colIndex, p.Value)
+		if err != nil {
+			return err
+		}
+		tree := p.db.records
+		if p.index != nil {
+			tree = p.index
+		}
+		item, err := tree.Find(key)
+		if err != nil || item == nil {
+			return err
+		}
+		if p.index != nil {
+			if item, err = record(item.Value); err != nil {
+				return err
+			}
+		}
+		_, err = emit(item)
+		return err
+	case ACCESS_PKEY_RANGE, ACCESS_INDEX_RANGE:
+		colIndex, _ := tD.ColIndex(p.Column)
+		var lowKey, highKey []byte
+		var err error
+		if p.Low != nil {
+			if lowKey, err = encodeKey(tD, colIndex, p.Low); err != nil {
+				return err
+			}
+		}
+		if p.High != nil {
+			if highKey, err = encodeKey(tD, colIndex, p.High); err != nil {
+				return err
+			}
+		}
+		tree := p.db.records
+		if p.index != nil {
+			tree = p.index
+		}
+		scan := tree.Scan
+		if p.reverse {
+			scan = tree.ScanReverse
+		}
+		return scan(lowKey, highKey, func(item *Item) (bool, error) {
+			// * The tree bounds are inclusive, exclusive ends are skipped here. Reaching an excluded end stops the scan.
+			if !p.LowInclusive && lowKey != nil && bytes.Equal(item.Key, lowKey) {
+				return !p.reverse, nil
+			}
+			if !p.HighInclusive && highKey != nil && bytes.Equal(item.Key, highKey) {
+				return p.reverse, nil
+			}
+			if p.index != nil {
+				var err error
+				if item, err = record(item.Value); err != nil {
+					return false, err
+				}
+			}
+			return emit(item)
+		})
+	}
+	if p.reverse {
+		return p.db.records.ScanReverse(nil, nil, emit)
+	}
+	return p.db.records.Scan(nil, nil, emit)
+}
+
+// * orderBy makes the plan produce its rows ordered by colIndex, in descending order when desc is set, and reports
+// * whether that is possible without sorting. Only trees with ordered keys are walked in value order. A full scan is
+// * turned into a walk of the index of colIndex when there is one.
+func (p *Plan) orderBy(colIndex int, desc bool) bool {
+	tD := p.db.records.TableDef
+	col := tD.Cols[colIndex]
+	switch p.Access {
+	case ACCESS_PKEY_LOOKUP, ACCESS_INDEX_LOOKUP:
+		return true
+	case ACCESS_PKEY_RANGE, ACCESS_INDEX_RANGE:
+		if p.Column != col {
+			return false
+		}
+	case ACCESS_FULL_SCAN:
+		if !orderedKeys(tD) {
+			return false
+		}
+		if colIndex != 0 {
+			treeIndex := -1
+			for i, uniqueCol := range tD.UniqueCols {
+				if uniqueCol == colIndex {
+					treeIndex = i
+				}
+			}
+			if treeIndex == -1 {
+				return false
+			}
+			p.Access, p.Column, p.index = ACCESS_INDEX_RANGE, col, p.db.uniqueColumnsTree[treeIndex]
+		}
+	}
+	p.reverse = desc
+	return true
+}
+
+// * Rows collects every matching row.
+func (p *Plan) Rows() ([][]any, error) {
+	var rows [][]any
+	err := p.Each(func(row []any) (bool, error) {
+		rows = append(rows, row)
+		return true, nil
+	})
+	return rows, err
+}
+
+// * Query returns the rows matching every predicate, using the plan chosen by Plan.
+func (db *DB) Query(preds ...Predicate) ([][]any, error) {
+	plan, err := db.Plan(preds...)
+	if err != nil {
+		return nil, err
+	}
+	return plan.Rows()
+}
+
+// * Explain returns the plan Query would use for preds.
+func (db *DB) Explain(preds ...Predicate) (string, error) {
+	plan, err := db.Plan(preds...)
+	if err != nil {
+		return "", err
+	}
+	return plan.Explain(), nil
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// * Row is a single table row whose values are looked up by column name instead of position, so callers don't have to
+// * know where the primary key was moved to by the TableDef.
+type Row struct {
+	cols   []string
+	values []any
+}
+
+func newRow(tD *TableDef, values []any) *Row {
+	return NewRow(tD.Cols, values)
+}
+
+// * NewRow wraps positional values with their column names.
+func NewRow(cols []string, values []any) *Row {
+	return &Row{
+		cols:   cols,
+		values: values,
+	}
+}
+
+func newRows(tD *TableDef, rows [][]any) []*Row {
+	ret := make([]*Row, 0, len(rows))
+	for _, values := range rows {
+		ret = append(ret, newRow(tD, values))
+	}
+	return ret
+}
+
+// * Columns returns the column names in the order they are stored.
+func (r *Row) Columns() []string {
+	return append([]string{}, r.cols...)
+}
+
+// * Values returns the row as a positional slice, in the order of Columns.
+func (r *Row) Values() []any {
+	return r.values
+}
+
+// * Get returns the value of the named column or nil if the row has no such column.
+func (r *Row) Get(name string) any {
+	for i, col := range r.cols {
+		if strings.EqualFold(col, name) {
+			return r.values[i]
+		}
+	}
+	return nil
+}
+
+// * Int returns the value of an integer column, or 0 if the column is missing or not an integer.
+func (r *Row) Int(name string) int {
+	val, _ := r.Get(name).(int)
+	return val
+}
+
+// * Bytes returns the value of a byte column, or nil if the column is missing or not a byte column.
+func (r *Row) Bytes(name string) []byte {
+	val, _ := r.Get(name).([]byte)
+	return val
+}
+
+// * String returns the value of the named column as a string. Numbers are formatted in base 10.
+func (r *Row) String(name string) string {
+	switch data := r.Get(name).(type) {
+	case []byte:
+		return string(data)
+	case int:
+		return fmt.Sprint(data)
+	case float64:
+		return strconv.FormatFloat(data, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// * Named column API
+
+// * Columns returns the column names of the table, primary key first.
+func (db *DB) Columns() []string {
+	return append([]string{}, db.records.TableDef.Cols...)
+}
+
+// * InsertRow inserts a row given as a map of column names to values. Columns left out take their default value,
+// * every column without a default has to be present.
+func (db *DB) InsertRow(values map[string]any) error {
+	row, err := db.positionalRow(values)
+	if err != nil {
+		return err
+	}
+	return db.Insert(row...)
+}
+
+// * GetRow returns the row with the given primary key.
+func (db *DB) GetRow(pKeyVal any) (*Row, error) {
+	values, err := db.PKeyQuery(pKeyVal)
+	if err != nil {
+		return nil, err
+	}
+	return newRow(db.records.TableDef, values), nil
+}
+
+// * SelectAll returns every row of the table.
+func (db *DB) SelectAll() ([]*Row, error) {
+	rows, err := db.SelectEntireTable()
+	if err != nil {
+		return nil, err
+	}
+	return newRows(db.records.TableDef, rows), nil
+}
+
+// * SelectWhere returns the rows whose named column equals val.
+func (db *DB) SelectWhere(col string, val any) ([]*Row, error) {
+	colIndex, err := db.records.TableDef.ColIndex(col)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.PointQuery(colIndex, val)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(db.records.TableDef, rows), nil
+}
+
+// * SelectRange returns the rows whose named column lies between low and high, both inclusive.
+func (db *DB) SelectRange(col string, low any, high any) ([]*Row, error) {
+	colIndex, err := db.records.TableDef.ColIndex(col)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.RangeQuery(colIndex, low, high)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(db.records.TableDef, rows), nil
+}
+
+// * DeleteBy deletes the rows whose named column equals val.
+func (db *DB) DeleteBy(col string, val any) error {
+	colIndex, err := db.records.TableDef.ColIndex(col)
+	if err != nil {
+		return err
+	}
+	return db.Delete(colIndex, val)
+}
+
+// * positionalRow orders the values of a named row the way Insert expects them.
+func (db *DB) positionalRow(values map[string]any) ([]any, error) {
+	tD := db.records.TableDef
+	row := make([]any, len(tD.Cols))
+	for colName, val := range values {
+		colIndex, err := tD.ColIndex(colName)
+		if err != nil {
+			return nil, err
+		}
+		row[colIndex] = val
+	}
+	for i, val := range row {
+		if val != nil {
+			continue
+		}
+		if row[i] = tD.Defaults[tD.Cols[i]]; row[i] == nil {
+			return nil, errors.New("[error] missing value for column: " + tD.Cols[i])
+		}
+	}
+	return row, nil
+}
@@ -0,0 +1,279 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// * QueryOptions shape the result of Select. The zero value returns every column of every row, ordered by primary key.
+type QueryOptions struct {
+	Columns []string // * Projection, every column when empty
+	OrderBy string   // * Primary key when empty
+	Desc    bool
+	Limit   int // * No limit when 0
+	Offset  int
+	// * After continues a keyset paginated query with the NextToken of the previous page. The other options have to
+	// * be the same as for that page.
+	After string
+	// * Filter is an extra condition on the positional row, applied together with the predicates.
+	Filter func(row []any) bool
+}
+
+// * ResultSet holds the rows of a Select. NextToken is set when Limit cut the result short.
+type ResultSet struct {
+	Columns   []string
+	Rows      [][]any
+	NextToken string
+	// * Sorted tells whether the rows had to be sorted in memory instead of being read in order from a tree
+	Sorted bool
+}
+
+// * Row returns the i-th row with its values addressable by column name.
+func (rs *ResultSet) Row(i int) *Row {
+	return NewRow(rs.Columns, rs.Rows[i])
+}
+
+/*
+* Select returns the rows matching preds shaped by opts. When the order column is the primary key or a unique column
+* the rows are read in order from its tree, so LIMIT stops the walk early. Any other order sorts the matching rows.
+*
+* Keyset pagination: a page cut by Limit carries a NextToken holding the order column and primary key of its last
+* row. Passing it as After returns the rows following that row, which stays correct when rows are inserted or
+* deleted between pages, unlike Offset.
+ */
+func (db *DB) Select(opts *QueryOptions, preds ...Predicate) (*ResultSet, error) {
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+	tD := db.records.TableDef
+	sel, err := db.planSelect(opts, preds)
+	if err != nil {
+		return nil, err
+	}
+	rs := &ResultSet{}
+	for _, colIndex := range sel.projection {
+		rs.Columns = append(rs.Columns, tD.Cols[colIndex])
+	}
+	// * One row more than the page tells whether there is a next page
+	wanted := -1
+	if opts.Limit > 0 {
+		wanted = opts.Offset + opts.Limit + 1
+	}
+
+	var rows [][]any
+	err = sel.plan.Each(func(row []any) (bool, error) {
+		if opts.Filter != nil && !opts.Filter(row) {
+			return true, nil
+		}
+		if sel.after != nil && !sel.after.before(row, sel.orderIndex, opts.Desc) {
+			return true, nil
+		}
+		rows = append(rows, row)
+		return !sel.ordered || wanted == -1 || len(rows) < wanted, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !sel.ordered {
+		rs.Sorted = true
+		sort.SliceStable(rows, func(i, j int) bool {
+			return compareRows(rows[i], rows[j], sel.orderIndex, opts.Desc) < 0
+		})
+	}
+
+	if opts.Offset >= len(rows) {
+		rows = nil
+	} else {
+		rows = rows[opts.Offset:]
+	}
+	if opts.Limit > 0 && len(rows) > opts.Limit {
+		rows = rows[:opts.Limit]
+		rs.NextToken = encodePageToken(tD, rows[len(rows)-1], sel.orderIndex, opts.Desc)
+	}
+	for _, row := range rows {
+		out := make([]any, len(sel.projection))
+		for i, colIndex := range sel.projection {
+			out[i] = row[colIndex]
+		}
+		rs.Rows = append(rs.Rows, out)
+	}
+	return rs, nil
+}
+
+// * selectPlan is a Plan with the shaping of a Select resolved.
+type selectPlan struct {
+	plan       *Plan
+	orderIndex int
+	projection []int
+	after      *pageKey
+	ordered    bool // * The plan yields the rows in the requested order
+}
+
+func (db *DB) planSelect(opts *QueryOptions, preds []Predicate) (*selectPlan, error) {
+	tD := db.records.TableDef
+	if opts.Limit < 0 || opts.Offset < 0 {
+		return nil, errors.New("[error] limit and offset can't be negative")
+	}
+	sel := &selectPlan{}
+	var err error
+	if opts.OrderBy != "" {
+		if sel.orderIndex, err = tD.ColIndex(opts.OrderBy); err != nil {
+			return nil, err
+		}
+	}
+	if sel.projection, err = projectionOf(tD, opts.Columns); err != nil {
+		return nil, err
+	}
+	if opts.After != "" {
+		if sel.after, err = decodePageToken(tD, opts.After, sel.orderIndex, opts.Desc); err != nil {
+			return nil, err
+		}
+		// * On a unique column the token is a plain bound the planner can use
+		if isKeyCol(tD, sel.orderIndex) {
+			bound := Gt(tD.Cols[sel.orderIndex], sel.after.val)
+			if opts.Desc {
+				bound = Lt(tD.Cols[sel.orderIndex], sel.after.val)
+			}
+			preds = append(preds, bound)
+		}
+	}
+	if sel.plan, err = db.Plan(preds...); err != nil {
+		return nil, err
+	}
+	sel.ordered = sel.plan.orderBy(sel.orderIndex, opts.Desc)
+	return sel, nil
+}
+
+// * ExplainSelect returns the plan Select would use, with the sort and paging steps.
+func (db *DB) ExplainSelect(opts *QueryOptions, preds ...Predicate) (string, error) {
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+	sel, err := db.planSelect(opts, preds)
+	if err != nil {
+		return "", err
+	}
+	explain := sel.plan.Explain()
+	if !sel.ordered {
+		explain += "\nSORT BY " + db.records.TableDef.Cols[sel.orderIndex]
+		if opts.Desc {
+			explain += " DESC"
+		}
+	}
+	if opts.Limit > 0 {
+		explain += "\nLIMIT " + strconv.Itoa(opts.Limit)
+	}
+	if opts.Offset > 0 {
+		explain += "\nOFFSET " + strconv.Itoa(opts.Offset)
+	}
+	return explain, nil
+}
+
+func projectionOf(tD *TableDef, cols []string) ([]int, error) {
+	var projection []int
+	if len(cols) == 0 {
+		for i := range tD.Cols {
+			projection = append(projection, i)
+		}
+		return projection, nil
+	}
+	for _, col := range cols {
+		colIndex, err := tD.ColIndex(col)
+		if err != nil {
+			return nil, err
+		}
+		projection = append(projection, colIndex)
+	}
+	return projection, nil
+}
+
+func isKeyCol(tD *TableDef, colIndex int) bool {
+	return colIndex == 0 || containsInt(tD.UniqueCols, colIndex)
+}
+
+// * compareRows orders rows by the order column, ties broken by primary key.
+func compareRows(a, b []any, orderIndex int, desc bool) int {
+	c := compareValues(a[orderIndex], b[orderIndex])
+	if c == 0 {
+		c = compareValues(a[0], b[0])
+	}
+	if desc {
+		return -c
+	}
+	return c
+}
+
+// * Keyset pagination tokens
+
+const pageTokenVersion = 1
+
+// * pageKey is the position of the last row of a page.
+type pageKey struct {
+	val  any
+	pKey any
+}
+
+// * before reports whether row comes after the page key in the query order.
+func (k *pageKey) before(row []any, orderIndex int, desc bool) bool {
+	c := compareValues(k.val, row[orderIndex])
+	if c == 0 {
+		c = compareValues(k.pKey, row[0])
+	}
+	if desc {
+		c = -c
+	}
+	return c < 0
+}
+
+/*
+* A token is the URL safe base64 of
+*
+*	| Version | Order Column | Desc | Order Column Value | Primary Key Value |
+*
+* with the values in the length prefixed encoding of record values.
+ */
+func encodePageToken(tD *TableDef, row []any, orderIndex int, desc bool) string {
+	buf := []byte{pageTokenVersion, byte(orderIndex), 0}
+	if desc {
+		buf[2] = 1
+	}
+	buf, _ = checkTypeAndEncodeByte(tD, orderIndex, row[orderIndex], buf)
+	buf, _ = checkTypeAndEncodeByte(tD, 0, row[0], buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodePageToken(tD *TableDef, token string, orderIndex int, desc bool) (*pageKey, error) {
+	errInvalid := errors.New("[error] invalid page token")
+	buf, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil || len(buf) < 3 || buf[0] != pageTokenVersion {
+		return nil, errInvalid
+	}
+	if int(buf[1]) != orderIndex || (buf[2] == 1) != desc {
+		return nil, errors.New("[error] page token belongs to a query with a different order")
+	}
+	buf = buf[3:]
+	vals := make([]any, 2)
+	for i, colIndex := range []int{orderIndex, 0} {
+		// * Check the length before decoding, the token comes from the caller
+		size := 8
+		if tD.Types[colIndex] == TYPE_BYTE {
+			if len(buf) < 2 {
+				return nil, errInvalid
+			}
+			size = 2 + int(binary.LittleEndian.Uint16(buf))
+		}
+		if len(buf) < size {
+			return nil, errInvalid
+		}
+		vals[i], _ = checkTypeAndDecodeCol(tD, colIndex, buf[:size])
+		buf = buf[size:]
+	}
+	if len(buf) != 0 {
+		return nil, errInvalid
+	}
+	return &pageKey{val: vals[0], pKey: vals[1]}, nil
+}
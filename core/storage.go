@@ -0,0 +1,270 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/*
+* Storage holds the files of the tables, addressed by the paths collectionPath gives them. Options.Storage picks it:
+*
+*	FileStorage      files on disk, locked against other processes, the default
+*	MemoryStorage    files in memory, gone with the process, for tests and caches
+*
+* A table is opened through the storage it was created in, every file of it and of the tables its foreign keys reach.
+ */
+type Storage interface {
+	// * Open opens the file at path, creating it when it is missing unless readOnly is set.
+	Open(path string, readOnly bool) (StorageFile, error)
+	Exists(path string) (bool, error)
+	Remove(path string) error
+	// * Rename moves a file over another one, which keeps the lock it holds.
+	Rename(oldPath string, newPath string) error
+	// * Lock locks the file at path, shared or exclusive, waiting up to timeout. The returned function unlocks it.
+	Lock(path string, exclusive bool, timeout time.Duration) (func(), error)
+	// * List returns the names of the files in dir, none when dir is missing.
+	List(dir string) ([]string, error)
+}
+
+// * StorageFile is an open file of a Storage.
+type StorageFile interface {
+	io.ReaderAt
+	io.WriterAt
+	Size() (int64, error)
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// * FileStorage keeps the files on disk.
+var FileStorage Storage = &fileStorage{}
+
+type fileStorage struct{}
+
+type diskFile struct {
+	*os.File
+}
+
+func (f diskFile) Size() (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *fileStorage) Open(path string, readOnly bool) (StorageFile, error) {
+	flag := os.O_RDWR | os.O_CREATE
+	if readOnly {
+		flag = os.O_RDONLY
+	} else if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, flag, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return diskFile{file}, nil
+}
+
+func (s *fileStorage) Exists(path string) (bool, error) {
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *fileStorage) Remove(path string) error {
+	return os.Remove(path)
+}
+
+func (s *fileStorage) Rename(oldPath string, newPath string) error {
+	return renameLocked(oldPath, newPath)
+}
+
+func (s *fileStorage) Lock(path string, exclusive bool, timeout time.Duration) (func(), error) {
+	if err := lockFile(path, exclusive, timeout); err != nil {
+		return nil, err
+	}
+	return func() { unlockFile(path) }, nil
+}
+
+func (s *fileStorage) List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+/*
+* MemoryStorage keeps the files in memory. Like a file on disk, a file removed or renamed over stays readable through
+* the handles open on it. Locks aren't needed, the files can't be reached from another process, and within the process
+* a table opened twice shares its files as it does on disk.
+ */
+type MemoryStorage struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: map[string]*memFile{}}
+}
+
+type memFile struct {
+	mu   sync.RWMutex
+	data []byte
+}
+
+// * memHandle is a file of a MemoryStorage as opened once.
+type memHandle struct {
+	*memFile
+	path     string
+	readOnly bool
+}
+
+func (s *MemoryStorage) Open(path string, readOnly bool) (StorageFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path = filepath.Clean(path)
+	f, ok := s.files[path]
+	if !ok {
+		if readOnly {
+			return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+		}
+		f = &memFile{}
+		s.files[path] = f
+	}
+	return &memHandle{memFile: f, path: path, readOnly: readOnly}, nil
+}
+
+func (s *MemoryStorage) Exists(path string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.files[filepath.Clean(path)]
+	return ok, nil
+}
+
+func (s *MemoryStorage) Remove(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path = filepath.Clean(path)
+	if _, ok := s.files[path]; !ok {
+		return &os.PathError{Op: "remove", Path: path, Err: os.ErrNotExist}
+	}
+	delete(s.files, path)
+	return nil
+}
+
+func (s *MemoryStorage) Rename(oldPath string, newPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldPath, newPath = filepath.Clean(oldPath), filepath.Clean(newPath)
+	f, ok := s.files[oldPath]
+	if !ok {
+		return &os.PathError{Op: "rename", Path: oldPath, Err: os.ErrNotExist}
+	}
+	delete(s.files, oldPath)
+	s.files[newPath] = f
+	return nil
+}
+
+func (s *MemoryStorage) Lock(path string, exclusive bool, timeout time.Duration) (func(), error) {
+	return func() {}, nil
+}
+
+func (s *MemoryStorage) List(dir string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir = filepath.Clean(dir)
+	var names []string
+	for path := range s.files {
+		if filepath.Dir(path) == dir {
+			names = append(names, filepath.Base(path))
+		}
+	}
+	return names, nil
+}
+
+// * Size returns the bytes held by the files of the storage.
+func (s *MemoryStorage) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var size int64
+	for _, f := range s.files {
+		f.mu.RLock()
+		size += int64(len(f.data))
+		f.mu.RUnlock()
+	}
+	return size
+}
+
+func (h *memHandle) ReadAt(p []byte, off int64) (int, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if off >= int64(len(h.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, h.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (h *memHandle) WriteAt(p []byte, off int64) (int, error) {
+	if h.readOnly {
+		return 0, fmt.Errorf("[error] %s is open read only", h.path)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if end := off + int64(len(p)); end > int64(len(h.data)) {
+		h.data = append(h.data, make([]byte, end-int64(len(h.data)))...)
+	}
+	return copy(h.data[off:], p), nil
+}
+
+func (h *memHandle) Size() (int64, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return int64(len(h.data)), nil
+}
+
+func (h *memHandle) Truncate(size int64) error {
+	if h.readOnly {
+		return fmt.Errorf("[error] %s is open read only", h.path)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if size < int64(len(h.data)) {
+		h.data = h.data[:size]
+	} else {
+		h.data = append(h.data, make([]byte, size-int64(len(h.data)))...)
+	}
+	return nil
+}
+
+func (h *memHandle) Sync() error {
+	return nil
+}
+
+func (h *memHandle) Close() error {
+	return nil
+}
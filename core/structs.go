@@ -0,0 +1,261 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+)
+
+/*
+* Struct mapping between Go structs and table rows. Exported fields are mapped to columns by their `bynx` tag, or by the
+* field name when untagged. The tag takes the column name followed by options:
+*
+*	type Faculty struct {
+*		ID    int    `bynx:"id,pk"`
+*		Name  string `bynx:"name"`
+*		Cabin int    `bynx:"cabin,unique"`
+*		Notes string `bynx:"-"`
+*	}
+*
+* Integer fields map to TYPE_INT64, string and []byte fields to TYPE_BYTE.
+ */
+
+const structTag = "bynx"
+
+type structField struct {
+	index  int
+	col    string
+	typ    uint16
+	pk     bool
+	unique bool
+}
+
+var bytesType = reflect.TypeOf([]byte(nil))
+
+func structFields(t reflect.Type) ([]structField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("[error] expected a struct, got: " + t.String())
+	}
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get(structTag)
+		if tag == "-" {
+			continue
+		}
+		sf := structField{index: i, col: f.Name}
+		opts := strings.Split(tag, ",")
+		if opts[0] != "" {
+			sf.col = opts[0]
+		}
+		sf.col = strings.ToUpper(sf.col)
+		for _, opt := range opts[1:] {
+			switch opt {
+			case "pk":
+				sf.pk = true
+			case "unique":
+				sf.unique = true
+			default:
+				return nil, errors.New("[error] unknown bynx tag option: " + opt)
+			}
+		}
+		switch {
+		case isIntKind(f.Type.Kind()):
+			sf.typ = TYPE_INT64
+		case f.Type.Kind() == reflect.String || f.Type == bytesType:
+			sf.typ = TYPE_BYTE
+		default:
+			return nil, errors.New("[error] unsupported field type " + f.Type.String() + " for field: " + f.Name)
+		}
+		fields = append(fields, sf)
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("[error] struct has no mappable fields: " + t.String())
+	}
+	return fields, nil
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// * structValue dereferences v down to the struct it points at.
+func structValue(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return reflect.Value{}, errors.New("[error] nil pointer passed for struct")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, errors.New("[error] expected a struct, got: " + rv.Type().String())
+	}
+	return rv, nil
+}
+
+// * TableDefOf derives a TableDef from the fields of a struct. The field tagged pk becomes the primary key, the first
+// * field if none is tagged.
+func TableDefOf(v any) (*TableDef, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return tableDefOfType(rv.Type())
+}
+
+// * TableDefFor is the generic counterpart of TableDefOf.
+func TableDefFor[T any]() (*TableDef, error) {
+	return tableDefOfType(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func tableDefOfType(t reflect.Type) (*TableDef, error) {
+	fields, err := structFields(t)
+	if err != nil {
+		return nil, err
+	}
+	tD := &TableDef{}
+	pKeys := 0
+	for i, f := range fields {
+		tD.Cols = append(tD.Cols, f.col)
+		tD.Types = append(tD.Types, f.typ)
+		if f.pk {
+			tD.PKeyIndex = i
+			pKeys++
+		}
+		if f.unique {
+			tD.UniqueCols = append(tD.UniqueCols, i)
+		}
+	}
+	if pKeys > 1 {
+		return nil, errors.New("[error] more than one field tagged pk in: " + t.String())
+	}
+	return tD, nil
+}
+
+// * InsertStruct inserts the fields of a struct (or a pointer to one) as a row.
+func (db *DB) InsertStruct(v any) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	fields, err := structFields(rv.Type())
+	if err != nil {
+		return err
+	}
+	values := make(map[string]any, len(fields))
+	for _, f := range fields {
+		val, err := fieldToCol(rv.Field(f.index))
+		if err != nil {
+			return errors.New("[error] column " + f.col + ": " + err.Error())
+		}
+		values[f.col] = val
+	}
+	return db.InsertRow(values)
+}
+
+// * Get loads the row with the given primary key into the struct dest points at.
+func (db *DB) Get(pKeyVal any, dest any) error {
+	row, err := db.GetRow(pKeyVal)
+	if err != nil {
+		return err
+	}
+	return ScanRow(row, dest)
+}
+
+// * ScanRow copies the columns of a row into the matching fields of the struct dest points at. Fields without a
+// * matching column are left untouched.
+func ScanRow(row *Row, dest any) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("[error] ScanRow needs a non nil pointer to a struct")
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return errors.New("[error] ScanRow needs a non nil pointer to a struct")
+	}
+	fields, err := structFields(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		val := row.Get(f.col)
+		if val == nil {
+			continue
+		}
+		if err := colToField(rv.Field(f.index), val); err != nil {
+			return errors.New("[error] column " + f.col + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// * Scan maps a slice of rows onto structs of type T.
+func Scan[T any](rows []*Row) ([]T, error) {
+	ret := make([]T, len(rows))
+	for i, row := range rows {
+		if err := ScanRow(row, &ret[i]); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func fieldToCol(fv reflect.Value) (any, error) {
+	switch {
+	case fv.Type() == bytesType:
+		return append([]byte{}, fv.Bytes()...), nil
+	case fv.Kind() == reflect.String:
+		return []byte(fv.String()), nil
+	case fv.CanInt():
+		return int(fv.Int()), nil
+	case fv.CanUint():
+		// * An int column holds at most MaxInt64, a larger value would wrap negative and sort before the others
+		if fv.Uint() > math.MaxInt64 {
+			return nil, errors.New("value does not fit an integer column")
+		}
+		return int(fv.Uint()), nil
+	}
+	return nil, nil
+}
+
+func colToField(fv reflect.Value, val any) error {
+	switch data := val.(type) {
+	case int:
+		switch {
+		case fv.CanInt():
+			if fv.OverflowInt(int64(data)) {
+				return errors.New("value does not fit in " + fv.Type().String())
+			}
+			fv.SetInt(int64(data))
+		case fv.CanUint():
+			if data < 0 || fv.OverflowUint(uint64(data)) {
+				return errors.New("value does not fit in " + fv.Type().String())
+			}
+			fv.SetUint(uint64(data))
+		default:
+			return errors.New("cannot store integer in " + fv.Type().String())
+		}
+	case []byte:
+		switch {
+		case fv.Type() == bytesType:
+			fv.SetBytes(append([]byte{}, data...))
+		case fv.Kind() == reflect.String:
+			fv.SetString(string(data))
+		default:
+			return errors.New("cannot store bytes in " + fv.Type().String())
+		}
+	default:
+		return errors.New("unsupported column value")
+	}
+	return nil
+}
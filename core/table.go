@@ -1,14 +1,29 @@
 package core
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
 
 const (
 	TYPE_INT64 = 1
 	TYPE_BYTE  = 2
 )
 
+/*
+* Encodings of the keys of the record and index trees. Tables created before the ordered encoding keep little endian
+* integers and length prefixed bytes, whose byte order doesn't follow the value order, so they can't be range scanned.
+ */
+const (
+	KEY_ENCODING_LEGACY  = 0
+	KEY_ENCODING_ORDERED = 1 // * Big endian integers with the sign bit flipped, raw bytes
+)
+
 /*
 * Stores the structure and definition of a table. The primary key will always be stored in index 0. If the pKeyIndex != 0, the columns will be swapped
+* so positional rows follow the stored order. Use the named column API (Row, InsertRow, SelectWhere...) to stay independent of it.
  */
 type TableDef struct {
 	Types     []uint16
@@ -18,11 +33,23 @@ type TableDef struct {
 	*  Indices of columns that have the contraint of being unique.	This tells the database to create a index Tree for that specific column. Starts with 0
 	 */
 	UniqueCols []int
+	// * Set by CollectionCreate, tables stored without it read back as KEY_ENCODING_LEGACY
+	KeyEncoding uint16
+	ForeignKeys []ForeignKey
+	// * Tables with a foreign key to this one, maintained by DbInit
+	ReferencedBy []string
+	// * Default values by column name, used for the columns missing from a named row
+	Defaults map[string]any
+	Checks   []Check
 }
 
 func (tD *TableDef) Serialize(buf []byte) []byte {
 	/*
-	*	| Total Number of Columns | Columns' Types | Columns' Names | Number of Unique Columns | Indices of Unique Columns |
+	*	| Total Number of Columns | Columns' Types | Columns' Names | Number of Unique Columns | Indices of Unique Columns | Key Encoding |
+	*	| Number of Foreign Keys | Foreign Keys (Column, Table, On Delete) | Number of Referencing Tables | Referencing Tables |
+	*	| Number of Defaults | Defaults (Column, Value) | Number of Checks | Checks (Column, Kind, Low, High, Pattern) |
+	*
+	* Names are length prefixed.
 	 */
 	leftPos := 0
 	numOfCol := len(tD.Cols)
@@ -50,10 +77,101 @@ func (tD *TableDef) Serialize(buf []byte) []byte {
 		binary.LittleEndian.PutUint16(buf[leftPos:], uint16(col))
 		leftPos += 2
 	}
+	binary.LittleEndian.PutUint16(buf[leftPos:], tD.KeyEncoding)
+	leftPos += 2
+
+	putName := func(name string) {
+		binary.LittleEndian.PutUint16(buf[leftPos:], uint16(len(name)))
+		leftPos += 2
+		copy(buf[leftPos:], name)
+		leftPos += len(name)
+	}
+	binary.LittleEndian.PutUint16(buf[leftPos:], uint16(len(tD.ForeignKeys)))
+	leftPos += 2
+	for _, fk := range tD.ForeignKeys {
+		putName(fk.Col)
+		putName(fk.RefTable)
+		binary.LittleEndian.PutUint16(buf[leftPos:], uint16(fk.OnDelete))
+		leftPos += 2
+	}
+	binary.LittleEndian.PutUint16(buf[leftPos:], uint16(len(tD.ReferencedBy)))
+	leftPos += 2
+	for _, name := range tD.ReferencedBy {
+		putName(name)
+	}
+
+	// * Defaults are written in column order, each value encoded like a record column
+	binary.LittleEndian.PutUint16(buf[leftPos:], uint16(len(tD.Defaults)))
+	leftPos += 2
+	for i, col := range tD.Cols {
+		val, ok := tD.Defaults[col]
+		if !ok {
+			continue
+		}
+		putName(col)
+		encoded, _ := checkTypeAndEncodeByte(tD, i, val, nil)
+		copy(buf[leftPos:], encoded)
+		leftPos += len(encoded)
+	}
+	binary.LittleEndian.PutUint16(buf[leftPos:], uint16(len(tD.Checks)))
+	leftPos += 2
+	for _, c := range tD.Checks {
+		putName(c.Col)
+		binary.LittleEndian.PutUint16(buf[leftPos:], uint16(c.Kind))
+		leftPos += 2
+		binary.LittleEndian.PutUint64(buf[leftPos:], uint64(c.Low))
+		leftPos += 8
+		binary.LittleEndian.PutUint64(buf[leftPos:], uint64(c.High))
+		leftPos += 8
+		putName(c.Pattern)
+	}
 	return buf
 }
 
-func (tD *TableDef) Deserialize(buf []byte) {
+// * serializedSize returns the number of bytes Serialize writes.
+func (tD *TableDef) serializedSize() int {
+	size := 2 + 4*len(tD.Cols) + 2 + 2*len(tD.UniqueCols) + 2
+	for _, col := range tD.Cols {
+		size += len(col)
+	}
+	size += 2
+	for _, fk := range tD.ForeignKeys {
+		size += 2 + len(fk.Col) + 2 + len(fk.RefTable) + 2
+	}
+	size += 2
+	for _, name := range tD.ReferencedBy {
+		size += 2 + len(name)
+	}
+	size += 2
+	for i, col := range tD.Cols {
+		if val, ok := tD.Defaults[col]; ok {
+			encoded, _ := checkTypeAndEncodeByte(tD, i, val, nil)
+			size += 2 + len(col) + len(encoded)
+		}
+	}
+	size += 2
+	for _, c := range tD.Checks {
+		size += 2 + len(c.Col) + 2 + 8 + 8 + 2 + len(c.Pattern)
+	}
+	return size
+}
+
+// * checkFits refuses a table definition that doesn't fit the table definition page of a file with pages of pageSize.
+func (tD *TableDef) checkFits(pageSize int) error {
+	if size, limit := tD.serializedSize(), pageSize-pageHeaderSize; size > limit {
+		return fmt.Errorf("[error] the table definition takes %d bytes, a page holds %d", size, limit)
+	}
+	return nil
+}
+
+// * Deserialize decodes a table definition page. Lengths running past the page, which the checksum of the page rules
+// * out for anything Serialize wrote, are an error rather than a panic.
+func (tD *TableDef) Deserialize(buf []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("malformed table definition")
+		}
+	}()
 	leftPos := 0
 
 	numOfCol := int(binary.LittleEndian.Uint16(buf[0:2]))
@@ -77,5 +195,72 @@ func (tD *TableDef) Deserialize(buf []byte) {
 		tD.UniqueCols = append(tD.UniqueCols, int(binary.LittleEndian.Uint16(buf[leftPos:])))
 		leftPos += 2
 	}
+	// * Zero on pages written before the field existed, like the counts that follow
+	tD.KeyEncoding = binary.LittleEndian.Uint16(buf[leftPos:])
+	leftPos += 2
 
+	getName := func() string {
+		nameLen := int(binary.LittleEndian.Uint16(buf[leftPos:]))
+		leftPos += 2
+		name := string(buf[leftPos : leftPos+nameLen])
+		leftPos += nameLen
+		return name
+	}
+	noForeignKeys := int(binary.LittleEndian.Uint16(buf[leftPos:]))
+	leftPos += 2
+	tD.ForeignKeys = nil
+	for i := 0; i < noForeignKeys; i++ {
+		fk := ForeignKey{Col: getName(), RefTable: getName()}
+		fk.OnDelete = FKAction(binary.LittleEndian.Uint16(buf[leftPos:]))
+		leftPos += 2
+		tD.ForeignKeys = append(tD.ForeignKeys, fk)
+	}
+	noReferencing := int(binary.LittleEndian.Uint16(buf[leftPos:]))
+	leftPos += 2
+	tD.ReferencedBy = nil
+	for i := 0; i < noReferencing; i++ {
+		tD.ReferencedBy = append(tD.ReferencedBy, getName())
+	}
+
+	noDefaults := int(binary.LittleEndian.Uint16(buf[leftPos:]))
+	leftPos += 2
+	tD.Defaults = nil
+	for i := 0; i < noDefaults; i++ {
+		col := getName()
+		colIndex, err := tD.ColIndex(col)
+		if err != nil {
+			break
+		}
+		val, offset := checkTypeAndDecodeCol(tD, colIndex, buf[leftPos:])
+		leftPos += offset
+		if tD.Defaults == nil {
+			tD.Defaults = map[string]any{}
+		}
+		tD.Defaults[col] = val
+	}
+	noChecks := int(binary.LittleEndian.Uint16(buf[leftPos:]))
+	leftPos += 2
+	tD.Checks = nil
+	for i := 0; i < noChecks; i++ {
+		c := Check{Col: getName()}
+		c.Kind = CheckKind(binary.LittleEndian.Uint16(buf[leftPos:]))
+		leftPos += 2
+		c.Low = int(binary.LittleEndian.Uint64(buf[leftPos:]))
+		leftPos += 8
+		c.High = int(binary.LittleEndian.Uint64(buf[leftPos:]))
+		leftPos += 8
+		c.Pattern = getName()
+		tD.Checks = append(tD.Checks, c)
+	}
+	return nil
+}
+
+// * ColIndex returns the position of the named column. Column names are stored upper case, so the lookup ignores case.
+func (tD *TableDef) ColIndex(name string) (int, error) {
+	for i, col := range tD.Cols {
+		if strings.EqualFold(col, name) {
+			return i, nil
+		}
+	}
+	return -1, errors.New("[error] no such column: " + name)
 }
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"BynxDB/core/utils"
+	"errors"
+	"os"
+)
+
+// * Truncate deletes every row of the table. The records tree and every index tree are reset to an empty root and
+// * their pages are given back, the files shrinking to the meta, freelist and table definition pages. A table other
+// * tables still reference rows of can't be truncated.
+func (db *DB) Truncate() (err error) {
+	utils.Info(1, "==Truncate Call==", db.name())
+	if err := db.checkWritable(); err != nil {
+		return err
+	}
+	defer db.beginWrite()(&err)
+	if err := db.checkNoBackup("truncate"); err != nil {
+		return err
+	}
+	if err := db.checkUnreferenced(); err != nil {
+		return err
+	}
+	for _, c := range db.collections() {
+		if err := c.truncate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// * Drop closes the table and removes its files. The table is taken out of the ReferencedBy list of the tables it
+// * references. A table with foreign keys of other tables pointing at it can't be dropped, even when no row
+// * references it, as the keys would be left without a table. The DB can't be used afterwards.
+func (db *DB) Drop() error {
+	name := db.name()
+	utils.Info(1, "==Drop Call==", name)
+	if err := db.checkWritable(); err != nil {
+		return err
+	}
+	if err := db.checkNoBackup("drop"); err != nil {
+		return err
+	}
+	for _, ref := range db.records.ReferencedBy {
+		if ref != name {
+			return errors.New("[error] can't drop " + name + ", it is referenced by " + ref)
+		}
+	}
+	if err := db.unlinkParents(); err != nil {
+		return err
+	}
+	var paths []string
+	for _, c := range db.collections() {
+		path, err := collectionPath(c.Name)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+	}
+	// * A journal is only left behind by a write that failed
+	paths = append(paths, db.journal.path)
+	db.Close()
+	for _, path := range paths {
+		if err := db.opts.storage().Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// * checkUnreferenced refuses to truncate a table while rows of other tables reference it. References of a table to
+// * itself go away with its rows. Only a SET NULL key holding its zero value references no row.
+func (db *DB) checkUnreferenced() error {
+	if len(db.records.ReferencedBy) == 0 {
+		return nil
+	}
+	refs, done, err := db.childRefs()
+	if err != nil {
+		return err
+	}
+	defer done()
+	for _, ref := range refs {
+		if ref.child == db {
+			continue
+		}
+		var preds []Predicate
+		if ref.fk.OnDelete == FK_SET_NULL {
+			colIndex, _ := ref.child.records.ColIndex(ref.fk.Col)
+			preds = append(preds, Ne(ref.fk.Col, zeroValue(ref.child.records.Types[colIndex])))
+		}
+		plan, err := ref.child.Plan(preds...)
+		if err != nil {
+			return err
+		}
+		referenced := false
+		err = plan.Each(func([]any) (bool, error) {
+			referenced = true
+			return false, nil
+		})
+		if err != nil {
+			return err
+		}
+		if referenced {
+			return errors.New("[error] can't truncate " + db.name() + ", it is referenced by " +
+				ref.child.name() + "." + ref.fk.Col)
+		}
+	}
+	return nil
+}
+
+// * truncate empties the tree. Every page but the meta, freelist and table definition pages is given back: the file
+// * is cut after the last of those and a new empty root follows it.
+func (c *Collection) truncate() error {
+	d := c.DAL
+	last := max(d.freelistPage, d.TableDefPage)
+	d.freeList.maxPage = last + 1
+	d.freeList.releasedPages = d.freeList.releasedPages[:0]
+	for pg := pgNum(metaPageNum + 1); pg < last; pg++ {
+		if pg != d.freelistPage && pg != d.TableDefPage {
+			d.ReleasedPage(pg)
+		}
+	}
+	if err := d.truncate(last + 1); err != nil {
+		return err
+	}
+	d.Root = d.GetNextPage()
+	if _, err := d.Writenode(&Node{Pagenum: d.Root}); err != nil {
+		return err
+	}
+	d.RowCount, d.rowCountKnown = 0, true
+	return d.commit()
+}
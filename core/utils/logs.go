@@ -2,20 +2,29 @@ package utils
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"strconv"
 )
 
 const logsFilePath = "logs/app.log"
 
 var logDepth int = int(^uint(0) >> 1)
 
+// * console receives the messages that are printed besides being logged
+var console io.Writer = os.Stdout
+
+// * SetLogDepth sets the highest priority of Info messages that are still logged.
+func SetLogDepth(depth int) {
+	logDepth = depth
+}
+
+// * SetConsole redirects the printed messages, io.Discard silences them.
+func SetConsole(w io.Writer) {
+	console = w
+}
+
 func InitFileLogs() {
-	if len(os.Args) > 1 {
-		fmt.Println(os.Args[1])
-		logDepth, _ = strconv.Atoi(os.Args[1])
-	}
 	// Ensure the logs directory exists
 	logsDir := "logs"
 	if _, err := os.Stat(logsDir); os.IsNotExist(err) {
@@ -38,12 +47,12 @@ func Info(priority int, msg ...any) {
 	}
 }
 func InfoLogAndPrint(msg ...any) {
-	fmt.Println("INFO:", msg)
+	fmt.Fprintln(console, "INFO:", msg)
 	log.Println("INFO:", msg)
 }
 
 func Error(msg ...any) {
-	fmt.Println("ERROR:", msg)
+	fmt.Fprintln(console, "ERROR:", msg)
 	log.Println("ERROR:", msg)
 }
 
@@ -51,7 +60,7 @@ func Warn(msg ...any) {
 	log.Println("WARN:", msg)
 }
 func FatalError(msg ...any) {
-	fmt.Println("ERROR:", msg)
+	fmt.Fprintln(console, "ERROR:", msg)
 	log.Fatalln("ERROR:", msg)
 }
 
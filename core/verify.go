@@ -0,0 +1,298 @@
+package core
+
+import (
+	"BynxDB/core/utils"
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// * Problem is an inconsistency found by Verify. Page is the page it was found on, 0 for problems of a whole tree.
+type Problem struct {
+	Tree string
+	Page uint64
+	Msg  string
+}
+
+func (p Problem) String() string {
+	if p.Page == 0 {
+		return p.Tree + ": " + p.Msg
+	}
+	return fmt.Sprintf("%s page %d: %s", p.Tree, p.Page, p.Msg)
+}
+
+// * TreeStats describes one tree walked by Verify. Underfilled and Overfilled count the nodes besides the root
+// * outside the MinFillPercent and MaxFillPercent bounds.
+type TreeStats struct {
+	Tree        string
+	Pages       uint64
+	FreePages   int
+	Nodes       int
+	Items       uint64
+	Depth       int
+	Underfilled int
+	Overfilled  int
+}
+
+// * VerifyReport is what Verify found: a TreeStats for every tree and the problems, none for healthy files. Warnings
+// * name the nodes outside the fill bounds, which don't make the files inconsistent.
+type VerifyReport struct {
+	Trees    []TreeStats
+	Problems []Problem
+	Warnings []Problem
+}
+
+// * VerifyError is returned by Verify when the files of a table are inconsistent.
+type VerifyError struct {
+	Problems []Problem
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("[error] verify found %d problems, first: %s", len(e.Problems), e.Problems[0])
+}
+
+/*
+* Verify checks the files of the table. The report describes every tree and lists the problems found, which are also
+* returned as a *VerifyError. Every tree is walked from its root:
+*
+*	- keys are in order within a node and lie between the keys of the parent around the node
+*	- child pointers point inside the file, at pages nothing else uses, and all leaves are at the same depth
+*	- no node but the root is empty, every node fits its page and every page has a valid header
+*	- every page is the freelist, the table definition, a node of the tree or released, exactly one of them
+*	- the row count in the meta page matches the items of the tree
+*
+* The fill bounds are only kept loosely by the tree: a split may leave a single item in the new node and a merge isn't
+* split again, so nodes outside MinFillPercent and MaxFillPercent are counted in the TreeStats and listed as warnings
+* rather than problems.
+* When the trees are sound, every row is looked up in every unique index and every index entry in the records.
+* Other errors are returned when the files can't be read.
+ */
+func (db *DB) Verify() (*VerifyReport, error) {
+	utils.Info(1, "==Verify Call==", db.name())
+	report := &VerifyReport{}
+	for _, c := range db.collections() {
+		t, err := c.verify()
+		if err != nil {
+			return nil, err
+		}
+		report.Trees = append(report.Trees, t.stats)
+		report.Problems = append(report.Problems, t.problems...)
+		report.Warnings = append(report.Warnings, t.warnings...)
+	}
+	if len(report.Problems) == 0 {
+		problems, err := db.verifyIndexes()
+		if err != nil {
+			return nil, err
+		}
+		report.Problems = problems
+	}
+	if len(report.Problems) != 0 {
+		return report, &VerifyError{Problems: report.Problems}
+	}
+	return report, nil
+}
+
+// * treeCheck collects the stats and problems of one tree while it is walked. used maps every page seen to what it
+// * holds.
+type treeCheck struct {
+	c         *Collection
+	stats     TreeStats
+	problems  []Problem
+	warnings  []Problem
+	used      map[pgNum]string
+	leafDepth int
+}
+
+func (t *treeCheck) report(page pgNum, format string, args ...any) {
+	t.problems = append(t.problems, Problem{Tree: string(t.c.Name), Page: uint64(page), Msg: fmt.Sprintf(format, args...)})
+}
+
+func (t *treeCheck) warn(page pgNum, format string, args ...any) {
+	t.warnings = append(t.warnings, Problem{Tree: string(t.c.Name), Page: uint64(page), Msg: fmt.Sprintf(format, args...)})
+}
+
+// * use records what a page holds. It reports pages outside the file or already in use and returns false for them.
+func (t *treeCheck) use(page pgNum, what string) bool {
+	if page == metaPageNum || page >= t.c.DAL.maxPage {
+		t.report(page, "%s outside the file of %d pages", what, t.c.DAL.maxPage)
+		return false
+	}
+	if prev, ok := t.used[page]; ok {
+		t.report(page, "used as %s and as %s", prev, what)
+		return false
+	}
+	t.used[page] = what
+	return true
+}
+
+// * verify walks the tree and accounts for every page of its file.
+func (c *Collection) verify() (*treeCheck, error) {
+	d := c.DAL
+	t := &treeCheck{c: c, used: map[pgNum]string{}, leafDepth: -1}
+	t.stats = TreeStats{Tree: string(c.Name), Pages: uint64(d.maxPage), FreePages: len(d.releasedPages)}
+	for _, special := range []struct {
+		page pgNum
+		typ  pageType
+	}{{d.freelistPage, PAGE_FREELIST}, {d.TableDefPage, PAGE_TABLEDEF}} {
+		if !t.use(special.page, special.typ.String()) {
+			continue
+		}
+		var corrupt *CorruptPageError
+		if _, err := d.readPageOf(special.page, special.typ); errors.As(err, &corrupt) {
+			t.report(special.page, "%s", corrupt.Reason)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if err := t.walk(d.Root, nil, nil, 0); err != nil {
+		return nil, err
+	}
+	t.stats.Depth = t.leafDepth + 1
+	for _, page := range d.releasedPages {
+		t.use(page, "released page")
+	}
+	for page := pgNum(metaPageNum + 1); page < d.maxPage; page++ {
+		if _, ok := t.used[page]; !ok {
+			t.report(page, "leaked, neither reachable nor on the freelist")
+		}
+	}
+	size, err := d.file.Size()
+	if err != nil {
+		return nil, err
+	}
+	if pages := pgNum(size / int64(d.pageSize)); pages > d.maxPage {
+		t.report(d.freelistPage, "the freelist ends at page %d of a file of %d pages", d.maxPage, pages)
+	}
+	if d.rowCountKnown && d.RowCount != t.stats.Items {
+		t.report(metaPageNum, "row count %d, the tree holds %d items", d.RowCount, t.stats.Items)
+	}
+	return t, nil
+}
+
+// * walk checks the subtree at page, whose keys have to lie between low and high, nil being open.
+func (t *treeCheck) walk(page pgNum, low []byte, high []byte, depth int) error {
+	if !t.use(page, "node") {
+		return nil
+	}
+	node, err := t.readNode(page)
+	if err != nil || node == nil {
+		return err
+	}
+	isRoot := page == t.c.DAL.Root
+	if size := node.nodeSize(); size > t.c.DAL.pageSize-pageHeaderSize {
+		t.report(page, "node of %d bytes doesn't fit a page", size)
+	}
+	if !node.Isleaf() && len(node.Childnodes) != len(node.Items)+1 {
+		t.report(page, "%d items with %d children", len(node.Items), len(node.Childnodes))
+		return nil
+	}
+	for i, item := range node.Items {
+		if i > 0 && bytes.Compare(node.Items[i-1].Key, item.Key) >= 0 {
+			t.report(page, "key %d out of order", i)
+		}
+		if (low != nil && bytes.Compare(item.Key, low) <= 0) || (high != nil && bytes.Compare(item.Key, high) >= 0) {
+			t.report(page, "key %d outside the range of its parent", i)
+		}
+	}
+	switch {
+	case len(node.Items) == 0 && (!isRoot || !node.Isleaf()):
+		t.report(page, "node without items")
+	case isRoot:
+	case node.isOverPopulated():
+		t.stats.Overfilled++
+		t.warn(page, "node of %d bytes over MaxFillPercent, %d bytes", node.nodeSize(), int(t.c.DAL.maxThreshold()))
+	case node.isUnderPopulated():
+		t.stats.Underfilled++
+		t.warn(page, "node of %d bytes under MinFillPercent, %d bytes", node.nodeSize(), int(t.c.DAL.minThreshold()))
+	}
+	t.stats.Nodes++
+	t.stats.Items += uint64(len(node.Items))
+
+	if node.Isleaf() {
+		if t.leafDepth == -1 {
+			t.leafDepth = depth
+		} else if depth != t.leafDepth {
+			t.report(page, "leaf at depth %d, other leaves are at depth %d", depth, t.leafDepth)
+		}
+		return nil
+	}
+	for i, child := range node.Childnodes {
+		childLow, childHigh := low, high
+		if i > 0 {
+			childLow = node.Items[i-1].Key
+		}
+		if i < len(node.Items) {
+			childHigh = node.Items[i].Key
+		}
+		if err := t.walk(child, childLow, childHigh, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// * readNode reads a node, reporting a corrupt page instead of failing.
+func (t *treeCheck) readNode(page pgNum) (*Node, error) {
+	node, err := t.c.DAL.Getnode(page)
+	var corrupt *CorruptPageError
+	if errors.As(err, &corrupt) {
+		t.report(page, "%s", corrupt.Reason)
+		return nil, nil
+	}
+	return node, err
+}
+
+// * verifyIndexes checks that every row has its entries in the unique indexes and every entry points at its row.
+func (db *DB) verifyIndexes() ([]Problem, error) {
+	tD := db.records.TableDef
+	var problems []Problem
+	report := func(c *Collection, format string, args ...any) {
+		problems = append(problems, Problem{Tree: string(c.Name), Msg: fmt.Sprintf(format, args...)})
+	}
+	for i, colIndex := range tD.UniqueCols {
+		index := db.uniqueColumnsTree[i]
+		err := db.records.Scan(nil, nil, func(item *Item) (bool, error) {
+			row := append([]any{decodeKey(tD, 0, item.Key)}, decodeRow(tD, item.Value)...)
+			indexKey, err := encodeKey(tD, colIndex, row[colIndex])
+			if err != nil {
+				return false, err
+			}
+			entry, err := index.Find(indexKey)
+			switch {
+			case err != nil:
+				return false, err
+			case entry == nil:
+				report(index, "row %s has no entry for %s", formatValue(row[0]), formatValue(row[colIndex]))
+			case !bytes.Equal(entry.Value, item.Key):
+				report(index, "entry for %s points at row %s instead of %s", formatValue(row[colIndex]),
+					formatValue(decodeKey(tD, 0, entry.Value)), formatValue(row[0]))
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = index.Scan(nil, nil, func(entry *Item) (bool, error) {
+			val := decodeKey(tD, colIndex, entry.Key)
+			record, err := db.records.Find(entry.Value)
+			if err != nil {
+				return false, err
+			}
+			if record == nil {
+				report(index, "entry for %s points at missing row %s", formatValue(val),
+					formatValue(decodeKey(tD, 0, entry.Value)))
+				return true, nil
+			}
+			row := append([]any{decodeKey(tD, 0, record.Key)}, decodeRow(tD, record.Value)...)
+			if indexKey, err := encodeKey(tD, colIndex, row[colIndex]); err != nil || !bytes.Equal(indexKey, entry.Key) {
+				report(index, "entry for %s points at row %s holding %s", formatValue(val), formatValue(row[0]),
+					formatValue(row[colIndex]))
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return problems, nil
+}
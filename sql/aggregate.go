@@ -0,0 +1,180 @@
+package sql
+
+import (
+	"BynxDB/core"
+	"bytes"
+	"cmp"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// * TYPE_FLOAT64 is the type of computed float columns such as AVG. Tables can't store it.
+const TYPE_FLOAT64 = 3
+
+var aggregateFuncOf = map[string]core.AggFunc{
+	"COUNT": core.AGG_COUNT,
+	"SUM":   core.AGG_SUM,
+	"MIN":   core.AGG_MIN,
+	"MAX":   core.AGG_MAX,
+	"AVG":   core.AGG_AVG,
+}
+
+// * aggregateRows runs a SELECT with aggregates or GROUP BY through DB.Aggregate. Plain columns of the select list
+// * have to be grouped by. Groups come ordered by the GROUP BY columns unless ORDER BY names another output column.
+func (e *Engine) aggregateRows(db *core.DB, tD *core.TableDef, s *Select) (*Result, error) {
+	aggs, opts, preds, _, err := aggregateOptions(tD, s)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := db.Aggregate(aggs, opts, preds...)
+	if err != nil {
+		return nil, err
+	}
+
+	// * Types of the columns DB.Aggregate returns, the group columns first
+	var types []uint16
+	for _, col := range s.GroupBy {
+		colIndex, _ := tD.ColIndex(col)
+		types = append(types, tD.Types[colIndex])
+	}
+	for _, agg := range aggs {
+		switch agg.Func {
+		case core.AGG_MIN, core.AGG_MAX:
+			colIndex, _ := tD.ColIndex(agg.Col)
+			types = append(types, tD.Types[colIndex])
+		case core.AGG_AVG:
+			types = append(types, TYPE_FLOAT64)
+		default:
+			types = append(types, core.TYPE_INT64)
+		}
+	}
+
+	// * Put the columns back in the order of the select list
+	var order []int
+	if len(s.Cols) == 0 && len(s.Aggregates) == 0 {
+		for i := range rs.Columns {
+			order = append(order, i)
+		}
+	}
+	nextCol, nextAgg := 0, 0
+	for pos := 0; pos < len(s.Cols)+len(s.Aggregates); pos++ {
+		if nextAgg < len(s.Aggregates) && s.Aggregates[nextAgg].Pos == pos {
+			order = append(order, len(s.GroupBy)+nextAgg)
+			nextAgg++
+			continue
+		}
+		col := s.Cols[nextCol]
+		nextCol++
+		groupIndex := -1
+		for i, groupCol := range s.GroupBy {
+			if strings.EqualFold(groupCol, col) {
+				groupIndex = i
+			}
+		}
+		if groupIndex == -1 {
+			return nil, errors.New("[error] column " + strings.ToUpper(col) + " has to be in GROUP BY")
+		}
+		order = append(order, groupIndex)
+	}
+
+	res := &Result{}
+	for _, i := range order {
+		res.Columns = append(res.Columns, Column{Name: rs.Columns[i], Type: types[i]})
+	}
+	for _, row := range rs.Rows {
+		out := make([]any, len(order))
+		for i, j := range order {
+			out[i] = row[j]
+		}
+		res.Rows = append(res.Rows, out)
+	}
+
+	if s.OrderBy != "" {
+		orderIndex := -1
+		for i, col := range res.Columns {
+			if strings.EqualFold(col.Name, s.OrderBy) {
+				orderIndex = i
+			}
+		}
+		if orderIndex == -1 {
+			return nil, errors.New("[error] ORDER BY column is not selected: " + s.OrderBy)
+		}
+		sort.SliceStable(res.Rows, func(i, j int) bool {
+			c := compareResultValues(res.Rows[i][orderIndex], res.Rows[j][orderIndex])
+			if s.Desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	if s.Offset >= len(res.Rows) {
+		res.Rows = nil
+	} else {
+		res.Rows = res.Rows[s.Offset:]
+	}
+	if s.Limit >= 0 && len(res.Rows) > s.Limit {
+		res.Rows = res.Rows[:s.Limit]
+	}
+	return res, nil
+}
+
+// * aggregateOptions maps a SELECT onto the arguments of DB.Aggregate. Like selectOptions it returns the conditions
+// * applied through the filter for EXPLAIN.
+func aggregateOptions(tD *core.TableDef, s *Select) ([]core.Aggregate, *core.AggregateOptions, []core.Predicate, Expr, error) {
+	preds, rest, err := splitWhere(tD, s.Where)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	var aggs []core.Aggregate
+	for _, agg := range s.Aggregates {
+		aggs = append(aggs, core.Aggregate{Func: aggregateFuncOf[agg.Func], Col: agg.Col})
+	}
+	opts := &core.AggregateOptions{GroupBy: s.GroupBy}
+	if rest != nil {
+		opts.Filter = func(row []any) bool {
+			return evalExpr(tD, rest, row)
+		}
+	}
+	return aggs, opts, preds, rest, nil
+}
+
+// * aggregateString describes the aggregation step of EXPLAIN.
+func aggregateString(s *Select) string {
+	explain := "AGGREGATE"
+	for i, agg := range s.Aggregates {
+		col := "*"
+		if agg.Col != "" {
+			col = strings.ToUpper(agg.Col)
+		}
+		if i > 0 {
+			explain += ","
+		}
+		explain += " " + agg.Func + "(" + col + ")"
+	}
+	if len(s.GroupBy) != 0 {
+		explain += " GROUP BY " + strings.ToUpper(strings.Join(s.GroupBy, ", "))
+	}
+	return explain
+}
+
+// * compareResultValues orders values of a result column, NULL first.
+func compareResultValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	switch x := a.(type) {
+	case int:
+		return cmp.Compare(x, b.(int))
+	case float64:
+		return cmp.Compare(x, b.(float64))
+	case []byte:
+		return bytes.Compare(x, b.([]byte))
+	}
+	return 0
+}
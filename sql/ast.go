@@ -0,0 +1,136 @@
+package sql
+
+import "BynxDB/core"
+
+// * Statement is one parsed SQL statement.
+type Statement interface {
+	statement()
+}
+
+// * ColumnDef is a column of a CREATE TABLE statement. References names the parent table of a foreign key, RefCol
+// * its primary key when the statement spells it out. Default is nil without a DEFAULT.
+type ColumnDef struct {
+	Name       string
+	Type       uint16
+	PrimaryKey bool
+	Unique     bool
+	References string
+	RefCol     string
+	OnDelete   core.FKAction
+	Default    any
+}
+
+// * CreateTable holds the CHECK constraints of the columns and of the table in one list.
+type CreateTable struct {
+	Table  string
+	Cols   []ColumnDef
+	Checks []core.Check
+}
+
+// * Insert holds one or more rows of literals. Cols is empty when the statement lists no columns.
+type Insert struct {
+	Table string
+	Cols  []string
+	Rows  [][]any
+}
+
+// * Select reads the given columns, or every column when Cols is empty (SELECT *). Limit is -1 without LIMIT.
+type Select struct {
+	Table      string
+	Cols       []string
+	Aggregates []Aggregate
+	Where      Expr
+	GroupBy    []string
+	OrderBy    string
+	Desc       bool
+	Limit      int
+	Offset     int
+}
+
+// * Aggregate is an aggregate function of the select list, Col is empty for COUNT(*). Pos is its place in the list,
+// * counting the plain columns too.
+type Aggregate struct {
+	Func string
+	Col  string
+	Pos  int
+}
+
+type Assignment struct {
+	Col   string
+	Value any
+}
+
+type Update struct {
+	Table string
+	Set   []Assignment
+	Where Expr
+}
+
+type Delete struct {
+	Table string
+	Where Expr
+}
+
+// * Truncate deletes every row of a table, Drop removes the table.
+type Truncate struct {
+	Table string
+}
+
+type Drop struct {
+	Table string
+}
+
+// * Explain reports the plan of a SELECT, UPDATE or DELETE instead of running it.
+type Explain struct {
+	Stmt Statement
+}
+
+func (s *Select) aggregated() bool {
+	return len(s.Aggregates) != 0 || len(s.GroupBy) != 0
+}
+
+// * shaped reports whether the rows have to be ordered or paged rather than returned in plan order.
+func (s *Select) shaped() bool {
+	return s.OrderBy != "" || s.Limit >= 0 || s.Offset > 0
+}
+
+func (*CreateTable) statement() {}
+func (*Insert) statement()      {}
+func (*Select) statement()      {}
+func (*Update) statement()      {}
+func (*Delete) statement()      {}
+func (*Truncate) statement()    {}
+func (*Drop) statement()        {}
+func (*Explain) statement()     {}
+
+// * Expr is a WHERE condition. Literal values are int or []byte, matching the column types of the core package.
+type Expr interface {
+	expr()
+}
+
+// * Comparison compares a column with a literal. Op is one of =, <>, <, <=, >, >=.
+type Comparison struct {
+	Col   string
+	Op    string
+	Value any
+}
+
+// * Between matches a column lying between Low and High, both inclusive.
+type Between struct {
+	Col  string
+	Low  any
+	High any
+}
+
+type And struct {
+	Left, Right Expr
+}
+
+type Or struct {
+	Left, Right Expr
+}
+
+func (*Comparison) expr() {}
+func (*Between) expr()    {}
+func (*And) expr()        {}
+func (*Or) expr()         {}
@@ -0,0 +1,632 @@
+package sql
+
+import (
+	"BynxDB/core"
+	"BynxDB/core/utils"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// * Engine executes statements against the tables in the db directory. Tables are opened on first use and stay open
+// * until Close.
+type Engine struct {
+	tables map[string]*core.DB
+	opts   *core.Options
+}
+
+func NewEngine() *Engine {
+	return NewEngineWithOptions(nil)
+}
+
+// * NewEngineWithOptions returns an engine opening the tables with opts, nil for the defaults.
+func NewEngineWithOptions(opts *core.Options) *Engine {
+	return &Engine{tables: map[string]*core.DB{}, opts: opts}
+}
+
+// * Exec parses and executes a single statement.
+func (e *Engine) Exec(query string) (*Result, error) {
+	stmt, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	return e.Execute(stmt)
+}
+
+// * Execute runs an already parsed statement.
+func (e *Engine) Execute(stmt Statement) (*Result, error) {
+	switch s := stmt.(type) {
+	case *CreateTable:
+		return e.createTable(s)
+	case *Insert:
+		return e.insert(s)
+	case *Select:
+		return e.selectRows(s)
+	case *Update:
+		return e.update(s)
+	case *Delete:
+		return e.delete(s)
+	case *Truncate:
+		db, err := e.Table(s.Table)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{}, db.Truncate()
+	case *Drop:
+		return e.drop(s)
+	case *Explain:
+		return e.explain(s)
+	}
+	return nil, fmt.Errorf("[error] unsupported statement: %T", stmt)
+}
+
+// * Close closes every table opened by the engine.
+func (e *Engine) Close() {
+	for name, db := range e.tables {
+		db.Close()
+		delete(e.tables, name)
+	}
+}
+
+// * Table returns the open table with the given name, opening it if needed.
+func (e *Engine) Table(name string) (*core.DB, error) {
+	name = strings.ToUpper(name)
+	if db, ok := e.tables[name]; ok {
+		return db, nil
+	}
+	db, err := core.OpenDBWithOptions(name, e.opts)
+	if err != nil {
+		return nil, err
+	}
+	e.tables[name] = db
+	return db, nil
+}
+
+func (e *Engine) createTable(s *CreateTable) (*Result, error) {
+	utils.Info(2, "==SQL Create Table==", s.Table)
+	exists, err := core.TableExistsWithOptions(s.Table, e.opts)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("[error] table already exists: " + s.Table)
+	}
+	if e.opts != nil && e.opts.ReadOnly {
+		return nil, core.ErrReadOnly
+	}
+	if len(s.Cols) == 0 {
+		return nil, errors.New("[error] table needs at least one column: " + s.Table)
+	}
+	tD := &core.TableDef{}
+	pKeys := 0
+	for i, col := range s.Cols {
+		if _, err := tD.ColIndex(col.Name); err == nil {
+			return nil, errors.New("[error] duplicate column: " + col.Name)
+		}
+		tD.Cols = append(tD.Cols, col.Name)
+		tD.Types = append(tD.Types, col.Type)
+		if col.PrimaryKey {
+			tD.PKeyIndex = i
+			pKeys++
+		}
+		if col.Unique && !col.PrimaryKey {
+			tD.UniqueCols = append(tD.UniqueCols, i)
+		}
+		if col.References != "" {
+			if err := e.checkRefCol(s, col); err != nil {
+				return nil, err
+			}
+			tD.ForeignKeys = append(tD.ForeignKeys, core.ForeignKey{Col: col.Name, RefTable: col.References, OnDelete: col.OnDelete})
+		}
+		if col.Default != nil {
+			if tD.Defaults == nil {
+				tD.Defaults = map[string]any{}
+			}
+			tD.Defaults[strings.ToUpper(col.Name)] = col.Default
+		}
+	}
+	tD.Checks = s.Checks
+	if pKeys > 1 {
+		return nil, errors.New("[error] more than one primary key in table: " + s.Table)
+	}
+	db, err := core.DbInitWithOptions(s.Table, tD, e.opts)
+	if err != nil {
+		return nil, err
+	}
+	e.tables[strings.ToUpper(s.Table)] = db
+	return &Result{}, nil
+}
+
+// * checkRefCol checks that a foreign key spelling out the referenced column names the primary key of the parent.
+func (e *Engine) checkRefCol(s *CreateTable, col ColumnDef) error {
+	if col.RefCol == "" {
+		return nil
+	}
+	var pKey string
+	if strings.EqualFold(col.References, s.Table) {
+		pKey = strings.ToUpper(s.Cols[0].Name)
+		for _, c := range s.Cols {
+			if c.PrimaryKey {
+				pKey = strings.ToUpper(c.Name)
+			}
+		}
+	} else {
+		parent, err := e.Table(col.References)
+		if err != nil {
+			return err
+		}
+		pKey = parent.TableDef().Cols[0]
+	}
+	if !strings.EqualFold(col.RefCol, pKey) {
+		return errors.New("[error] foreign key " + col.Name + " has to reference the primary key " + pKey + " of " +
+			strings.ToUpper(col.References))
+	}
+	return nil
+}
+
+// * insert writes the rows of an INSERT. Without a column list the values follow the stored column order, which has
+// * the primary key first. Columns left out of a column list take their default. A statement with several rows is
+// * inserted as one batch.
+func (e *Engine) insert(s *Insert) (*Result, error) {
+	db, err := e.Table(s.Table)
+	if err != nil {
+		return nil, err
+	}
+	tD := db.TableDef()
+	positions := make([]int, len(tD.Cols))
+	if len(s.Cols) == 0 {
+		for i := range positions {
+			positions[i] = i
+		}
+	} else {
+		positions = positions[:len(s.Cols)]
+		seen := map[int]bool{}
+		for i, col := range s.Cols {
+			colIndex, err := tD.ColIndex(col)
+			if err != nil {
+				return nil, err
+			}
+			if seen[colIndex] {
+				return nil, errors.New("[error] duplicate column: " + col)
+			}
+			seen[colIndex] = true
+			positions[i] = colIndex
+		}
+		for i, col := range tD.Cols {
+			if _, ok := tD.Defaults[col]; !seen[i] && !ok {
+				return nil, errors.New("[error] missing value for column: " + col)
+			}
+		}
+	}
+	rows := make([][]any, 0, len(s.Rows))
+	for _, values := range s.Rows {
+		if len(values) != len(positions) {
+			return nil, fmt.Errorf("[error] expected %d values, got %d", len(positions), len(values))
+		}
+		row := make([]any, len(tD.Cols))
+		for i, col := range tD.Cols {
+			row[i] = tD.Defaults[col]
+		}
+		for i, val := range values {
+			if err := checkType(tD, positions[i], val); err != nil {
+				return nil, err
+			}
+			row[positions[i]] = val
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 1 {
+		err = db.Insert(rows[0]...)
+	} else {
+		err = db.InsertBatch(rows)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Result{RowsAffected: len(rows)}, nil
+}
+
+func (e *Engine) selectRows(s *Select) (*Result, error) {
+	db, err := e.Table(s.Table)
+	if err != nil {
+		return nil, err
+	}
+	tD := db.TableDef()
+	if s.aggregated() {
+		return e.aggregateRows(db, tD, s)
+	}
+	var projection []int
+	if len(s.Cols) == 0 {
+		for i := range tD.Cols {
+			projection = append(projection, i)
+		}
+	} else {
+		for _, col := range s.Cols {
+			colIndex, err := tD.ColIndex(col)
+			if err != nil {
+				return nil, err
+			}
+			projection = append(projection, colIndex)
+		}
+	}
+	res := &Result{}
+	for _, colIndex := range projection {
+		res.Columns = append(res.Columns, Column{Name: tD.Cols[colIndex], Type: tD.Types[colIndex]})
+	}
+	if s.Limit == 0 {
+		return res, nil
+	}
+	// * Without ORDER BY, LIMIT or OFFSET the rows come in the order of the access path, nothing is sorted
+	if !s.shaped() {
+		rows, err := matchingRows(db, tD, s.Where)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			out := make([]any, len(projection))
+			for i, colIndex := range projection {
+				out[i] = row[colIndex]
+			}
+			res.Rows = append(res.Rows, out)
+		}
+		return res, nil
+	}
+	opts, preds, _, err := selectOptions(tD, s)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := db.Select(opts, preds...)
+	if err != nil {
+		return nil, err
+	}
+	res.Rows = rs.Rows
+	return res, nil
+}
+
+// * selectOptions maps a SELECT onto the options and predicates of DB.Select. Conditions the planner can't take are
+// * applied through the Filter option and returned for EXPLAIN.
+func selectOptions(tD *core.TableDef, s *Select) (*core.QueryOptions, []core.Predicate, Expr, error) {
+	preds, rest, err := splitWhere(tD, s.Where)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	opts := &core.QueryOptions{
+		Columns: s.Cols,
+		OrderBy: s.OrderBy,
+		Desc:    s.Desc,
+		Offset:  s.Offset,
+	}
+	if s.Limit > 0 {
+		opts.Limit = s.Limit
+	}
+	if rest != nil {
+		opts.Filter = func(row []any) bool {
+			return evalExpr(tD, rest, row)
+		}
+	}
+	return opts, preds, rest, nil
+}
+
+// * update applies the SET list to every matching row through DB.Update, all of them as one write so a row failing
+// * leaves the others as they were. Rows are matched before the first one is changed, so updating the primary key of
+// * a range can't visit a row twice.
+func (e *Engine) update(s *Update) (*Result, error) {
+	db, err := e.Table(s.Table)
+	if err != nil {
+		return nil, err
+	}
+	tD := db.TableDef()
+	changes := make(map[string]any, len(s.Set))
+	for _, set := range s.Set {
+		colIndex, err := tD.ColIndex(set.Col)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkType(tD, colIndex, set.Value); err != nil {
+			return nil, err
+		}
+		changes[tD.Cols[colIndex]] = set.Value
+	}
+	rows, err := matchingRows(db, tD, s.Where)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Atomic(func() error {
+		for _, row := range rows {
+			if err := db.Update(row[0], changes); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Result{RowsAffected: len(rows)}, nil
+}
+
+// * delete hands a WHERE made of comparisons joined by AND to DeleteWhere, which streams the matching rows. Other
+// * conditions are evaluated on the rows first, which are then deleted as one write.
+func (e *Engine) delete(s *Delete) (*Result, error) {
+	db, err := e.Table(s.Table)
+	if err != nil {
+		return nil, err
+	}
+	tD := db.TableDef()
+	preds, rest, err := splitWhere(tD, s.Where)
+	if err != nil {
+		return nil, err
+	}
+	if rest == nil {
+		deleted, err := db.DeleteWhere(preds...)
+		return &Result{RowsAffected: deleted}, err
+	}
+	rows, err := matchingRows(db, tD, s.Where)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Atomic(func() error {
+		for _, row := range rows {
+			if err := db.Delete(0, row[0]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Result{RowsAffected: len(rows)}, nil
+}
+
+func (e *Engine) drop(s *Drop) (*Result, error) {
+	db, err := e.Table(s.Table)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Drop(); err != nil {
+		return nil, err
+	}
+	delete(e.tables, strings.ToUpper(s.Table))
+	return &Result{}, nil
+}
+
+func (e *Engine) explain(s *Explain) (*Result, error) {
+	var table string
+	var where Expr
+	switch stmt := s.Stmt.(type) {
+	case *Select:
+		if stmt.aggregated() {
+			return e.explainAggregate(stmt)
+		}
+		if stmt.shaped() {
+			return e.explainSelect(stmt)
+		}
+		table, where = stmt.Table, stmt.Where
+	case *Update:
+		table, where = stmt.Table, stmt.Where
+	case *Delete:
+		table, where = stmt.Table, stmt.Where
+	default:
+		return nil, fmt.Errorf("[error] EXPLAIN needs SELECT, UPDATE or DELETE, got: %T", s.Stmt)
+	}
+	db, err := e.Table(table)
+	if err != nil {
+		return nil, err
+	}
+	plan, rest, err := planWhere(db, db.TableDef(), where)
+	if err != nil {
+		return nil, err
+	}
+	return planResult(plan.Explain(), rest), nil
+}
+
+func (e *Engine) explainSelect(s *Select) (*Result, error) {
+	db, err := e.Table(s.Table)
+	if err != nil {
+		return nil, err
+	}
+	opts, preds, rest, err := selectOptions(db.TableDef(), s)
+	if err != nil {
+		return nil, err
+	}
+	explain, err := db.ExplainSelect(opts, preds...)
+	if err != nil {
+		return nil, err
+	}
+	if s.Limit == 0 {
+		explain += "\nLIMIT 0"
+	}
+	return planResult(explain, rest), nil
+}
+
+func (e *Engine) explainAggregate(s *Select) (*Result, error) {
+	db, err := e.Table(s.Table)
+	if err != nil {
+		return nil, err
+	}
+	_, _, preds, rest, err := aggregateOptions(db.TableDef(), s)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := db.Plan(preds...)
+	if err != nil {
+		return nil, err
+	}
+	res := planResult(plan.Explain(), rest)
+	res.Rows = append(res.Rows, []any{[]byte(aggregateString(s))})
+	return res, nil
+}
+
+// * planResult turns the lines of an explanation into a result set, the conditions left to the sql package last.
+func planResult(explain string, rest Expr) *Result {
+	lines := strings.Split(explain, "\n")
+	if rest != nil {
+		// * The filter runs before the sort and paging steps of a SELECT
+		at := len(lines)
+		for i, line := range lines {
+			if strings.HasPrefix(line, "SORT BY ") || strings.HasPrefix(line, "LIMIT ") || strings.HasPrefix(line, "OFFSET ") {
+				at = i
+				break
+			}
+		}
+		lines = append(lines[:at], append([]string{"FILTER " + exprString(rest)}, lines[at:]...)...)
+	}
+	res := &Result{Columns: []Column{{Name: "PLAN", Type: core.TYPE_BYTE}}}
+	for _, line := range lines {
+		res.Rows = append(res.Rows, []any{[]byte(line)})
+	}
+	return res
+}
+
+// * Row matching
+
+// * matchingRows fetches the rows satisfying where.
+func matchingRows(db *core.DB, tD *core.TableDef, where Expr) ([][]any, error) {
+	plan, rest, err := planWhere(db, tD, where)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]any
+	err = plan.Each(func(row []any) (bool, error) {
+		if rest == nil || evalExpr(tD, rest, row) {
+			rows = append(rows, row)
+		}
+		return true, nil
+	})
+	return rows, err
+}
+
+// * splitWhere hands the comparisons of the top level AND chain to the core planner as predicates. The terms it
+// * can't express, the OR groups, are returned to be evaluated on the rows the plan produces.
+func splitWhere(tD *core.TableDef, where Expr) ([]core.Predicate, Expr, error) {
+	if err := bindExpr(tD, where); err != nil {
+		return nil, nil, err
+	}
+	var preds []core.Predicate
+	var rest Expr
+	for _, term := range andTerms(where) {
+		if pred, ok := toPredicate(term); ok {
+			preds = append(preds, pred)
+		} else if rest == nil {
+			rest = term
+		} else {
+			rest = &And{Left: rest, Right: term}
+		}
+	}
+	return preds, rest, nil
+}
+
+func planWhere(db *core.DB, tD *core.TableDef, where Expr) (*core.Plan, Expr, error) {
+	preds, rest, err := splitWhere(tD, where)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := db.Plan(preds...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plan, rest, nil
+}
+
+var predicateOps = map[string]core.Op{
+	"=": core.OP_EQ, "<>": core.OP_NE, "<": core.OP_LT, "<=": core.OP_LE, ">": core.OP_GT, ">=": core.OP_GE,
+}
+
+func toPredicate(e Expr) (core.Predicate, bool) {
+	switch x := e.(type) {
+	case *Comparison:
+		return core.Predicate{Col: x.Col, Op: predicateOps[x.Op], Value: x.Value}, true
+	case *Between:
+		return core.Between(x.Col, x.Low, x.High), true
+	}
+	return core.Predicate{}, false
+}
+
+func andTerms(e Expr) []Expr {
+	switch x := e.(type) {
+	case nil:
+		return nil
+	case *And:
+		return append(andTerms(x.Left), andTerms(x.Right)...)
+	}
+	return []Expr{e}
+}
+
+// * bindExpr checks that every column of the condition exists and that the literals match the column types. Column
+// * names are replaced by their stored spelling.
+func bindExpr(tD *core.TableDef, e Expr) error {
+	switch x := e.(type) {
+	case nil:
+		return nil
+	case *Comparison:
+		colIndex, err := tD.ColIndex(x.Col)
+		if err != nil {
+			return err
+		}
+		x.Col = tD.Cols[colIndex]
+		return checkType(tD, colIndex, x.Value)
+	case *Between:
+		colIndex, err := tD.ColIndex(x.Col)
+		if err != nil {
+			return err
+		}
+		x.Col = tD.Cols[colIndex]
+		if err := checkType(tD, colIndex, x.Low); err != nil {
+			return err
+		}
+		return checkType(tD, colIndex, x.High)
+	case *And:
+		if err := bindExpr(tD, x.Left); err != nil {
+			return err
+		}
+		return bindExpr(tD, x.Right)
+	case *Or:
+		if err := bindExpr(tD, x.Left); err != nil {
+			return err
+		}
+		return bindExpr(tD, x.Right)
+	}
+	return fmt.Errorf("[error] unsupported expression: %T", e)
+}
+
+func evalExpr(tD *core.TableDef, e Expr, row []any) bool {
+	switch x := e.(type) {
+	case *And:
+		return evalExpr(tD, x.Left, row) && evalExpr(tD, x.Right, row)
+	case *Or:
+		return evalExpr(tD, x.Left, row) || evalExpr(tD, x.Right, row)
+	}
+	pred, ok := toPredicate(e)
+	if !ok {
+		return false
+	}
+	colIndex, _ := tD.ColIndex(pred.Col)
+	return pred.Matches(row[colIndex])
+}
+
+// * exprString formats a condition back into SQL.
+func exprString(e Expr) string {
+	switch x := e.(type) {
+	case *And:
+		return exprString(x.Left) + " AND " + exprString(x.Right)
+	case *Or:
+		return "(" + exprString(x.Left) + " OR " + exprString(x.Right) + ")"
+	}
+	pred, _ := toPredicate(e)
+	return pred.String()
+}
+
+func checkType(tD *core.TableDef, colIndex int, val any) error {
+	switch val.(type) {
+	case int:
+		if tD.Types[colIndex] == core.TYPE_INT64 {
+			return nil
+		}
+	case []byte:
+		if tD.Types[colIndex] == core.TYPE_BYTE {
+			return nil
+		}
+	}
+	return errors.New("[error] type mismatch for column: " + tD.Cols[colIndex])
+}
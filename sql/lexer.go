@@ -0,0 +1,132 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokKeyword
+	tokInt
+	tokString
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string // * Keywords are upper cased, string literals are unquoted
+	pos  int
+}
+
+var keywords = map[string]bool{
+	"CREATE": true, "TABLE": true, "PRIMARY": true, "KEY": true, "UNIQUE": true,
+	"INSERT": true, "INTO": true, "VALUES": true,
+	"SELECT": true, "FROM": true, "WHERE": true,
+	"UPDATE": true, "SET": true, "DELETE": true,
+	"AND": true, "OR": true, "BETWEEN": true, "EXPLAIN": true,
+	"FOREIGN": true, "REFERENCES": true, "ON": true, "CASCADE": true, "RESTRICT": true, "NULL": true,
+	"ORDER": true, "GROUP": true, "BY": true, "ASC": true, "DESC": true, "LIMIT": true, "OFFSET": true,
+	"DEFAULT": true, "CHECK": true, "REGEXP": true, "TRUNCATE": true, "DROP": true,
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return "'" + t.text + "'"
+	default:
+		return t.text
+	}
+}
+
+// * lex splits a statement into tokens. Identifiers and keywords are case insensitive, string literals use single
+// * quotes, a quote inside a string is written twice.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && i+1 < len(input) && input[i+1] == '-':
+			// * Comment until the end of the line
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+		case isIdentStart(c):
+			start := i
+			for i < len(input) && isIdentPart(input[i]) {
+				i++
+			}
+			word := input[start:i]
+			if keywords[strings.ToUpper(word)] {
+				tokens = append(tokens, token{kind: tokKeyword, text: strings.ToUpper(word), pos: start})
+			} else {
+				tokens = append(tokens, token{kind: tokIdent, text: word, pos: start})
+			}
+		case isDigit(c) || (c == '-' && i+1 < len(input) && isDigit(input[i+1])):
+			start := i
+			i++
+			for i < len(input) && isDigit(input[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokInt, text: input[start:i], pos: start})
+		case c == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(input) {
+					return nil, fmt.Errorf("[error] unterminated string starting at position %d", start)
+				}
+				if input[i] == '\'' {
+					if i+1 < len(input) && input[i+1] == '\'' {
+						sb.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteByte(input[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), pos: start})
+		case c == '<' || c == '>' || c == '!':
+			if i+1 < len(input) && (input[i+1] == '=' || (c == '<' && input[i+1] == '>')) {
+				tokens = append(tokens, token{kind: tokSymbol, text: input[i : i+2], pos: i})
+				i += 2
+			} else if c == '!' {
+				return nil, fmt.Errorf("[error] unexpected character %q at position %d", c, i)
+			} else {
+				tokens = append(tokens, token{kind: tokSymbol, text: string(c), pos: i})
+				i++
+			}
+		case strings.IndexByte("=(),;*", c) != -1:
+			tokens = append(tokens, token{kind: tokSymbol, text: string(c), pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("[error] unexpected character %q at position %d", c, i)
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, pos: len(input)})
+	return tokens, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
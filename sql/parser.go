@@ -0,0 +1,787 @@
+package sql
+
+import (
+	"BynxDB/core"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// * Parse parses a single statement. A trailing semicolon is optional.
+func Parse(query string) (Statement, error) {
+	stmts, err := ParseScript(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) != 1 {
+		return nil, fmt.Errorf("[error] expected exactly one statement, got %d", len(stmts))
+	}
+	return stmts[0], nil
+}
+
+// * ParseScript parses a list of statements separated by semicolons.
+func ParseScript(input string) ([]Statement, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	var stmts []Statement
+	for {
+		for p.acceptSymbol(";") {
+		}
+		if p.peek().kind == tokEOF {
+			return stmts, nil
+		}
+		stmt, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+		if !p.acceptSymbol(";") && p.peek().kind != tokEOF {
+			return nil, p.unexpected("; or end of input")
+		}
+	}
+}
+
+// * Token helpers
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) unexpected(expected string) error {
+	t := p.peek()
+	return fmt.Errorf("[error] syntax error at position %d: expected %s, got %s", t.pos, expected, t)
+}
+
+func (p *parser) acceptKeyword(kw string) bool {
+	if t := p.peek(); t.kind == tokKeyword && t.text == kw {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		return p.unexpected(kw)
+	}
+	return nil
+}
+
+func (p *parser) acceptSymbol(sym string) bool {
+	if t := p.peek(); t.kind == tokSymbol && t.text == sym {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectSymbol(sym string) error {
+	if !p.acceptSymbol(sym) {
+		return p.unexpected("'" + sym + "'")
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	if t := p.peek(); t.kind == tokIdent {
+		p.pos++
+		return t.text, nil
+	}
+	return "", p.unexpected("identifier")
+}
+
+func (p *parser) identList() ([]string, error) {
+	var names []string
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.acceptSymbol(",") {
+			return names, nil
+		}
+	}
+}
+
+// * literal parses an integer or a string literal into the value types used by the core package.
+func (p *parser) literal() (any, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokInt:
+		p.pos++
+		val, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("[error] integer out of range at position %d: %s", t.pos, t.text)
+		}
+		return val, nil
+	case tokString:
+		p.pos++
+		return []byte(t.text), nil
+	}
+	return nil, p.unexpected("literal")
+}
+
+// * Statements
+
+func (p *parser) statement() (Statement, error) {
+	t := p.peek()
+	if t.kind == tokKeyword {
+		switch t.text {
+		case "CREATE":
+			return p.createTable()
+		case "INSERT":
+			return p.insert()
+		case "SELECT":
+			return p.selectStmt()
+		case "UPDATE":
+			return p.update()
+		case "DELETE":
+			return p.delete()
+		case "TRUNCATE":
+			p.next()
+			table, err := p.tableName()
+			return &Truncate{Table: table}, err
+		case "DROP":
+			p.next()
+			table, err := p.tableName()
+			return &Drop{Table: table}, err
+		case "EXPLAIN":
+			p.next()
+			stmt, err := p.statement()
+			if err != nil {
+				return nil, err
+			}
+			return &Explain{Stmt: stmt}, nil
+		}
+	}
+	return nil, p.unexpected("CREATE, INSERT, SELECT, UPDATE, DELETE, TRUNCATE, DROP or EXPLAIN")
+}
+
+// * tableName parses the TABLE keyword and the name following it.
+func (p *parser) tableName() (string, error) {
+	if err := p.expectKeyword("TABLE"); err != nil {
+		return "", err
+	}
+	return p.ident()
+}
+
+func (p *parser) createTable() (Statement, error) {
+	p.next()
+	if err := p.expectKeyword("TABLE"); err != nil {
+		return nil, err
+	}
+	stmt := &CreateTable{}
+	var err error
+	if stmt.Table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var pKeys, uniques []string
+	var foreignKeys []ColumnDef
+	for {
+		switch {
+		case p.acceptKeyword("FOREIGN"):
+			if err := p.expectKeyword("KEY"); err != nil {
+				return nil, err
+			}
+			fk := ColumnDef{}
+			if fk.Name, err = p.parenIdent(); err != nil {
+				return nil, err
+			}
+			if err := p.expectKeyword("REFERENCES"); err != nil {
+				return nil, err
+			}
+			if err := p.references(&fk); err != nil {
+				return nil, err
+			}
+			foreignKeys = append(foreignKeys, fk)
+		case p.acceptKeyword("PRIMARY"):
+			if err := p.expectKeyword("KEY"); err != nil {
+				return nil, err
+			}
+			name, err := p.parenIdent()
+			if err != nil {
+				return nil, err
+			}
+			pKeys = append(pKeys, name)
+		case p.acceptKeyword("UNIQUE"):
+			name, err := p.parenIdent()
+			if err != nil {
+				return nil, err
+			}
+			uniques = append(uniques, name)
+		case p.acceptKeyword("CHECK"):
+			check, err := p.check()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Checks = append(stmt.Checks, check)
+		default:
+			col, err := p.columnDef(stmt)
+			if err != nil {
+				return nil, err
+			}
+			stmt.Cols = append(stmt.Cols, col)
+		}
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	// * Table level constraints are folded into the column definitions
+	for _, name := range pKeys {
+		if err := stmt.markColumn(name, true); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range uniques {
+		if err := stmt.markColumn(name, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, check := range stmt.Checks {
+		found := false
+		for _, col := range stmt.Cols {
+			found = found || strings.EqualFold(col.Name, check.Col)
+		}
+		if !found {
+			return nil, fmt.Errorf("[error] constraint on unknown column: %s", check.Col)
+		}
+	}
+	for _, fk := range foreignKeys {
+		found := false
+		for i := range stmt.Cols {
+			if strings.EqualFold(stmt.Cols[i].Name, fk.Name) {
+				stmt.Cols[i].References, stmt.Cols[i].RefCol, stmt.Cols[i].OnDelete = fk.References, fk.RefCol, fk.OnDelete
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("[error] constraint on unknown column: %s", fk.Name)
+		}
+	}
+	return stmt, nil
+}
+
+// * references parses the target of a foreign key after REFERENCES: table [(column)] [ON DELETE action].
+func (p *parser) references(col *ColumnDef) error {
+	var err error
+	if col.References, err = p.ident(); err != nil {
+		return err
+	}
+	if p.peek().kind == tokSymbol && p.peek().text == "(" {
+		if col.RefCol, err = p.parenIdent(); err != nil {
+			return err
+		}
+	}
+	if !p.acceptKeyword("ON") {
+		return nil
+	}
+	if err := p.expectKeyword("DELETE"); err != nil {
+		return err
+	}
+	switch {
+	case p.acceptKeyword("RESTRICT"):
+		col.OnDelete = core.FK_RESTRICT
+	case p.acceptKeyword("CASCADE"):
+		col.OnDelete = core.FK_CASCADE
+	case p.acceptKeyword("SET"):
+		if err := p.expectKeyword("NULL"); err != nil {
+			return err
+		}
+		col.OnDelete = core.FK_SET_NULL
+	default:
+		return p.unexpected("RESTRICT, CASCADE or SET NULL")
+	}
+	return nil
+}
+
+// * check parses the condition of a CHECK after the keyword. Only the conditions core.Check can express are
+// * supported: BETWEEN, >=, >, <= and < against an integer, <> against the empty string, LENGTH(col) <= or < an
+// * integer, and REGEXP with a pattern.
+func (p *parser) check() (core.Check, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return core.Check{}, err
+	}
+	start := p.peek()
+	col, err := p.ident()
+	if err != nil {
+		return core.Check{}, err
+	}
+	var check core.Check
+	length := strings.EqualFold(col, "LENGTH") && p.peek().kind == tokSymbol && p.peek().text == "("
+	if length {
+		if col, err = p.parenIdent(); err != nil {
+			return check, err
+		}
+	}
+	unsupported := fmt.Errorf("[error] unsupported CHECK at position %d", start.pos)
+	switch {
+	case length:
+		op := p.next()
+		n, err := p.literal()
+		if err != nil {
+			return check, err
+		}
+		limit, ok := n.(int)
+		if !ok || op.kind != tokSymbol || (op.text != "<=" && op.text != "<") {
+			return check, unsupported
+		}
+		if op.text == "<" {
+			limit--
+		}
+		check = core.CheckMaxLength(col, limit)
+	case p.acceptKeyword("BETWEEN"):
+		low, err := p.literal()
+		if err != nil {
+			return check, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return check, err
+		}
+		high, err := p.literal()
+		if err != nil {
+			return check, err
+		}
+		lowInt, lowOk := low.(int)
+		highInt, highOk := high.(int)
+		if !lowOk || !highOk {
+			return check, unsupported
+		}
+		check = core.CheckRange(col, lowInt, highInt)
+	case p.acceptKeyword("REGEXP"):
+		pattern, err := p.literal()
+		if err != nil {
+			return check, err
+		}
+		patternBytes, ok := pattern.([]byte)
+		if !ok {
+			return check, unsupported
+		}
+		check = core.CheckRegex(col, string(patternBytes))
+	default:
+		op := p.next()
+		if op.kind != tokSymbol {
+			return check, p.unexpected("comparison operator, BETWEEN or REGEXP")
+		}
+		val, err := p.literal()
+		if err != nil {
+			return check, err
+		}
+		n, isInt := val.(int)
+		switch {
+		case (op.text == "<>" || op.text == "!=") && !isInt && len(val.([]byte)) == 0:
+			check = core.CheckNotEmpty(col)
+		case op.text == ">=" && isInt:
+			check = core.CheckMin(col, n)
+		case op.text == ">" && isInt:
+			check = core.CheckGreater(col, n)
+		case op.text == "<=" && isInt:
+			check = core.CheckMax(col, n)
+		case op.text == "<" && isInt:
+			check = core.CheckLess(col, n)
+		default:
+			return check, unsupported
+		}
+	}
+	return check, p.expectSymbol(")")
+}
+
+func (p *parser) parenIdent() (string, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return "", err
+	}
+	name, err := p.ident()
+	if err != nil {
+		return "", err
+	}
+	return name, p.expectSymbol(")")
+}
+
+func (stmt *CreateTable) markColumn(name string, pKey bool) error {
+	for i := range stmt.Cols {
+		if strings.EqualFold(stmt.Cols[i].Name, name) {
+			if pKey {
+				stmt.Cols[i].PrimaryKey = true
+			} else {
+				stmt.Cols[i].Unique = true
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("[error] constraint on unknown column: %s", name)
+}
+
+// * ColumnType maps a SQL type name onto the core column types.
+func ColumnType(name string) (uint16, bool) {
+	switch strings.ToUpper(name) {
+	case "INT", "INTEGER", "BIGINT", "INT64":
+		return core.TYPE_INT64, true
+	case "TEXT", "VARCHAR", "CHAR", "STRING", "BYTES", "BLOB":
+		return core.TYPE_BYTE, true
+	}
+	return 0, false
+}
+
+// * TypeName is the inverse of ColumnType.
+func TypeName(typ uint16) string {
+	switch typ {
+	case core.TYPE_INT64:
+		return "INT"
+	case core.TYPE_BYTE:
+		return "TEXT"
+	}
+	return "UNKNOWN"
+}
+
+// * columnDef parses a column and its constraints. A CHECK on the column is added to the checks of stmt.
+func (p *parser) columnDef(stmt *CreateTable) (ColumnDef, error) {
+	col := ColumnDef{}
+	var err error
+	if col.Name, err = p.ident(); err != nil {
+		return col, err
+	}
+	typTok := p.peek()
+	typName, err := p.ident()
+	if err != nil {
+		return col, p.unexpected("column type")
+	}
+	var ok bool
+	if col.Type, ok = ColumnType(typName); !ok {
+		return col, fmt.Errorf("[error] unknown column type at position %d: %s", typTok.pos, typName)
+	}
+	// * Length of VARCHAR(n) and friends is accepted and ignored
+	if p.acceptSymbol("(") {
+		if p.peek().kind != tokInt {
+			return col, p.unexpected("length")
+		}
+		p.next()
+		if err := p.expectSymbol(")"); err != nil {
+			return col, err
+		}
+	}
+	for {
+		switch {
+		case p.acceptKeyword("PRIMARY"):
+			if err := p.expectKeyword("KEY"); err != nil {
+				return col, err
+			}
+			col.PrimaryKey = true
+		case p.acceptKeyword("UNIQUE"):
+			col.Unique = true
+		case p.acceptKeyword("REFERENCES"):
+			if err := p.references(&col); err != nil {
+				return col, err
+			}
+		case p.acceptKeyword("DEFAULT"):
+			if col.Default, err = p.literal(); err != nil {
+				return col, err
+			}
+		case p.acceptKeyword("CHECK"):
+			check, err := p.check()
+			if err != nil {
+				return col, err
+			}
+			stmt.Checks = append(stmt.Checks, check)
+		default:
+			return col, nil
+		}
+	}
+}
+
+func (p *parser) insert() (Statement, error) {
+	p.next()
+	if err := p.expectKeyword("INTO"); err != nil {
+		return nil, err
+	}
+	stmt := &Insert{}
+	var err error
+	if stmt.Table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if p.acceptSymbol("(") {
+		if stmt.Cols, err = p.identList(); err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKeyword("VALUES"); err != nil {
+		return nil, err
+	}
+	for {
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		var row []any
+		for {
+			val, err := p.literal()
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, val)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		stmt.Rows = append(stmt.Rows, row)
+		if !p.acceptSymbol(",") {
+			return stmt, nil
+		}
+	}
+}
+
+func (p *parser) selectStmt() (Statement, error) {
+	p.next()
+	stmt := &Select{Limit: -1}
+	var err error
+	if !p.acceptSymbol("*") {
+		if err := p.selectList(stmt); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	if stmt.Table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if stmt.Where, err = p.where(); err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("GROUP") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		if stmt.GroupBy, err = p.identList(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		if stmt.OrderBy, err = p.ident(); err != nil {
+			return nil, err
+		}
+		if p.acceptKeyword("DESC") {
+			stmt.Desc = true
+		} else {
+			p.acceptKeyword("ASC")
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		if stmt.Limit, err = p.count(); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("OFFSET") {
+		if stmt.Offset, err = p.count(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// * aggregateFuncs are the functions of the select list. They aren't keywords, so they still work as column names.
+var aggregateFuncs = map[string]bool{"COUNT": true, "SUM": true, "MIN": true, "MAX": true, "AVG": true}
+
+// * selectList parses the columns and aggregates of a SELECT.
+func (p *parser) selectList(stmt *Select) error {
+	for pos := 0; ; pos++ {
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if fn := strings.ToUpper(name); aggregateFuncs[fn] && p.acceptSymbol("(") {
+			agg := Aggregate{Func: fn, Pos: pos}
+			if fn != "COUNT" || !p.acceptSymbol("*") {
+				if agg.Col, err = p.ident(); err != nil {
+					return err
+				}
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return err
+			}
+			stmt.Aggregates = append(stmt.Aggregates, agg)
+		} else {
+			stmt.Cols = append(stmt.Cols, name)
+		}
+		if !p.acceptSymbol(",") {
+			return nil
+		}
+	}
+}
+
+// * count parses the non negative integer of LIMIT and OFFSET.
+func (p *parser) count() (int, error) {
+	t := p.peek()
+	val, err := p.literal()
+	if err != nil {
+		return 0, err
+	}
+	n, ok := val.(int)
+	if !ok || n < 0 {
+		return 0, fmt.Errorf("[error] expected a non negative integer at position %d, got %s", t.pos, t)
+	}
+	return n, nil
+}
+
+func (p *parser) update() (Statement, error) {
+	p.next()
+	stmt := &Update{}
+	var err error
+	if stmt.Table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("SET"); err != nil {
+		return nil, err
+	}
+	for {
+		col, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol("="); err != nil {
+			return nil, err
+		}
+		val, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Set = append(stmt.Set, Assignment{Col: col, Value: val})
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if stmt.Where, err = p.where(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (p *parser) delete() (Statement, error) {
+	p.next()
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	stmt := &Delete{}
+	var err error
+	if stmt.Table, err = p.ident(); err != nil {
+		return nil, err
+	}
+	if stmt.Where, err = p.where(); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// * Expressions, AND binds tighter than OR
+
+func (p *parser) where() (Expr, error) {
+	if !p.acceptKeyword("WHERE") {
+		return nil, nil
+	}
+	return p.orExpr()
+}
+
+func (p *parser) orExpr() (Expr, error) {
+	left, err := p.andExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		right, err := p.andExpr()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) andExpr() (Expr, error) {
+	left, err := p.condition()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		right, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) condition() (Expr, error) {
+	if p.acceptSymbol("(") {
+		e, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expectSymbol(")")
+	}
+	col, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("BETWEEN") {
+		low, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		return &Between{Col: col, Low: low, High: high}, nil
+	}
+	t := p.peek()
+	switch t.text {
+	case "=", "<>", "!=", "<", "<=", ">", ">=":
+		if t.kind != tokSymbol {
+			break
+		}
+		p.pos++
+		val, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		op := t.text
+		if op == "!=" {
+			op = "<>"
+		}
+		return &Comparison{Col: col, Op: op, Value: val}, nil
+	}
+	return nil, p.unexpected("comparison operator or BETWEEN")
+}
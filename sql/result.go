@@ -0,0 +1,30 @@
+package sql
+
+import "BynxDB/core"
+
+// * Column describes a column of a result set. Type is core.TYPE_INT64, core.TYPE_BYTE or TYPE_FLOAT64.
+type Column struct {
+	Name string
+	Type uint16
+}
+
+// * Result is the outcome of a statement. SELECT fills Columns and Rows, the other statements only RowsAffected.
+type Result struct {
+	Columns      []Column
+	Rows         [][]any
+	RowsAffected int
+}
+
+// * ColumnNames returns the names of the result columns in order.
+func (r *Result) ColumnNames() []string {
+	names := make([]string, len(r.Columns))
+	for i, col := range r.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// * Row returns the i-th row with its values addressable by column name.
+func (r *Result) Row(i int) *core.Row {
+	return core.NewRow(r.ColumnNames(), r.Rows[i])
+}
@@ -0,0 +1,178 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"fmt"
+	"testing"
+)
+
+// TestAggregate tests COUNT, SUM, MIN, MAX and AVG with and without GROUP BY against values computed from the rows
+func TestAggregate(t *testing.T) {
+	opts := memoryOptions()
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "NAME", "CABIN", "DEPT", "SALARY"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64, core.TYPE_INT64, core.TYPE_INT64},
+		UniqueCols: []int{2},
+	}
+	db, err := core.DbInitWithOptions("aggregate", tDef, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { db.Close() }()
+
+	var all [][]any
+	for i := 0; i < 300; i++ {
+		row := []any{i*3 - 200, []byte(fmt.Sprintf("Name_%03d", (i*7)%300)), 9000 - i, i % 5, 1000 + (i*37)%500}
+		all = append(all, row)
+		if err := db.Insert(row...); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	t.Run("Ungrouped", func(t *testing.T) {
+		rs, err := db.Aggregate([]core.Aggregate{
+			core.CountAll(), core.Sum("salary"), core.Min("cabin"), core.Max("id"), core.Avg("salary"),
+			core.Min("name"), core.Max("dept"),
+		}, nil, core.Gt("id", 0))
+		if err != nil {
+			t.Fatalf("Aggregate failed: %v", err)
+		}
+		want := []any{0, 0, nil, nil, 0.0, nil, nil}
+		for _, row := range all {
+			if row[0].(int) <= 0 {
+				continue
+			}
+			want[0] = want[0].(int) + 1
+			want[1] = want[1].(int) + row[4].(int)
+			if want[2] == nil || row[2].(int) < want[2].(int) {
+				want[2] = row[2]
+			}
+			if want[3] == nil || row[0].(int) > want[3].(int) {
+				want[3] = row[0]
+			}
+			if want[5] == nil || string(row[1].([]byte)) < string(want[5].([]byte)) {
+				want[5] = row[1]
+			}
+			if want[6] == nil || row[3].(int) > want[6].(int) {
+				want[6] = row[3]
+			}
+		}
+		want[4] = float64(want[1].(int)) / float64(want[0].(int))
+		if fmt.Sprint(rs.Rows) != fmt.Sprint([][]any{want}) {
+			t.Errorf("expected %v, got %v", want, rs.Rows)
+		}
+		if fmt.Sprint(rs.Columns) != "[COUNT(*) SUM(SALARY) MIN(CABIN) MAX(ID) AVG(SALARY) MIN(NAME) MAX(DEPT)]" {
+			t.Errorf("unexpected columns %v", rs.Columns)
+		}
+	})
+
+	t.Run("Grouped", func(t *testing.T) {
+		filter := func(row []any) bool { return row[4].(int)%2 == 0 }
+		rs, err := db.Aggregate([]core.Aggregate{core.Count("id"), core.Sum("salary"), core.Max("cabin")},
+			&core.AggregateOptions{GroupBy: []string{"dept"}, Filter: filter})
+		if err != nil {
+			t.Fatalf("Aggregate failed: %v", err)
+		}
+		want := make([][]any, 5)
+		for dept := range want {
+			want[dept] = []any{dept, 0, 0, nil}
+		}
+		for _, row := range all {
+			if !filter(row) {
+				continue
+			}
+			group := want[row[3].(int)]
+			group[1] = group[1].(int) + 1
+			group[2] = group[2].(int) + row[4].(int)
+			if group[3] == nil || row[2].(int) > group[3].(int) {
+				group[3] = row[2]
+			}
+		}
+		if fmt.Sprint(rs.Rows) != fmt.Sprint(want) {
+			t.Errorf("expected %v, got %v", want, rs.Rows)
+		}
+		if rs.Columns[0] != "DEPT" {
+			t.Errorf("unexpected columns %v", rs.Columns)
+		}
+
+		// * Without aggregates the distinct groups are listed in order
+		rs, err = db.Aggregate(nil, &core.AggregateOptions{GroupBy: []string{"dept"}}, core.Lt("cabin", 8800))
+		if err != nil {
+			t.Fatalf("Aggregate failed: %v", err)
+		}
+		if fmt.Sprint(rs.Rows) != "[[0] [1] [2] [3] [4]]" {
+			t.Errorf("unexpected groups %v", rs.Rows)
+		}
+	})
+
+	t.Run("NoRows", func(t *testing.T) {
+		rs, err := db.Aggregate([]core.Aggregate{core.CountAll(), core.Sum("salary"), core.Min("id"), core.Avg("dept")},
+			nil, core.Gt("id", 100000))
+		if err != nil {
+			t.Fatalf("Aggregate failed: %v", err)
+		}
+		if fmt.Sprint(rs.Rows) != "[[0 <nil> <nil> <nil>]]" {
+			t.Errorf("unexpected row %v", rs.Rows)
+		}
+		rs, err = db.Aggregate([]core.Aggregate{core.CountAll()}, &core.AggregateOptions{GroupBy: []string{"dept"}},
+			core.Gt("id", 100000))
+		if err != nil || len(rs.Rows) != 0 {
+			t.Errorf("expected no groups, got %v %v", rs, err)
+		}
+	})
+
+	t.Run("InvalidAggregates", func(t *testing.T) {
+		for _, aggs := range [][]core.Aggregate{
+			{core.Sum("name")},
+			{core.Avg("name")},
+			{core.Max("")},
+			{core.Count("salary_typo")},
+			nil,
+		} {
+			if _, err := db.Aggregate(aggs, nil); err == nil {
+				t.Errorf("%v: expected an error", aggs)
+			}
+		}
+		if _, err := db.Aggregate([]core.Aggregate{core.CountAll()}, &core.AggregateOptions{GroupBy: []string{"x"}}); err == nil {
+			t.Errorf("expected an error for an unknown group column")
+		}
+	})
+
+	// * The row count is kept through inserts, deletes and reopening the table
+	t.Run("RowCount", func(t *testing.T) {
+		for i := 0; i < 40; i++ {
+			if err := db.Delete(0, all[i][0]); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+		}
+		if err := db.Insert(5000, []byte("Extra"), 1, 1, 1); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if err := db.Insert(5000, []byte("Duplicate"), 2, 1, 1); err == nil {
+			t.Fatalf("expected a duplicate key error")
+		}
+		if err := db.Update(5000, map[string]any{"name": []byte("Renamed")}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		want := len(all) - 40 + 1
+		if count, err := db.Count(); err != nil || count != want {
+			t.Errorf("expected %d rows, got %d %v", want, count, err)
+		}
+		db.Close()
+		db, err = core.DbInitWithOptions("aggregate", tDef, opts)
+		if err != nil {
+			t.Fatalf("DbInit failed: %v", err)
+		}
+		rs, err := db.Aggregate([]core.Aggregate{core.CountAll(), core.Min("id")}, nil)
+		if err != nil {
+			t.Fatalf("Aggregate failed: %v", err)
+		}
+		if rs.Rows[0][0] != want || rs.Rows[0][1] != all[40][0] {
+			t.Errorf("unexpected row after reopening %v", rs.Rows[0])
+		}
+		rows, err := db.SelectEntireTable()
+		if err != nil || len(rows) != want {
+			t.Errorf("the count disagrees with the table: %d rows %v", len(rows), err)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeHook calls fn before each of the first writes it passes on, letting a test change a table while it is being
+// backed up
+type writeHook struct {
+	buf   bytes.Buffer
+	calls int
+	fn    func(call int)
+}
+
+func (w *writeHook) Write(p []byte) (int, error) {
+	w.calls++
+	w.fn(w.calls)
+	return w.buf.Write(p)
+}
+
+// TestBackupRestore tests that a backup holds the table as it was when it started, even while rows change during
+// it, and that Restore brings it back and rejects damaged archives
+func TestBackupRestore(t *testing.T) {
+	dataDir := tempDataDir(t)
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "CODE", "QTY"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64},
+		UniqueCols: []int{1},
+	}
+	db, err := core.DbInit("backup_items", tDef)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { db.Close() }()
+	code := func(id int) []byte { return []byte(fmt.Sprintf("CODE-%05d", id)) }
+	var rows [][]any
+	for i := 0; i < 2000; i++ {
+		rows = append(rows, []any{i, code(i), i})
+	}
+	if err := db.InsertBatch(rows); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	// * Every write of the archive deletes, updates and inserts rows
+	var writeErr error
+	w := &writeHook{fn: func(call int) {
+		if call > 50 || writeErr != nil {
+			return
+		}
+		if err := db.Truncate(); err == nil {
+			writeErr = errors.New("truncate allowed during a backup")
+			return
+		}
+		if _, err := db.DeleteRange("id", call*20, call*20+9); err != nil {
+			writeErr = err
+			return
+		}
+		if err := db.Update(call*20+10, map[string]any{"QTY": -1}); err != nil {
+			writeErr = err
+			return
+		}
+		writeErr = db.Insert(10000+call, code(10000+call), 0)
+	}}
+	if err := db.Backup(w); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if writeErr != nil {
+		t.Fatalf("write during the backup failed: %v", writeErr)
+	}
+	if count, _ := db.Count(); count == len(rows) {
+		t.Fatalf("the table didn't change during the backup")
+	}
+	archive := w.buf.Bytes()
+
+	t.Run("Validation", func(t *testing.T) {
+		dir := t.TempDir()
+		corrupt := append([]byte{}, archive...)
+		corrupt[len(corrupt)/2] ^= 0xff
+		for name, data := range map[string][]byte{
+			"Corrupt":   corrupt,
+			"Truncated": archive[:len(archive)-5000],
+			"Garbage":   []byte("not a backup at all"),
+			"Empty":     nil,
+		} {
+			if _, err := core.Restore(bytes.NewReader(data), dir); !errors.Is(err, core.ErrBadBackup) {
+				t.Errorf("%s: expected ErrBadBackup, got %v", name, err)
+			}
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("expected failed restores to leave nothing behind, got %d files", len(entries))
+		}
+		if _, err := core.Restore(bytes.NewReader(archive), dataDir); err == nil || errors.Is(err, core.ErrBadBackup) {
+			t.Errorf("expected an error restoring over an existing table, got %v", err)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		if err := db.Drop(); err != nil {
+			t.Fatalf("Drop failed: %v", err)
+		}
+		name, err := core.Restore(bytes.NewReader(archive), dataDir)
+		if err != nil || name != "BACKUP_ITEMS" {
+			t.Fatalf("Restore failed: %q %v", name, err)
+		}
+		if db, err = core.OpenDB("backup_items"); err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		restored, err := db.Query()
+		if err != nil || len(restored) != len(rows) {
+			t.Fatalf("expected %d rows, got %d %v", len(rows), len(restored), err)
+		}
+		for i, row := range restored {
+			if row[0] != i || row[2] != i {
+				t.Fatalf("unexpected row %v, expected id %d", row, i)
+			}
+		}
+		for _, id := range []int{20, 30, 999, 1999} {
+			if row, err := db.PointQueryUniqueCol(1, code(id)); err != nil || row[0] != id {
+				t.Errorf("index lookup of %d: %v %v", id, row, err)
+			}
+		}
+		if _, err := db.PointQueryUniqueCol(1, code(10001)); !errors.Is(err, core.ErrNotFound) {
+			t.Errorf("a row inserted during the backup was restored: %v", err)
+		}
+		if count, err := db.Count(); err != nil || count != len(rows) {
+			t.Errorf("expected a count of %d, got %d %v", len(rows), count, err)
+		}
+		if err := db.Insert(5000, code(5000), 1); err != nil {
+			t.Errorf("Insert into the restored table failed: %v", err)
+		}
+	})
+
+	t.Run("CLI", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "items.bak")
+		db.Close()
+		out, exitCode := runCLI(t, "", "backup", "backup_items", file)
+		if exitCode != 0 || !strings.Contains(out, "backed up BACKUP_ITEMS") {
+			t.Fatalf("Unexpected backup output: %s", out)
+		}
+		if out, exitCode := runCLI(t, "", "restore", file); exitCode == 0 {
+			t.Errorf("expected restoring over the table to fail: %s", out)
+		}
+		if db, err = core.OpenDB("backup_items"); err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		if err := db.Drop(); err != nil {
+			t.Fatalf("Drop failed: %v", err)
+		}
+		out, exitCode = runCLI(t, "", "restore", file)
+		if exitCode != 0 || !strings.Contains(out, "restored table BACKUP_ITEMS") {
+			t.Fatalf("Unexpected restore output: %s", out)
+		}
+		if db, err = core.OpenDB("backup_items"); err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		if count, err := db.Count(); err != nil || count != len(rows)+1 {
+			t.Errorf("expected a count of %d, got %d %v", len(rows)+1, count, err)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestInsertBatch tests batch validation and insertion
+func TestInsertBatch(t *testing.T) {
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "NAME", "EMAIL"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_BYTE},
+		UniqueCols: []int{2},
+	}
+	db, err := core.DbInitWithOptions("insert_batch", tDef, memoryOptions())
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Insert(1, []byte("Alice"), []byte("alice@example.com")); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	t.Run("RejectInvalidRows", func(t *testing.T) {
+		rows := [][]any{
+			{2, []byte("Bob"), []byte("bob@example.com")},
+			{1, []byte("Alice again"), []byte("alice2@example.com")}, // * existing primary key
+			{3, []byte("Charlie"), []byte("bob@example.com")},        // * unique value repeated in batch
+			{4, []byte("Dave"), []byte("alice@example.com")},         // * existing unique value
+			{2, []byte("Bob again"), []byte("bob2@example.com")},     // * primary key repeated in batch
+			{5, 5, []byte("eve@example.com")},                        // * wrong type
+			{6, []byte("Frank")},                                     // * too few columns
+		}
+		err := db.InsertBatch(rows)
+		var batchErr *core.BatchError
+		if !errors.As(err, &batchErr) {
+			t.Fatalf("Expected BatchError, got %v", err)
+		}
+		if batchErr.RowErrors[0] != nil {
+			t.Errorf("Row 0 should be valid, got %v", batchErr.RowErrors[0])
+		}
+		for i := 1; i < len(rows); i++ {
+			if batchErr.RowErrors[i] == nil {
+				t.Errorf("Expected error for row %d", i)
+			}
+		}
+		// * Nothing is applied when a row is rejected
+		if row, _ := db.PKeyQuery(2); row != nil {
+			t.Error("Valid row of a rejected batch was inserted")
+		}
+	})
+
+	t.Run("InsertValidBatch", func(t *testing.T) {
+		var rows [][]any
+		for i := 10; i < 500; i++ {
+			rows = append(rows, []any{i, []byte(fmt.Sprintf("User_%d", i)), []byte(fmt.Sprintf("user%d@example.com", i))})
+		}
+		if err := db.InsertBatch(rows); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		for i := 10; i < 500; i++ {
+			row, err := db.PKeyQuery(i)
+			if err != nil || row == nil {
+				t.Fatalf("Record %d missing after batch", i)
+			}
+		}
+		rowsByEmail, err := db.PointQuery(2, []byte("user250@example.com"))
+		if err != nil || len(rowsByEmail) != 1 || rowsByEmail[0][0] != 250 {
+			t.Errorf("Unique index lookup failed: %v %v", rowsByEmail, err)
+		}
+	})
+}
+
+// TestInsertBatchPersists tests that a batch is committed when the database is reopened
+func TestInsertBatchPersists(t *testing.T) {
+	opts := memoryOptions()
+	tDef := &core.TableDef{
+		Cols:  []string{"ID", "NAME"},
+		Types: []uint16{core.TYPE_INT64, core.TYPE_BYTE},
+	}
+	db, err := core.DbInitWithOptions("insert_batch_reopen", tDef, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	var rows [][]any
+	for i := 0; i < 300; i++ {
+		rows = append(rows, []any{i, []byte(fmt.Sprintf("Row_%d", i))})
+	}
+	if err := db.InsertBatch(rows); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	db.Close()
+
+	db, err = core.DbInitWithOptions("insert_batch_reopen", tDef, opts)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Close()
+	for i := 0; i < 300; i++ {
+		row, err := db.PKeyQuery(i)
+		if err != nil || row == nil {
+			t.Fatalf("Record %d missing after reopen", i)
+		}
+	}
+}
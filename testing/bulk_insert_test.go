@@ -2,6 +2,8 @@ package testing
 
 import (
 	"BynxDB/core"
+	"fmt"
+	"math/rand"
 	"testing"
 )
 
@@ -51,3 +53,89 @@ func TestBulkInsert(t *testing.T) {
 		}
 	}
 }
+
+// TestBulkLoad tests building the records and index trees bottom-up from unsorted input
+func TestBulkLoad(t *testing.T) {
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "NAME", "CABIN"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64},
+		UniqueCols: []int{2},
+	}
+	db, err := core.DbInitWithOptions("bulk_load", tDef, memoryOptions())
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer db.Close()
+
+	numRecords := 3000
+	rows := make([][]any, 0, numRecords)
+	for _, i := range rand.New(rand.NewSource(7)).Perm(numRecords) {
+		rows = append(rows, []any{i, []byte(fmt.Sprintf("Person_%d", i)), 10000 + i})
+	}
+
+	t.Run("RejectDuplicates", func(t *testing.T) {
+		dup := [][]any{{1, []byte("A"), 1}, {2, []byte("B"), 1}}
+		_, err := db.BulkLoad(core.SliceIterator(dup), nil)
+		if err == nil {
+			t.Error("Expected duplicate unique value error, got nil")
+		}
+		all, _ := db.SelectEntireTable()
+		if len(all) != 0 {
+			t.Errorf("Table not empty after failed bulk load: %d rows", len(all))
+		}
+	})
+
+	t.Run("LoadWithExternalSort", func(t *testing.T) {
+		// * A small in-memory limit forces sorted runs to be spilled and merged
+		opts := &core.BulkLoadOptions{FillFactor: 0.8, MaxRowsInMemory: 500}
+		n, err := db.BulkLoad(core.SliceIterator(rows), opts)
+		if err != nil {
+			t.Fatalf("BulkLoad failed: %v", err)
+		}
+		if n != numRecords {
+			t.Errorf("Expected %d rows loaded, got %d", numRecords, n)
+		}
+		for i := 0; i < numRecords; i++ {
+			row, err := db.PKeyQuery(i)
+			if err != nil || row == nil {
+				t.Fatalf("Record %d missing after bulk load", i)
+			}
+		}
+		for i := 0; i < numRecords; i += 97 {
+			rows, err := db.PointQuery(2, 10000+i)
+			if err != nil || len(rows) != 1 || rows[0][0] != i {
+				t.Errorf("Unique index lookup for %d failed: %v %v", 10000+i, rows, err)
+			}
+		}
+		all, err := db.SelectEntireTable()
+		if err != nil || len(all) != numRecords {
+			t.Errorf("Expected %d rows in table, got %d (%v)", numRecords, len(all), err)
+		}
+	})
+
+	t.Run("RejectNonEmptyTable", func(t *testing.T) {
+		_, err := db.BulkLoad(core.SliceIterator([][]any{{numRecords, []byte("X"), 1}}), nil)
+		if err == nil {
+			t.Error("Expected error loading into a non-empty table, got nil")
+		}
+	})
+
+	t.Run("ModifyAfterLoad", func(t *testing.T) {
+		for i := numRecords; i < numRecords+100; i++ {
+			if err := db.Insert(i, []byte("New"), 10000+i); err != nil {
+				t.Fatalf("Insert %d after bulk load failed: %v", i, err)
+			}
+		}
+		for i := 0; i < numRecords+100; i += 3 {
+			if err := db.Delete(0, i); err != nil {
+				t.Fatalf("Delete %d after bulk load failed: %v", i, err)
+			}
+		}
+		for i := 0; i < numRecords+100; i++ {
+			row, _ := db.PKeyQuery(i)
+			if (row == nil) != (i%3 == 0) {
+				t.Fatalf("Record %d in wrong state after modifications", i)
+			}
+		}
+	})
+}
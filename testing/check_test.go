@@ -0,0 +1,178 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"BynxDB/sql"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestCheckConstraints tests that CHECK constraints are enforced on every write and that defaults fill the columns
+// left out of a named row
+func TestCheckConstraints(t *testing.T) {
+	opts := memoryOptions()
+	tDef := &core.TableDef{
+		Cols:      []string{"NAME", "ID", "AGE", "CODE", "SCORE"},
+		Types:     []uint16{core.TYPE_BYTE, core.TYPE_INT64, core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64},
+		PKeyIndex: 1,
+		Defaults:  map[string]any{"age": 30, "CODE": []byte("AAA")},
+		Checks: []core.Check{
+			core.CheckNotEmpty("name"),
+			core.CheckMaxLength("name", 10),
+			core.CheckRange("age", 0, 150),
+			core.CheckRegex("code", "^[A-Z]{3}$"),
+			core.CheckMin("score", 0),
+		},
+	}
+	db, err := core.DbInitWithOptions("check_people", tDef, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { db.Close() }()
+
+	// * violates expects err to be a constraint violation on col
+	violates := func(t *testing.T, err error, col string) {
+		t.Helper()
+		var constraintErr *core.ConstraintError
+		if !errors.As(err, &constraintErr) || constraintErr.Col != col {
+			t.Errorf("expected a constraint violation on %s, got %v", col, err)
+		}
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		if err := db.Insert(1, []byte("Ada"), 36, []byte("ENG"), 10); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		violates(t, db.Insert(2, []byte(""), 36, []byte("ENG"), 10), "NAME")
+		violates(t, db.Insert(2, []byte("Bartholomew"), 36, []byte("ENG"), 10), "NAME")
+		violates(t, db.Insert(2, []byte("Bob"), 151, []byte("ENG"), 10), "AGE")
+		violates(t, db.Insert(2, []byte("Bob"), -1, []byte("ENG"), 10), "AGE")
+		violates(t, db.Insert(2, []byte("Bob"), 40, []byte("eng"), 10), "CODE")
+		violates(t, db.Insert(2, []byte("Bob"), 40, []byte("ENG"), -5), "SCORE")
+		if _, err := db.PKeyQuery(2); !errors.Is(err, core.ErrNotFound) {
+			t.Errorf("a rejected row was inserted: %v", err)
+		}
+
+		var batchErr *core.BatchError
+		err := db.InsertBatch([][]any{{3, []byte("Cy"), 20, []byte("OPS"), 0}, {4, []byte("Di"), 200, []byte("OPS"), 0}})
+		if !errors.As(err, &batchErr) || batchErr.RowErrors[0] != nil {
+			t.Fatalf("expected the second batch row to be rejected, got %v", err)
+		}
+		violates(t, batchErr.RowErrors[1], "AGE")
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		if err := db.InsertRow(map[string]any{"id": 5, "name": []byte("Eve"), "score": 7}); err != nil {
+			t.Fatalf("InsertRow failed: %v", err)
+		}
+		row, err := db.GetRow(5)
+		if err != nil {
+			t.Fatalf("GetRow failed: %v", err)
+		}
+		if row.Int("age") != 30 || string(row.Bytes("code")) != "AAA" {
+			t.Errorf("expected the defaults, got %v", row)
+		}
+		if err := db.InsertRow(map[string]any{"id": 6, "name": []byte("Fay")}); err == nil {
+			t.Errorf("expected an error for a missing column without a default")
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		violates(t, db.Update(1, map[string]any{"age": 500}), "AGE")
+		violates(t, db.UpdatePoint(2, 36, 999), "AGE")
+		violates(t, db.UpdatePoint(1, []byte("Ada"), []byte("")), "NAME")
+		if err := db.UpdatePoint(2, 36, 37); err != nil {
+			t.Errorf("UpdatePoint failed: %v", err)
+		}
+		row, _ := db.PKeyQuery(1)
+		if row[2] != 37 {
+			t.Errorf("unexpected row %v", row)
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		db.Close()
+		if db, err = core.OpenDBWithOptions("check_people", opts); err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		tD := db.TableDef()
+		if len(tD.Checks) != 5 || tD.Checks[3].Pattern != "^[A-Z]{3}$" || tD.Checks[2].High != 150 {
+			t.Errorf("unexpected checks %v", tD.Checks)
+		}
+		if tD.Defaults["AGE"] != 30 || string(tD.Defaults["CODE"].([]byte)) != "AAA" {
+			t.Errorf("unexpected defaults %v", tD.Defaults)
+		}
+		violates(t, db.Insert(7, []byte("Gus"), 40, []byte("ABCD"), 1), "CODE")
+		if err := db.InsertRow(map[string]any{"id": 7, "name": []byte("Gus"), "score": 1}); err != nil {
+			t.Errorf("InsertRow failed: %v", err)
+		}
+	})
+
+	t.Run("InvalidDefinitions", func(t *testing.T) {
+		for name, tD := range map[string]*core.TableDef{
+			"check_bad_type":    {Checks: []core.Check{core.CheckRange("name", 1, 2)}},
+			"check_bad_length":  {Checks: []core.Check{core.CheckMaxLength("age", 2)}},
+			"check_bad_range":   {Checks: []core.Check{core.CheckRange("age", 5, 1)}},
+			"check_bad_greater": {Checks: []core.Check{core.CheckGreater("age", math.MaxInt)}},
+			"check_bad_less":    {Checks: []core.Check{core.CheckLess("age", math.MinInt)}},
+			"check_bad_regex":   {Checks: []core.Check{core.CheckRegex("name", "[a-")}},
+			"check_bad_column":  {Checks: []core.Check{core.CheckMin("height", 0)}},
+			"check_bad_default": {Defaults: map[string]any{"age": -3}, Checks: []core.Check{core.CheckMin("age", 0)}},
+			"check_bad_dtype":   {Defaults: map[string]any{"age": []byte("old")}},
+			"check_pk_default":  {Defaults: map[string]any{"id": 1}},
+			"check_huge_regex":  {Checks: []core.Check{core.CheckRegex("name", "^"+strings.Repeat("a", 5000)+"$")}},
+		} {
+			tD.Cols = []string{"ID", "NAME", "AGE"}
+			tD.Types = []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64}
+			if _, err := core.DbInitWithOptions(name, tD, opts); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+			if exists, _ := core.TableExistsWithOptions(name, opts); exists {
+				t.Errorf("%s: table created despite the invalid definition", name)
+			}
+		}
+	})
+}
+
+// TestSQLCheckConstraints tests DEFAULT and CHECK in CREATE TABLE and INSERT statements leaving columns out
+func TestSQLCheckConstraints(t *testing.T) {
+	e := sql.NewEngineWithOptions(memoryOptions())
+	defer e.Close()
+
+	execSQL(t, e, `CREATE TABLE sqlcheck_items (
+		id INT PRIMARY KEY,
+		name TEXT CHECK (name <> '') CHECK (LENGTH(name) < 9),
+		qty INT DEFAULT 1 CHECK (qty > 0),
+		price INT DEFAULT 100,
+		sku TEXT DEFAULT 'SKU-0' CHECK (sku REGEXP '^SKU-[0-9]+$'),
+		CHECK (price BETWEEN 0 AND 10000)
+	)`)
+	execSQL(t, e, "INSERT INTO sqlcheck_items (id, name) VALUES (1, 'Pen'), (2, 'Pencil')")
+	execSQL(t, e, "INSERT INTO sqlcheck_items (name, id, qty, sku) VALUES ('Ink', 3, 5, 'SKU-42')")
+	res := execSQL(t, e, "SELECT qty, price, sku FROM sqlcheck_items WHERE id = 1")
+	if len(res.Rows) != 1 || res.Row(0).Int("qty") != 1 || res.Row(0).Int("price") != 100 || res.Row(0).String("sku") != "SKU-0" {
+		t.Errorf("expected the defaults, got %v", res.Rows)
+	}
+
+	for _, query := range []string{
+		"INSERT INTO sqlcheck_items (id, name, qty) VALUES (4, 'Pad', 0)",
+		"INSERT INTO sqlcheck_items (id, name) VALUES (4, 'Notebooks')",
+		"INSERT INTO sqlcheck_items (id, name) VALUES (4, '')",
+		"INSERT INTO sqlcheck_items (id, name, sku) VALUES (4, 'Pad', 'sku-1')",
+		"INSERT INTO sqlcheck_items (id) VALUES (4)",
+		"UPDATE sqlcheck_items SET price = 20000 WHERE id = 3",
+		"CREATE TABLE sqlcheck_bad (id INT, name TEXT CHECK (name > 3))",
+		"CREATE TABLE sqlcheck_bad (id INT, b INT CHECK (b > 9223372036854775807))",
+		"CREATE TABLE sqlcheck_bad (id INT, CHECK (missing >= 0))",
+	} {
+		if _, err := e.Exec(query); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+	res = execSQL(t, e, "SELECT * FROM sqlcheck_items")
+	if len(res.Rows) != 3 {
+		t.Errorf("expected 3 rows, got %d", len(res.Rows))
+	}
+}
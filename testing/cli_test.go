@@ -0,0 +1,91 @@
+package testing
+
+import (
+	"BynxDB/cli"
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCLI(t *testing.T, stdin string, args ...string) (string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String() + stderr.String(), code
+}
+
+// TestCLI tests the bynx subcommands and the shell
+func TestCLI(t *testing.T) {
+	tempDataDir(t)
+	history := filepath.Join(t.TempDir(), "history")
+
+	out, code := runCLI(t, "", "create-table", "cli_staff", "id:int:pk", "name:text", "badge:int:unique")
+	if code != 0 {
+		t.Fatalf("create-table failed: %s", out)
+	}
+	for _, args := range [][]string{
+		{"insert", "cli_staff", "id=1", "name=Ada Lovelace", "badge=100"},
+		{"insert", "cli_staff", "id=2", "name=Alan", "badge=101"},
+	} {
+		if out, code := runCLI(t, "", args...); code != 0 {
+			t.Fatalf("insert failed: %s", out)
+		}
+	}
+	if out, code := runCLI(t, "", "insert", "cli_staff", "id=x", "name=Bad", "badge=1"); code == 0 {
+		t.Errorf("Expected insert of a non integer to fail: %s", out)
+	}
+
+	out, _ = runCLI(t, "", "tables")
+	if !strings.Contains(out, "CLI_STAFF") {
+		t.Errorf("Table not listed: %s", out)
+	}
+
+	out, code = runCLI(t, "", "query", "cli_staff", "badge", ">", "100")
+	if code != 0 || !strings.Contains(out, "|  2 | Alan |") {
+		t.Errorf("Unexpected query output: %s", out)
+	}
+	if !strings.Contains(out, "(1 row)") || strings.Contains(out, "Ada") {
+		t.Errorf("Unexpected query output: %s", out)
+	}
+
+	script := strings.Join([]string{
+		"INSERT INTO cli_staff",
+		"VALUES (3, 'Grace', 102);",
+		"SELECT name FROM cli_staff WHERE id = 3;",
+		".schema",
+		"!2",
+		".history",
+		".quit",
+	}, "\n")
+	out, code = runCLI(t, script, "-history", history, "open", "cli_staff")
+	if code != 0 {
+		t.Fatalf("open failed: %s", out)
+	}
+	for _, want := range []string{"1 row inserted", "| Grace |", "PRIMARY KEY", "UNIQUE", "3  .schema"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Shell output is missing %q:\n%s", want, out)
+		}
+	}
+	// * !2 runs the SELECT again
+	if strings.Count(out, "| Grace |") != 2 {
+		t.Errorf("History recall did not rerun the statement:\n%s", out)
+	}
+
+	// * History is kept between sessions
+	out, _ = runCLI(t, "!1\n", "-history", history, "shell")
+	if !strings.Contains(out, "INSERT INTO cli_staff VALUES (3, 'Grace', 102);") || !strings.Contains(out, "already") {
+		t.Errorf("History not restored:\n%s", out)
+	}
+
+	out, code = runCLI(t, "", "delete", "cli_staff", "id = 1 OR name = 'Grace'")
+	if code != 0 || !strings.Contains(out, "2 rows deleted") {
+		t.Errorf("Unexpected delete output: %s", out)
+	}
+	if out, code := runCLI(t, "", "query", "no_such_table"); code == 0 {
+		t.Errorf("Expected query on a missing table to fail: %s", out)
+	}
+	if _, code := runCLI(t, "", "frobnicate"); code != 2 {
+		t.Errorf("Expected usage error for unknown command, got %d", code)
+	}
+}
@@ -0,0 +1,118 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCompact tests that Compact shrinks the files of a table after deletes and leaves its rows and index intact
+func TestCompact(t *testing.T) {
+	dir := tempDataDir(t)
+	db, err := core.DbInit("compact_items", &core.TableDef{
+		Cols:       []string{"ID", "CODE", "QTY"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64},
+		UniqueCols: []int{1},
+	})
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { db.Close() }()
+	code := func(id int) []byte { return []byte(fmt.Sprintf("CODE-%05d", id)) }
+	size := func() int64 {
+		t.Helper()
+		var total int64
+		for _, name := range []string{"COMPACT_ITEMSrec", "COMPACT_ITEMSCODE"} {
+			info, err := os.Stat(filepath.Join(dir, name+".db"))
+			if err != nil {
+				t.Fatalf("Stat failed: %v", err)
+			}
+			total += info.Size()
+		}
+		return total
+	}
+	check := func(t *testing.T, ids []int) {
+		t.Helper()
+		rows, err := db.Query()
+		if err != nil || len(rows) != len(ids) {
+			t.Fatalf("expected %d rows, got %d %v", len(ids), len(rows), err)
+		}
+		for i, id := range ids {
+			if rows[i][0] != id || string(rows[i][1].([]byte)) != string(code(id)) {
+				t.Errorf("unexpected row %v, expected id %d", rows[i], id)
+			}
+			if row, err := db.PointQueryUniqueCol(1, code(id)); err != nil || row[0] != id {
+				t.Errorf("index lookup of %d: %v %v", id, row, err)
+			}
+		}
+		if count, err := db.Count(); err != nil || count != len(ids) {
+			t.Errorf("expected a count of %d, got %d %v", len(ids), count, err)
+		}
+	}
+
+	var rows [][]any
+	for i := 0; i < 4000; i++ {
+		rows = append(rows, []any{i, code(i), i})
+	}
+	if err := db.InsertBatch(rows); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	if _, err := db.DeleteRange("id", 100, 3899); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	var ids []int
+	for i := 0; i < 4000; i++ {
+		if i < 100 || i >= 3900 {
+			ids = append(ids, i)
+		}
+	}
+
+	before := size()
+	reclaimed, err := db.Compact()
+	if err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	after := size()
+	if reclaimed <= 0 || before-after != reclaimed {
+		t.Errorf("expected %d bytes reclaimed, got %d", before-after, reclaimed)
+	}
+	if after > before/4 {
+		t.Errorf("expected the files to shrink from %d, got %d", before, after)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "COMPACT_ITEMSrec.db.compact")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the temporary file to be gone, got %v", err)
+	}
+	check(t, ids)
+
+	// * Compacting a compacted table gives nothing back
+	if reclaimed, err := db.Compact(); err != nil || reclaimed != 0 {
+		t.Errorf("expected nothing reclaimed, got %d %v", reclaimed, err)
+	}
+
+	// * The table keeps working on the new files, also after reopening
+	if err := db.Insert(5000, code(5000), 1); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := db.Delete(0, 0); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	ids = append(ids[1:], 5000)
+	db.Close()
+	if db, err = core.OpenDB("compact_items"); err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	check(t, ids)
+
+	db.Close()
+	out, exitCode := runCLI(t, "", "compact", "compact_items")
+	if exitCode != 0 || !strings.Contains(out, "compacted COMPACT_ITEMS, ") || !strings.Contains(out, "bytes reclaimed") {
+		t.Errorf("Unexpected compact output: %s", out)
+	}
+	if db, err = core.OpenDB("compact_items"); err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+}
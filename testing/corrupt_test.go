@@ -0,0 +1,127 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// rewritePage lets fn change the data of a page after its header and writes it back with a valid checksum
+func rewritePage(t *testing.T, f *os.File, pageNum int64, fn func(data []byte)) {
+	t.Helper()
+	buf := make([]byte, os.Getpagesize())
+	if _, err := f.ReadAt(buf, pageNum*int64(len(buf))); err != nil {
+		t.Fatalf("ReadAt failed: %v", err)
+	}
+	fn(buf[16:])
+	binary.LittleEndian.PutUint32(buf, crc32.Checksum(buf[4:], crc32.MakeTable(crc32.Castagnoli)))
+	if _, err := f.WriteAt(buf, pageNum*int64(len(buf))); err != nil {
+		t.Fatalf("WriteAt failed: %v", err)
+	}
+}
+
+// TestCorruptPages tests that damaged pages are reported as ErrCorrupt with their page number instead of panicking
+func TestCorruptPages(t *testing.T) {
+	dir := tempDataDir(t)
+	tDef := &core.TableDef{
+		Cols:  []string{"ID", "NAME"},
+		Types: []uint16{core.TYPE_INT64, core.TYPE_BYTE},
+	}
+	db, err := core.DbInit("corrupt_items", tDef)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() {
+		if db != nil {
+			db.Close()
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if err := db.Insert(i, []byte(fmt.Sprintf("name-%d", i))); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	db.Close()
+	db = nil
+	path := filepath.Join(dir, "CORRUPT_ITEMSrec.db")
+	pageSize := int64(os.Getpagesize())
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	root := int64(binary.LittleEndian.Uint64(saved[32:]))
+	// * damage restores the saved file, lets fn change it and returns the error of reading every row
+	damage := func(fn func(f *os.File)) error {
+		t.Helper()
+		if err := os.WriteFile(path, saved, 0666); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		f, err := os.OpenFile(path, os.O_RDWR, 0666)
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		fn(f)
+		f.Close()
+		if db, err = core.OpenDB("corrupt_items"); err != nil {
+			return err
+		}
+		_, err = db.Query()
+		db.Close()
+		db = nil
+		return err
+	}
+	expect := func(t *testing.T, err error, page int64) {
+		t.Helper()
+		var corrupt *core.CorruptPageError
+		if !errors.Is(err, core.ErrCorrupt) || !errors.As(err, &corrupt) {
+			t.Fatalf("expected ErrCorrupt, got %v", err)
+		}
+		if corrupt.Page != uint64(page) {
+			t.Errorf("expected page %d to be reported, got %v", page, err)
+		}
+	}
+
+	t.Run("Checksum", func(t *testing.T) {
+		err := damage(func(f *os.File) {
+			f.WriteAt([]byte{0xff}, root*pageSize+pageSize-10)
+		})
+		expect(t, err, root)
+	})
+
+	t.Run("Meta", func(t *testing.T) {
+		err := damage(func(f *os.File) {
+			f.WriteAt([]byte{0xff, 0xff}, 40)
+		})
+		expect(t, err, 0)
+	})
+
+	t.Run("WrongPage", func(t *testing.T) {
+		// * The root page copied over the freelist page, valid except for its page number
+		err := damage(func(f *os.File) {
+			f.WriteAt(saved[root*pageSize:(root+1)*pageSize], pageSize)
+		})
+		expect(t, err, 1)
+	})
+
+	t.Run("Node", func(t *testing.T) {
+		// * A valid checksum over item offsets pointing outside the page
+		err := damage(func(f *os.File) {
+			rewritePage(t, f, root, func(data []byte) {
+				binary.LittleEndian.PutUint16(data[1:], 3)
+				for i := 3; i < 30; i++ {
+					data[i] = 0xff
+				}
+			})
+		})
+		expect(t, err, root)
+	})
+
+	if err := damage(func(f *os.File) {}); err != nil {
+		t.Errorf("Query of the restored file failed: %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// crashStep is a write of the crash workload along with what it does to the rows, keyed by ID. A step that fails
+// is refused by the table, after some of it may have been written, and leaves the rows as they were.
+type crashStep struct {
+	name  string
+	do    func(db *core.DB) error
+	apply func(rows map[int]string)
+	fails bool
+}
+
+func crashTableDef() *core.TableDef {
+	return &core.TableDef{
+		Cols:       []string{"ID", "CODE", "TAG", "NAME"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_BYTE, core.TYPE_BYTE},
+		UniqueCols: []int{1, 2},
+	}
+}
+
+func crashCode(id int) []byte { return []byte(fmt.Sprintf("CODE-%06d", id)) }
+
+func crashTag(id int) []byte { return []byte(fmt.Sprintf("TAG-%06d", id)) }
+
+func crashInsert(db *core.DB, id int, val string) error {
+	return db.Insert(id, crashCode(id), crashTag(id), []byte(val))
+}
+
+// crashSteps plans n random writes, inserts splitting nodes, updates, deletes merging them, batches, compactions and
+// writes clashing with a unique column after writing another one, against the rows they start from
+func crashSteps(rng *rand.Rand, rows map[int]string, n int) []crashStep {
+	rows = maps.Clone(rows)
+	nextID := 0
+	for id := range rows {
+		nextID = max(nextID, id+1)
+	}
+	anyID := func() int {
+		ids := slices.Sorted(maps.Keys(rows))
+		return ids[rng.Intn(len(ids))]
+	}
+	name := func() string { return fmt.Sprintf("name-%d-%s", rng.Intn(1000), strings.Repeat("x", rng.Intn(40))) }
+	var steps []crashStep
+	for len(steps) < n {
+		var step crashStep
+		switch r := rng.Intn(43); {
+		case r < 20 || len(rows) < 10:
+			id, val := nextID, name()
+			nextID++
+			step = crashStep{name: fmt.Sprint("insert ", id),
+				do:    func(db *core.DB) error { return crashInsert(db, id, val) },
+				apply: func(rows map[int]string) { rows[id] = val }}
+		case r < 28:
+			id, val := anyID(), name()
+			step = crashStep{name: fmt.Sprint("update ", id),
+				do:    func(db *core.DB) error { return db.Update(id, map[string]any{"NAME": []byte(val)}) },
+				apply: func(rows map[int]string) { rows[id] = val }}
+		case r < 36:
+			id := anyID()
+			step = crashStep{name: fmt.Sprint("delete ", id),
+				do:    func(db *core.DB) error { return db.Delete(0, id) },
+				apply: func(rows map[int]string) { delete(rows, id) }}
+		case r < 37:
+			// * The codes are new, only the row is already there
+			id, fresh := anyID(), nextID
+			step = crashStep{name: fmt.Sprint("insert existing ", id),
+				do:    func(db *core.DB) error { return db.Insert(id, crashCode(fresh), crashTag(fresh), []byte("again")) },
+				apply: func(rows map[int]string) {}, fails: true}
+		case r < 38:
+			// * The CODE index is written before the TAG index refuses the row
+			id, other := nextID, anyID()
+			step = crashStep{name: fmt.Sprint("insert ", id, " with the tag of ", other),
+				do:    func(db *core.DB) error { return db.Insert(id, crashCode(id), crashTag(other), []byte("clash")) },
+				apply: func(rows map[int]string) {}, fails: true}
+		case r < 40:
+			id, other := anyID(), anyID()
+			for other == id {
+				other = anyID()
+			}
+			step = crashStep{name: fmt.Sprint("update ", id, " to the code of ", other),
+				do:    func(db *core.DB) error { return db.Update(id, map[string]any{"CODE": crashCode(other)}) },
+				apply: func(rows map[int]string) {}, fails: true}
+		case r < 42:
+			first, val := nextID, name()
+			nextID += 20
+			step = crashStep{name: fmt.Sprint("batch ", first),
+				do: func(db *core.DB) error {
+					batch := make([][]any, 20)
+					for i := range batch {
+						batch[i] = []any{first + i, crashCode(first + i), crashTag(first + i), []byte(val)}
+					}
+					return db.InsertBatch(batch)
+				},
+				apply: func(rows map[int]string) {
+					for i := 0; i < 20; i++ {
+						rows[first+i] = val
+					}
+				}}
+		default:
+			step = crashStep{name: "compact",
+				do: func(db *core.DB) error {
+					_, err := db.Compact()
+					return err
+				},
+				apply: func(rows map[int]string) {}}
+		}
+		step.apply(rows)
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// checkCrashRows checks the table holds one of the given sets of rows, the unique indexes included
+func checkCrashRows(t *testing.T, db *core.DB, want ...map[int]string) {
+	t.Helper()
+	if _, err := db.Verify(); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	rows, err := db.Query()
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	got := map[int]string{}
+	for _, row := range rows {
+		got[row[0].(int)] = string(row[3].([]byte))
+	}
+	for _, w := range want {
+		if maps.Equal(got, w) {
+			if count, err := db.Count(); err != nil || count != len(w) {
+				t.Errorf("expected a count of %d, got %d %v", len(w), count, err)
+			}
+			for id := range w {
+				if row, err := db.PointQueryUniqueCol(1, crashCode(id)); err != nil || row[0] != id {
+					t.Fatalf("PointQueryUniqueCol of %d failed: %v %v", id, row, err)
+				}
+				if row, err := db.PointQueryUniqueCol(2, crashTag(id)); err != nil || row[0] != id {
+					t.Fatalf("PointQueryUniqueCol of the tag of %d failed: %v %v", id, row, err)
+				}
+			}
+			return
+		}
+	}
+	t.Fatalf("the table holds %d rows matching none of the %d expected states", len(got), len(want))
+}
+
+// TestCrashRecovery tests that a table written through a storage failing at a random write, torn or not, with the
+// process dying, the machine losing power or the process going on, reopens consistent: it holds every write that
+// returned and the one that failed either whole or not at all. Writes the table refuses are rolled back, with or
+// without a fault
+func TestCrashRecovery(t *testing.T) {
+	const name = "crash_items"
+	dir, err := core.DataDir()
+	if err != nil {
+		t.Fatalf("DataDir failed: %v", err)
+	}
+	journal := filepath.Join(dir, "CRASH_ITEMS.journal")
+	initial := map[int]string{}
+	for i := 0; i < 200; i++ {
+		initial[i] = fmt.Sprint("initial-", i)
+	}
+	// setup creates the table with its initial rows in a new memory storage
+	setup := func(t *testing.T) *core.MemoryStorage {
+		mem := core.NewMemoryStorage()
+		db, err := core.DbInitWithOptions(name, crashTableDef(), &core.Options{Storage: mem, PageSize: core.MIN_PAGE_SIZE})
+		if err != nil {
+			t.Fatalf("DbInit failed: %v", err)
+		}
+		defer db.Close()
+		for id, val := range initial {
+			if err := crashInsert(db, id, val); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		return mem
+	}
+
+	rng := rand.New(rand.NewSource(50))
+	for workload := 0; workload < 3; workload++ {
+		steps := crashSteps(rng, initial, 60)
+		// A run without a fault counts the writes a fault can hit, closing the table included
+		mem := setup(t)
+		counter := core.NewFaultStorage(mem)
+		db, err := core.OpenDBWithOptions(name, &core.Options{Storage: counter})
+		if err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		final := maps.Clone(initial)
+		for _, step := range steps {
+			err := step.do(db)
+			if step.fails {
+				// * A refused write is rolled back whole, whatever it wrote before it was refused
+				if err == nil || errors.Is(err, core.ErrInjected) {
+					t.Fatalf("expected %s to be refused, got %v", step.name, err)
+				}
+				if exists, _ := mem.Exists(journal); exists {
+					t.Fatalf("expected the journal of %s to be removed", step.name)
+				}
+				checkCrashRows(t, db, final)
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s failed: %v", step.name, err)
+			}
+			step.apply(final)
+		}
+		db.Close()
+		ops := counter.Ops()
+		db, err = core.OpenDBWithOptions(name, &core.Options{Storage: mem})
+		if err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		checkCrashRows(t, db, final)
+		db.Close()
+
+		for trial := 0; trial < 20; trial++ {
+			fault := &core.FaultStorage{FailAt: 1 + rng.Intn(ops), Torn: rng.Intn(2) == 0, Crash: rng.Intn(4) != 0}
+			// * A power loss drops what wasn't synced as well
+			fault.DropUnsynced = fault.Crash && rng.Intn(2) == 0
+			t.Run(fmt.Sprintf("Workload%d/FailAt%d", workload, fault.FailAt), func(t *testing.T) {
+				mem := setup(t)
+				fault.Storage = mem
+				db, err := core.OpenDBWithOptions(name, &core.Options{Storage: fault})
+				if err != nil {
+					t.Fatalf("OpenDB failed: %v", err)
+				}
+				before := maps.Clone(initial)
+				after := before
+				failed := false
+				for _, step := range steps {
+					err := step.do(db)
+					after = maps.Clone(before)
+					step.apply(after)
+					if step.fails && err == nil {
+						t.Fatalf("expected %s to be refused", step.name)
+					}
+					// A refused write the fault didn't hit, or hit while rolling it back, leaves the rows as they were
+					if step.fails && !errors.Is(err, core.ErrInjected) {
+						continue
+					}
+					if err != nil {
+						if !errors.Is(err, core.ErrInjected) {
+							t.Fatalf("%s failed with %v, expected the injected fault", step.name, err)
+						}
+						t.Logf("%s failed, torn %v, crash %v, power loss %v", step.name, fault.Torn, fault.Crash,
+							fault.DropUnsynced)
+						failed = true
+						break
+					}
+					before = after
+				}
+				if failed && !fault.Crash {
+					// The process goes on. A write that failed halfway leaves the table unwritable until it is
+					// opened again, one failing before it changed anything, like a compaction, leaves it as it was
+					if err := crashInsert(db, -1, "after"); err == nil {
+						before[-1], after = "after", before
+					} else if !errors.Is(err, core.ErrInjected) {
+						t.Errorf("expected the injected fault to fail the insert, got %v", err)
+					}
+				}
+				// A process that died doesn't close the table, the fault may hit closing it otherwise
+				if !fault.Crashed() {
+					db.Close()
+				}
+
+				db, err = core.OpenDBWithOptions(name, &core.Options{Storage: mem})
+				if err != nil {
+					t.Fatalf("OpenDB after the fault failed: %v", err)
+				}
+				defer db.Close()
+				if exists, _ := mem.Exists(journal); exists {
+					t.Errorf("expected the journal to be rolled back and removed")
+				}
+				checkCrashRows(t, db, before, after)
+				if err := crashInsert(db, -2, "after"); err != nil {
+					t.Errorf("Insert after the recovery failed: %v", err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"BynxDB/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestDeleteRange tests range and predicate deletes spanning several delete batches, checking the unique index and
+// the row count stay in step with the records
+func TestDeleteRange(t *testing.T) {
+	db, err := core.DbInitWithOptions("delete_range", &core.TableDef{
+		Cols:       []string{"ID", "EMAIL", "GROUPNO"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64},
+		UniqueCols: []int{1},
+	}, memoryOptions())
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer db.Close()
+
+	const total = 5000
+	email := func(id int) []byte { return []byte(fmt.Sprintf("user%05d@bynx", id)) }
+	var rows [][]any
+	for i := 0; i < total; i++ {
+		rows = append(rows, []any{i, email(i), i % 4})
+	}
+	if err := db.InsertBatch(rows); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+	alive := map[int]bool{}
+	for i := 0; i < total; i++ {
+		alive[i] = true
+	}
+	// * check compares the table, its unique index and its row count with alive
+	check := func(t *testing.T) {
+		t.Helper()
+		rows, err := db.Query()
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(rows) != len(alive) {
+			t.Errorf("expected %d rows, got %d", len(alive), len(rows))
+		}
+		for _, row := range rows {
+			if !alive[row[0].(int)] {
+				t.Errorf("row %v should have been deleted", row)
+			}
+		}
+		if count, err := db.Count(); err != nil || count != len(alive) {
+			t.Errorf("expected a count of %d, got %d %v", len(alive), count, err)
+		}
+		for _, id := range []int{0, 1200, 2500, 3999, 4001, total - 1} {
+			_, err := db.PointQueryUniqueCol(1, email(id))
+			if alive[id] != (err == nil) || (!alive[id] && !errors.Is(err, core.ErrNotFound)) {
+				t.Errorf("index lookup of %d: alive %v, got %v", id, alive[id], err)
+			}
+		}
+	}
+
+	t.Run("PrimaryKeyRange", func(t *testing.T) {
+		n, err := db.DeleteRange("id", 1000, 3499)
+		if err != nil || n != 2500 {
+			t.Fatalf("expected 2500 rows deleted, got %d %v", n, err)
+		}
+		for i := 1000; i <= 3499; i++ {
+			delete(alive, i)
+		}
+		check(t)
+	})
+
+	t.Run("UniqueColumnRange", func(t *testing.T) {
+		n, err := db.DeleteRange("email", email(3900), email(4099))
+		if err != nil || n != 200 {
+			t.Fatalf("expected 200 rows deleted, got %d %v", n, err)
+		}
+		for i := 3900; i <= 4099; i++ {
+			delete(alive, i)
+		}
+		check(t)
+	})
+
+	t.Run("Predicate", func(t *testing.T) {
+		// * A residual filter on a column without an index, matching rows spread over the whole table
+		n, err := db.DeleteWhere(core.Eq("groupno", 1), core.Ge("id", 100))
+		want := 0
+		for id := range alive {
+			if id%4 == 1 && id >= 100 {
+				delete(alive, id)
+				want++
+			}
+		}
+		if err != nil || n != want {
+			t.Fatalf("expected %d rows deleted, got %d %v", want, n, err)
+		}
+		check(t)
+		if n, err := db.DeleteWhere(core.Gt("id", total)); err != nil || n != 0 {
+			t.Errorf("expected nothing deleted, got %d %v", n, err)
+		}
+		if err := db.Delete(2, 2); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		for id := range alive {
+			if id%4 == 2 {
+				delete(alive, id)
+			}
+		}
+		check(t)
+	})
+
+	t.Run("Everything", func(t *testing.T) {
+		n, err := db.DeleteWhere()
+		if err != nil || n != len(alive) {
+			t.Fatalf("expected %d rows deleted, got %d %v", len(alive), n, err)
+		}
+		alive = map[int]bool{}
+		check(t)
+		if _, err := db.DeleteRange("missing", 1, 2); err == nil {
+			t.Errorf("expected an error for an unknown column")
+		}
+	})
+}
+
+// TestSQLDeleteCount tests that DELETE reports the rows it deleted
+func TestSQLDeleteCount(t *testing.T) {
+	e := sql.NewEngineWithOptions(memoryOptions())
+	defer e.Close()
+	execSQL(t, e, "CREATE TABLE sqldelete_items (id INT PRIMARY KEY, kind INT)")
+	for i := 0; i < 30; i++ {
+		execSQL(t, e, fmt.Sprintf("INSERT INTO sqldelete_items VALUES (%d, %d)", i, i%3))
+	}
+	for _, c := range []struct {
+		query string
+		want  int
+	}{
+		{"DELETE FROM sqldelete_items WHERE id BETWEEN 5 AND 9", 5},
+		{"DELETE FROM sqldelete_items WHERE kind = 0 AND id >= 20", 3},
+		{"DELETE FROM sqldelete_items WHERE kind = 1 OR id = 2", 10},
+		{"DELETE FROM sqldelete_items WHERE id > 100", 0},
+	} {
+		res := execSQL(t, e, c.query)
+		if res.RowsAffected != c.want {
+			t.Errorf("%s: expected %d rows, got %d", c.query, c.want, res.RowsAffected)
+		}
+	}
+	if res := execSQL(t, e, "SELECT * FROM sqldelete_items"); len(res.Rows) != 12 {
+		t.Errorf("expected %d rows left, got %d", 12, len(res.Rows))
+	}
+}
@@ -0,0 +1,329 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"BynxDB/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestForeignKeys tests that foreign keys are validated on insert and update and that RESTRICT, CASCADE and SET NULL
+// are applied when a parent row is deleted
+func TestForeignKeys(t *testing.T) {
+	opts := memoryOptions()
+	depts, err := core.DbInitWithOptions("fk_departments", &core.TableDef{
+		Cols:  []string{"ID", "NAME"},
+		Types: []uint16{core.TYPE_INT64, core.TYPE_BYTE},
+	}, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { depts.Close() }()
+	faculty, err := core.DbInitWithOptions("fk_faculty", &core.TableDef{
+		Cols:        []string{"NAME", "ID", "DEPARTMENT_ID"},
+		Types:       []uint16{core.TYPE_BYTE, core.TYPE_INT64, core.TYPE_INT64},
+		PKeyIndex:   1,
+		ForeignKeys: []core.ForeignKey{{Col: "department_id", RefTable: "fk_departments", OnDelete: core.FK_RESTRICT}},
+	}, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer faculty.Close()
+	assignments, err := core.DbInitWithOptions("fk_assignments", &core.TableDef{
+		Cols:        []string{"ID", "FACULTY_ID"},
+		Types:       []uint16{core.TYPE_INT64, core.TYPE_INT64},
+		ForeignKeys: []core.ForeignKey{{Col: "FACULTY_ID", RefTable: "FK_FACULTY", OnDelete: core.FK_CASCADE}},
+	}, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer assignments.Close()
+	rooms, err := core.DbInitWithOptions("fk_rooms", &core.TableDef{
+		Cols:        []string{"ID", "DEPARTMENT_ID"},
+		Types:       []uint16{core.TYPE_INT64, core.TYPE_INT64},
+		ForeignKeys: []core.ForeignKey{{Col: "DEPARTMENT_ID", RefTable: "FK_DEPARTMENTS", OnDelete: core.FK_SET_NULL}},
+	}, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer rooms.Close()
+
+	for i := 1; i <= 3; i++ {
+		if err := depts.Insert(i, []byte("Dept")); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	for i := 1; i <= 4; i++ {
+		if err := faculty.Insert(i, []byte("Faculty"), 1+i%2); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	for i := 1; i <= 8; i++ {
+		if err := assignments.Insert(i, 1+i%4); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	for i := 1; i <= 3; i++ {
+		if err := rooms.Insert(i, i); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	count := func(db *core.DB, preds ...core.Predicate) int {
+		t.Helper()
+		rows, err := db.Query(preds...)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		return len(rows)
+	}
+
+	t.Run("InsertUpdate", func(t *testing.T) {
+		err := faculty.Insert(10, []byte("Nobody"), 99)
+		if err == nil || !strings.Contains(err.Error(), "DEPARTMENT_ID") {
+			t.Errorf("expected a foreign key violation naming the column, got %v", err)
+		}
+		var batchErr *core.BatchError
+		err = faculty.InsertBatch([][]any{{10, []byte("Ok"), 3}, {11, []byte("Bad"), 42}})
+		if !errors.As(err, &batchErr) || batchErr.RowErrors[0] != nil || batchErr.RowErrors[1] == nil {
+			t.Errorf("expected the second batch row to be rejected, got %v", err)
+		}
+		if err := faculty.Update(1, map[string]any{"department_id": 99}); err == nil {
+			t.Errorf("expected a foreign key violation on update")
+		}
+		if err := faculty.Update(1, map[string]any{"department_id": 3}); err != nil {
+			t.Errorf("Update failed: %v", err)
+		}
+		// * Zero means no parent for SET NULL keys only
+		if err := rooms.Insert(10, 0); err != nil {
+			t.Errorf("expected the zero value to be accepted: %v", err)
+		}
+		if err := faculty.Insert(10, []byte("Zero"), 0); err == nil {
+			t.Errorf("expected a foreign key violation for the zero value of a RESTRICT key")
+		}
+		if err := depts.Update(3, map[string]any{"id": 30}); err == nil {
+			t.Errorf("expected an error changing a referenced primary key")
+		}
+		if err := depts.Update(3, map[string]any{"name": []byte("Renamed")}); err != nil {
+			t.Errorf("Update failed: %v", err)
+		}
+	})
+
+	t.Run("Restrict", func(t *testing.T) {
+		// * Department 2 is referenced by faculty (RESTRICT) and a room (SET NULL): nothing may change
+		if err := depts.Delete(0, 2); err == nil {
+			t.Fatalf("expected a foreign key violation")
+		}
+		if count(depts, core.Eq("id", 2)) != 1 || count(rooms, core.Eq("department_id", 2)) != 1 {
+			t.Errorf("a restricted delete changed rows")
+		}
+	})
+
+	t.Run("Cascade", func(t *testing.T) {
+		if n := count(assignments, core.Eq("faculty_id", 2)); n != 2 {
+			t.Fatalf("expected 2 assignments of faculty 2, got %d", n)
+		}
+		if err := faculty.Delete(0, 2); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if n := count(assignments, core.Eq("faculty_id", 2)); n != 0 {
+			t.Errorf("expected the assignments to be deleted, got %d", n)
+		}
+		if n := count(assignments); n != 6 {
+			t.Errorf("expected 6 assignments left, got %d", n)
+		}
+	})
+
+	t.Run("SetNull", func(t *testing.T) {
+		// * Department 1 is only referenced by a room once faculty 4 moves away
+		if err := faculty.Update(4, map[string]any{"department_id": 2}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if err := depts.Delete(0, 1); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		row, err := rooms.PKeyQuery(1)
+		if err != nil || row[1] != 0 {
+			t.Errorf("expected the room to lose its department, got %v %v", row, err)
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		depts.Close()
+		if depts, err = core.OpenDBWithOptions("fk_departments", opts); err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		refs := depts.TableDef().ReferencedBy
+		if strings.Join(refs, ",") != "FK_FACULTY,FK_ROOMS" {
+			t.Errorf("unexpected referencing tables %v", refs)
+		}
+		if err := depts.Delete(0, 2); err == nil {
+			t.Errorf("expected a foreign key violation after reopening")
+		}
+		fks := faculty.TableDef().ForeignKeys
+		if len(fks) != 1 || fks[0].Col != "DEPARTMENT_ID" || fks[0].RefTable != "FK_DEPARTMENTS" {
+			t.Errorf("unexpected foreign keys %v", fks)
+		}
+	})
+
+	t.Run("SelfReference", func(t *testing.T) {
+		employees, err := core.DbInitWithOptions("fk_employees", &core.TableDef{
+			Cols:        []string{"ID", "MANAGER"},
+			Types:       []uint16{core.TYPE_INT64, core.TYPE_INT64},
+			ForeignKeys: []core.ForeignKey{{Col: "MANAGER", RefTable: "FK_EMPLOYEES", OnDelete: core.FK_CASCADE}},
+		}, opts)
+		if err != nil {
+			t.Fatalf("DbInit failed: %v", err)
+		}
+		defer employees.Close()
+		for _, row := range [][]any{{1, 1}, {2, 1}, {3, 2}, {4, 1}} {
+			if err := employees.Insert(row...); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		if err := employees.Insert(5, 6); err == nil {
+			t.Errorf("expected a foreign key violation")
+		}
+		if err := employees.Delete(0, 2); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if n := count(employees); n != 2 {
+			t.Errorf("expected employee 3 to be deleted with its manager, %d left", n)
+		}
+		if err := employees.Delete(0, 1); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if n := count(employees); n != 0 {
+			t.Errorf("expected every employee to be deleted, %d left", n)
+		}
+	})
+
+	t.Run("BulkLoadSelfReference", func(t *testing.T) {
+		tDef := &core.TableDef{
+			Cols:        []string{"ID", "MANAGER"},
+			Types:       []uint16{core.TYPE_INT64, core.TYPE_INT64},
+			ForeignKeys: []core.ForeignKey{{Col: "MANAGER", RefTable: "FK_ORG", OnDelete: core.FK_SET_NULL}},
+		}
+		org, err := core.DbInitWithOptions("fk_org", tDef, opts)
+		if err != nil {
+			t.Fatalf("DbInit failed: %v", err)
+		}
+		defer org.Close()
+		// * Rows may reference rows later in the input
+		if _, err := org.BulkLoad(core.SliceIterator([][]any{{1, 3}, {2, 9}, {3, 0}}), nil); err == nil {
+			t.Errorf("expected a foreign key violation")
+		}
+		if n, err := org.BulkLoad(core.SliceIterator([][]any{{1, 3}, {2, 1}, {3, 0}}), nil); err != nil || n != 3 {
+			t.Errorf("BulkLoad failed: %d %v", n, err)
+		}
+		if err := org.InsertBatch([][]any{{4, 5}, {5, 2}}); err != nil {
+			t.Errorf("InsertBatch failed: %v", err)
+		}
+	})
+
+	t.Run("InvalidDefinitions", func(t *testing.T) {
+		for name, fk := range map[string]core.ForeignKey{
+			"fk_bad_table":  {Col: "REF", RefTable: "fk_missing"},
+			"fk_bad_type":   {Col: "NAME", RefTable: "fk_departments"},
+			"fk_bad_column": {Col: "MISSING", RefTable: "fk_departments"},
+			"fk_bad_action": {Col: "REF", RefTable: "fk_departments", OnDelete: 9},
+		} {
+			_, err := core.DbInitWithOptions(name, &core.TableDef{
+				Cols:        []string{"ID", "REF", "NAME"},
+				Types:       []uint16{core.TYPE_INT64, core.TYPE_INT64, core.TYPE_BYTE},
+				ForeignKeys: []core.ForeignKey{fk},
+			}, opts)
+			if err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+			if exists, _ := core.TableExistsWithOptions(name, opts); exists {
+				t.Errorf("%s: table created despite the invalid foreign key", name)
+			}
+		}
+	})
+}
+
+// TestSQLForeignKeys tests REFERENCES and FOREIGN KEY in CREATE TABLE
+func TestSQLForeignKeys(t *testing.T) {
+	e := sql.NewEngineWithOptions(memoryOptions())
+	defer e.Close()
+
+	for _, query := range []string{
+		"CREATE TABLE sqlfk_dept (id INT PRIMARY KEY, name TEXT)",
+		`CREATE TABLE sqlfk_staff (
+			id INT PRIMARY KEY,
+			dept INT REFERENCES sqlfk_dept(id) ON DELETE CASCADE,
+			mentor INT,
+			FOREIGN KEY (mentor) REFERENCES sqlfk_staff ON DELETE SET NULL
+		)`,
+		"INSERT INTO sqlfk_dept VALUES (1, 'Physics'), (2, 'Maths')",
+		"INSERT INTO sqlfk_staff VALUES (10, 1, 0), (11, 1, 10), (12, 2, 11)",
+	} {
+		execSQL(t, e, query)
+	}
+	if _, err := e.Exec("INSERT INTO sqlfk_staff VALUES (13, 3, 0)"); err == nil {
+		t.Error("Expected a foreign key violation")
+	}
+	if _, err := e.Exec("CREATE TABLE sqlfk_bad (id INT, dept INT REFERENCES sqlfk_dept(name))"); err == nil {
+		t.Error("Expected an error referencing a column that isn't the primary key")
+	}
+
+	execSQL(t, e, "DELETE FROM sqlfk_dept WHERE id = 1")
+	res := execSQL(t, e, "SELECT id, mentor FROM sqlfk_staff")
+	if len(res.Rows) != 1 || res.Row(0).Int("id") != 12 || res.Row(0).Int("mentor") != 0 {
+		t.Errorf("Unexpected rows after the cascade: %v", res.Rows)
+	}
+}
+
+// TestForeignKeyDefinitionSize tests that a table isn't created when the definition of the table it references can't
+// list it anymore
+func TestForeignKeyDefinitionSize(t *testing.T) {
+	opts := &core.Options{Storage: core.NewMemoryStorage(), PageSize: core.MIN_PAGE_SIZE}
+	parent, err := core.DbInitWithOptions("fk_full_parent", &core.TableDef{
+		Cols:   []string{"ID", "CODE"},
+		Types:  []uint16{core.TYPE_INT64, core.TYPE_BYTE},
+		Checks: []core.Check{core.CheckRegex("code", "^"+strings.Repeat("a", 850)+"$")},
+	}, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { parent.Close() }()
+
+	var children []string
+	for i := 0; ; i++ {
+		name := fmt.Sprintf("FK_FULL_CHILD_%02d", i)
+		child, err := core.DbInitWithOptions(name, &core.TableDef{
+			Cols:        []string{"ID", "PARENT_ID"},
+			Types:       []uint16{core.TYPE_INT64, core.TYPE_INT64},
+			ForeignKeys: []core.ForeignKey{{Col: "PARENT_ID", RefTable: "FK_FULL_PARENT", OnDelete: core.FK_RESTRICT}},
+		}, opts)
+		if err != nil {
+			if child, err := core.OpenDBWithOptions(name, opts); err == nil {
+				child.Close()
+				t.Errorf("%s created despite the full parent definition", name)
+			}
+			break
+		}
+		child.Close()
+		children = append(children, name)
+		if i == 50 {
+			t.Fatalf("expected the parent definition to fill up, %d children created", len(children))
+		}
+	}
+	if len(children) == 0 {
+		t.Fatalf("expected children to be created before the parent definition fills up")
+	}
+
+	parent.Close()
+	if parent, err = core.OpenDBWithOptions("fk_full_parent", opts); err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	if got := parent.TableDef().ReferencedBy; !slices.Equal(got, children) {
+		t.Errorf("expected ReferencedBy %v, got %v", children, got)
+	}
+	if _, err := parent.Verify(); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFileHeader tests that files keep the page size they were created with and that incompatible files are refused
+func TestFileHeader(t *testing.T) {
+	dir := tempDataDir(t)
+	tDef := &core.TableDef{
+		Cols:  []string{"ID", "NAME"},
+		Types: []uint16{core.TYPE_INT64, core.TYPE_BYTE},
+	}
+	db, err := core.DbInit("header_items", tDef)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	for i := 0; i < 300; i++ {
+		if err := db.Insert(i, []byte(fmt.Sprintf("name-%d", i))); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	db.Close()
+	path := filepath.Join(dir, "HEADER_ITEMSrec.db")
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	defer os.WriteFile(path, saved, 0666)
+	// * open writes data as the records file and opens the table
+	open := func(data []byte) (*core.DB, error) {
+		t.Helper()
+		if err := os.WriteFile(path, data, 0666); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		return core.OpenDB("header_items")
+	}
+	// * withMeta returns the saved file with fn applied to the header of the meta page
+	withMeta := func(fn func(header []byte)) []byte {
+		data := append([]byte{}, saved...)
+		fn(data[16:32])
+		binary.LittleEndian.PutUint32(data, crc32.Checksum(data[4:os.Getpagesize()], crc32.MakeTable(crc32.Castagnoli)))
+		return data
+	}
+
+	t.Run("PageSize", func(t *testing.T) {
+		// * The file as a machine with pages twice as large would have written it
+		pageSize := os.Getpagesize()
+		var data []byte
+		for off := 0; off < len(saved); off += pageSize {
+			buf := make([]byte, 2*pageSize)
+			copy(buf, saved[off:off+pageSize])
+			if off == 0 {
+				binary.LittleEndian.PutUint32(buf[28:], uint32(2*pageSize))
+			}
+			binary.LittleEndian.PutUint32(buf, crc32.Checksum(buf[4:], crc32.MakeTable(crc32.Castagnoli)))
+			data = append(data, buf...)
+		}
+		db, err := open(data)
+		if err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		defer db.Close()
+		rows, err := db.Query()
+		if err != nil || len(rows) != 300 {
+			t.Fatalf("expected 300 rows, got %d %v", len(rows), err)
+		}
+		for i := 300; i < 600; i++ {
+			if err := db.Insert(i, []byte(fmt.Sprintf("name-%d", i))); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		if _, err := db.Verify(); err != nil {
+			t.Errorf("Verify failed: %v", err)
+		}
+	})
+
+	t.Run("Incompatible", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			data []byte
+			want string
+		}{
+			"Garbage": {[]byte("not a database file at all"), "not a BynxDB file"},
+			"Empty":   {nil, "not a BynxDB file"},
+			"Magic":   {withMeta(func(h []byte) { copy(h, "NOTBYNX!") }), "not a BynxDB file"},
+			"Newer":   {withMeta(func(h []byte) { binary.LittleEndian.PutUint16(h[8:], 99) }), "format version 99 is newer"},
+			"Older":   {withMeta(func(h []byte) { binary.LittleEndian.PutUint16(h[8:], 0) }), "migrate"},
+			"PageSize": {withMeta(func(h []byte) { binary.LittleEndian.PutUint32(h[12:], 3000) }),
+				"unsupported page size 3000"},
+		} {
+			db, err := open(tc.data)
+			if err == nil {
+				db.Close()
+			}
+			if !errors.Is(err, core.ErrIncompatible) || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("%s: expected ErrIncompatible about %q, got %v", name, tc.want, err)
+			}
+		}
+	})
+
+	if db, err := open(saved); err != nil {
+		t.Errorf("OpenDB of the saved file failed: %v", err)
+	} else {
+		db.Close()
+	}
+}
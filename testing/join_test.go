@@ -0,0 +1,159 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// TestJoin tests inner and left joins with both strategies against joins computed with nested loops over the rows
+func TestJoin(t *testing.T) {
+	opts := memoryOptions()
+	depts, err := core.DbInitWithOptions("join_departments", &core.TableDef{
+		Cols:       []string{"ID", "NAME", "CODE"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_BYTE},
+		UniqueCols: []int{2},
+	}, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer depts.Close()
+	faculty, err := core.DbInitWithOptions("join_faculty", &core.TableDef{
+		Cols:  []string{"ID", "NAME", "DEPARTMENT_ID"},
+		Types: []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64},
+	}, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer faculty.Close()
+
+	// * Department 6 has no faculty, faculty of department 99 has no department
+	for i := 0; i < 7; i++ {
+		if err := depts.Insert(i, []byte(fmt.Sprintf("Dept_%d", i)), []byte(fmt.Sprintf("D%d", i))); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	for i := 0; i < 60; i++ {
+		dept := i % 6
+		if i%13 == 0 {
+			dept = 99
+		}
+		if err := faculty.Insert(i, []byte(fmt.Sprintf("Faculty_%d", i)), dept); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	deptRows, _ := depts.Query()
+	facultyRows, _ := faculty.Query()
+
+	// * nestedLoop joins outer and inner rows the slow way
+	nestedLoop := func(outer, inner [][]any, outerCol, innerCol int, left bool) []string {
+		var rows []string
+		for _, o := range outer {
+			matched := false
+			for _, i := range inner {
+				if fmt.Sprint(o[outerCol]) == fmt.Sprint(i[innerCol]) {
+					rows = append(rows, fmt.Sprint(append(append([]any{}, o...), i...)))
+					matched = true
+				}
+			}
+			if !matched && left {
+				rows = append(rows, fmt.Sprint(append(append([]any{}, o...), make([]any, len(inner[0]))...)))
+			}
+		}
+		sort.Strings(rows)
+		return rows
+	}
+	joined := func(t *testing.T, j *core.JoinIterator) []string {
+		t.Helper()
+		rows, err := j.Rows()
+		if err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+		var out []string
+		for _, row := range rows {
+			out = append(out, fmt.Sprint(row))
+		}
+		sort.Strings(out)
+		return out
+	}
+	same := func(t *testing.T, got, want []string) {
+		t.Helper()
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("expected %d rows %v\ngot %d rows %v", len(want), want, len(got), got)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		outer    [][]any
+		outerCol int
+		inner    *core.DB
+		innerRow [][]any
+		innerCol string
+		colIndex int
+		strategy core.JoinStrategy
+	}{
+		{"FacultyToDepartment", facultyRows, 2, depts, deptRows, "id", 0, core.JOIN_INDEX_NESTED_LOOP},
+		{"DepartmentToFaculty", deptRows, 0, faculty, facultyRows, "department_id", 2, core.JOIN_HASH},
+	}
+	for _, c := range cases {
+		for joinName, joinType := range map[string]core.JoinType{"Inner": core.JOIN_INNER, "Left": core.JOIN_LEFT} {
+			t.Run(c.name+"/"+joinName, func(t *testing.T) {
+				j, err := c.inner.Join(core.SliceIterator(c.outer), c.outerCol, c.innerCol, joinType)
+				if err != nil {
+					t.Fatalf("Join failed: %v", err)
+				}
+				if j.Strategy != c.strategy {
+					t.Errorf("expected %v, got %v", c.strategy, j.Strategy)
+				}
+				same(t, joined(t, j), nestedLoop(c.outer, c.innerRow, c.outerCol, c.colIndex, joinType == core.JOIN_LEFT))
+			})
+		}
+	}
+
+	t.Run("Chained", func(t *testing.T) {
+		// * Departments with their faculty, then the department of every faculty row again by its unique code
+		first, err := faculty.Join(core.SliceIterator(deptRows), 0, "department_id", core.JOIN_INNER)
+		if err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+		second, err := depts.Join(first, 2, "code", core.JOIN_INNER)
+		if err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+		if second.Explain() != "INDEX NESTED LOOP JOIN on JOIN_DEPARTMENTS.CODE" {
+			t.Errorf("unexpected explain %q", second.Explain())
+		}
+		rows, err := second.Rows()
+		if err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+		want := nestedLoop(deptRows, facultyRows, 0, 2, false)
+		if len(rows) != len(want) {
+			t.Fatalf("expected %d rows, got %d", len(want), len(rows))
+		}
+		for _, row := range rows {
+			if len(row) != 9 || fmt.Sprint(row[:3]) != fmt.Sprint(row[6:]) {
+				t.Errorf("unexpected row %v", row)
+			}
+		}
+		cols := core.JoinColumns(core.JoinColumns([]string{"ID", "NAME", "CODE"}, faculty), depts)
+		if len(cols) != 9 || cols[5] != "JOIN_FACULTY.DEPARTMENT_ID" || cols[8] != "JOIN_DEPARTMENTS.CODE" {
+			t.Errorf("unexpected columns %v", cols)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := depts.Join(core.SliceIterator(facultyRows), 2, "budget", core.JOIN_INNER); err == nil {
+			t.Errorf("expected an error for an unknown column")
+		}
+		j, err := depts.Join(core.SliceIterator(facultyRows), 1, "id", core.JOIN_LEFT)
+		if err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+		if _, err := j.Next(); err == nil {
+			t.Errorf("expected an error joining a byte column with an integer column")
+		}
+	})
+}
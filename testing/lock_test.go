@@ -0,0 +1,144 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLockHolder opens lock_items in a process of its own for TestLocking and holds it until its stdin is closed
+func TestLockHolder(t *testing.T) {
+	mode := os.Getenv("BYNX_LOCK_HOLDER")
+	if mode == "" {
+		t.Skip("only run by TestLocking")
+	}
+	db, err := core.OpenDBWithOptions("lock_items", &core.Options{ReadOnly: mode == "ro"})
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	os.Stdout.WriteString("holding\n")
+	io.Copy(io.Discard, os.Stdin)
+	db.Close()
+}
+
+// holdTable starts a process holding lock_items open, read only when readOnly is set, and returns what lets it go
+func holdTable(t *testing.T, readOnly bool) func() {
+	t.Helper()
+	mode := "rw"
+	if readOnly {
+		mode = "ro"
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLockHolder$")
+	cmd.Env = append(os.Environ(), "BYNX_LOCK_HOLDER="+mode)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("StdinPipe failed: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("StdoutPipe failed: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() && scanner.Text() != "holding" {
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			stdin.Close()
+			io.Copy(io.Discard, stdout)
+			cmd.Wait()
+		})
+	}
+	if scanner.Err() != nil || scanner.Text() != "holding" {
+		release()
+		t.Fatalf("the holding process didn't open the table")
+	}
+	return release
+}
+
+// TestLocking tests that a table open in another process can't be opened for writing, fails at once or after the
+// lock timeout and is shared by read only openers
+func TestLocking(t *testing.T) {
+	tempDataDir(t)
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "NAME"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE},
+		UniqueCols: []int{1},
+	}
+	db, err := core.DbInit("lock_items", tDef)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	if err := db.Insert(1, []byte("one")); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	// * A second open in the same process shares the lock
+	again, err := core.OpenDB("lock_items")
+	if err != nil {
+		t.Fatalf("OpenDB in the same process failed: %v", err)
+	}
+	again.Close()
+	db.Close()
+
+	t.Run("Exclusive", func(t *testing.T) {
+		release := holdTable(t, false)
+		defer release()
+		start := time.Now()
+		if _, err := core.OpenDB("lock_items"); !errors.Is(err, core.ErrLocked) {
+			t.Fatalf("expected ErrLocked, got %v", err)
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("failing took %v", time.Since(start))
+		}
+		start = time.Now()
+		if _, err := core.OpenDBWithOptions("lock_items", &core.Options{LockTimeout: 200 * time.Millisecond}); !errors.Is(err, core.ErrLocked) {
+			t.Fatalf("expected ErrLocked, got %v", err)
+		}
+		if time.Since(start) < 200*time.Millisecond {
+			t.Errorf("gave up after %v, before the timeout", time.Since(start))
+		}
+		if _, err := core.OpenDBWithOptions("lock_items", &core.Options{ReadOnly: true}); !errors.Is(err, core.ErrLocked) {
+			t.Errorf("expected ErrLocked opening read only, got %v", err)
+		}
+		if out, exitCode := runCLI(t, "", "query", "lock_items"); exitCode == 0 || !strings.Contains(out, "locked") {
+			t.Errorf("Unexpected query output: %s", out)
+		}
+
+		// * Waiting gets the table once the other process lets go of it
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			release()
+		}()
+		db, err := core.OpenDBWithOptions("lock_items", &core.Options{LockTimeout: 10 * time.Second})
+		if err != nil {
+			t.Fatalf("OpenDB waiting for the lock failed: %v", err)
+		}
+		db.Close()
+	})
+
+	t.Run("Shared", func(t *testing.T) {
+		release := holdTable(t, true)
+		defer release()
+		reader, err := core.OpenDBWithOptions("lock_items", &core.Options{ReadOnly: true})
+		if err != nil {
+			t.Fatalf("OpenDB read only failed: %v", err)
+		}
+		defer reader.Close()
+		if row, err := reader.PKeyQuery(1); err != nil || string(row[1].([]byte)) != "one" {
+			t.Errorf("PKeyQuery failed: %v %v", row, err)
+		}
+		if _, err := core.OpenDB("lock_items"); !errors.Is(err, core.ErrLocked) {
+			t.Errorf("expected ErrLocked opening for writing, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// toVersion0 lays a file out as the original format wrote it: the same pages without their header, the meta page
+// without the file header and the freelist, a single page here, with the max page in a u16
+func toVersion0(data []byte) []byte {
+	pageSize := os.Getpagesize()
+	freelistPage := int(binary.LittleEndian.Uint64(data[40:]))
+	legacy := make([]byte, len(data))
+	for off := 0; off < len(data); off += pageSize {
+		page := data[off : off+pageSize]
+		switch {
+		case bytes.Equal(page, make([]byte, pageSize)):
+		case off == 0:
+			copy(legacy, page[32:])
+		case off == freelistPage*pageSize:
+			binary.LittleEndian.PutUint16(legacy[off:], uint16(binary.LittleEndian.Uint64(page[16:])))
+			copy(legacy[off+2:], page[24:len(page)-8])
+		default:
+			copy(legacy[off:], page[16:])
+		}
+	}
+	return legacy
+}
+
+// TestMigrate tests that files of the original format are upgraded, checked by a dry run first and copied on request
+func TestMigrate(t *testing.T) {
+	dataDir := tempDataDir(t)
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "CODE", "QTY"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64},
+		UniqueCols: []int{1},
+	}
+	db, err := core.DbInit("migrate_items", tDef)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	code := func(id int) []byte { return []byte(fmt.Sprintf("CODE-%05d", id)) }
+	for i := 0; i < 500; i++ {
+		if err := db.Insert(i, code(i), i); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if _, err := db.DeleteRange("id", 100, 199); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	db.Close()
+
+	files := []string{"MIGRATE_ITEMSrec.db", "MIGRATE_ITEMSCODE.db"}
+	legacy := map[string][]byte{}
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dataDir, file))
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		legacy[file] = toVersion0(data)
+		if err := os.WriteFile(filepath.Join(dataDir, file), legacy[file], 0666); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	// * unchanged checks that the files still hold the original format
+	unchanged := func(t *testing.T) {
+		t.Helper()
+		for _, file := range files {
+			if data, _ := os.ReadFile(filepath.Join(dataDir, file)); !bytes.Equal(data, legacy[file]) {
+				t.Errorf("%s changed", file)
+			}
+		}
+		entries, _ := os.ReadDir(dataDir)
+		for _, entry := range entries {
+			if strings.Contains(entry.Name(), ".migrate") {
+				t.Errorf("temporary file %s left behind", entry.Name())
+			}
+		}
+	}
+
+	if _, err := core.OpenDB("migrate_items"); !errors.Is(err, core.ErrIncompatible) {
+		t.Fatalf("expected ErrIncompatible opening the old files, got %v", err)
+	}
+	if version, _, err := core.FileVersion(filepath.Join(dataDir, files[0]), os.Getpagesize()); err != nil || version != 0 {
+		t.Fatalf("expected version 0, got %d %v", version, err)
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		results, err := core.MigrateTable("migrate_items", &core.MigrateOptions{DryRun: true})
+		if err != nil || len(results) != 2 {
+			t.Fatalf("MigrateTable failed: %v %v", results, err)
+		}
+		for _, res := range results {
+			if res.From != 0 || res.To != core.FORMAT_VERSION || len(res.Steps) != core.FORMAT_VERSION {
+				t.Errorf("unexpected result %+v", res)
+			}
+		}
+		unchanged(t)
+		out, exitCode := runCLI(t, "", "migrate", "-dry-run", "migrate_items")
+		if exitCode != 0 || !strings.Contains(out, "would migrate 2 files") {
+			t.Errorf("Unexpected migrate output: %s", out)
+		}
+		unchanged(t)
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		dir := t.TempDir()
+		if _, err := core.MigrateTable("migrate_items", &core.MigrateOptions{Dir: dir}); err != nil {
+			t.Fatalf("MigrateTable failed: %v", err)
+		}
+		unchanged(t)
+		for _, file := range files {
+			if version, _, err := core.FileVersion(filepath.Join(dir, file), 0); err != nil || version != core.FORMAT_VERSION {
+				t.Errorf("%s: expected version %d, got %d %v", file, core.FORMAT_VERSION, version, err)
+			}
+		}
+		if _, err := core.MigrateTable("migrate_items", &core.MigrateOptions{Dir: dir}); err == nil {
+			t.Errorf("expected migrating over the copies to fail")
+		}
+	})
+
+	t.Run("InPlace", func(t *testing.T) {
+		out, exitCode := runCLI(t, "", "migrate", "migrate_items")
+		if exitCode != 0 || !strings.Contains(out, "migrated 2 files") {
+			t.Fatalf("Unexpected migrate output: %s", out)
+		}
+		db, err := core.OpenDB("migrate_items")
+		if err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		rows, err := db.Query()
+		if err != nil || len(rows) != 400 {
+			t.Fatalf("expected 400 rows, got %d %v", len(rows), err)
+		}
+		if row, err := db.PointQueryUniqueCol(1, code(250)); err != nil || row[0] != 250 {
+			t.Errorf("index lookup failed: %v %v", row, err)
+		}
+		for i := 100; i < 200; i++ {
+			if err := db.Insert(i, code(i), i); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		if _, err := db.Verify(); err != nil {
+			t.Errorf("Verify failed: %v", err)
+		}
+		db.Close()
+		out, exitCode = runCLI(t, "", "migrate", "migrate_items")
+		if exitCode != 0 || !strings.Contains(out, "MIGRATE_ITEMSrec.db: up to date") || !strings.Contains(out, "migrated 0 files") {
+			t.Errorf("Unexpected migrate output: %s", out)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestQueryPlanner tests the access path chosen for a set of predicates and that every plan returns the same rows
+// as filtering the whole table
+func TestQueryPlanner(t *testing.T) {
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "NAME", "CABIN", "DEPT"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64, core.TYPE_INT64},
+		UniqueCols: []int{2},
+	}
+	db, err := core.DbInitWithOptions("query_planner", tDef, memoryOptions())
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer db.Close()
+
+	// * Negative keys check that the key encoding keeps the value order
+	var all [][]any
+	for i := -100; i < 200; i++ {
+		row := []any{i, []byte(fmt.Sprintf("Name_%03d", i+100)), 5000 - i, i % 7}
+		all = append(all, row)
+		if err := db.Insert(row...); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	cases := []struct {
+		preds  []core.Predicate
+		access core.AccessPath
+		column string
+	}{
+		{[]core.Predicate{core.Eq("id", 42)}, core.ACCESS_PKEY_LOOKUP, "ID"},
+		{[]core.Predicate{core.Eq("cabin", 4990), core.Eq("dept", 3)}, core.ACCESS_INDEX_LOOKUP, "CABIN"},
+		{[]core.Predicate{core.Eq("cabin", 4990), core.Eq("id", 10)}, core.ACCESS_PKEY_LOOKUP, "ID"},
+		{[]core.Predicate{core.Between("id", -20, 30), core.Eq("dept", 2)}, core.ACCESS_PKEY_RANGE, "ID"},
+		{[]core.Predicate{core.Gt("id", -5), core.Le("id", 15), core.Ge("id", -3)}, core.ACCESS_PKEY_RANGE, "ID"},
+		{[]core.Predicate{core.Ge("cabin", 4900), core.Lt("cabin", 4950)}, core.ACCESS_INDEX_RANGE, "CABIN"},
+		{[]core.Predicate{core.Gt("id", 150), core.Between("cabin", 4800, 4900)}, core.ACCESS_INDEX_RANGE, "CABIN"},
+		{[]core.Predicate{core.Lt("id", -90)}, core.ACCESS_PKEY_RANGE, "ID"},
+		{[]core.Predicate{core.Eq("dept", 4), core.Ne("name", []byte("Name_004"))}, core.ACCESS_FULL_SCAN, ""},
+		{[]core.Predicate{core.Between("name", []byte("Name_010"), []byte("Name_020"))}, core.ACCESS_FULL_SCAN, ""},
+		{nil, core.ACCESS_FULL_SCAN, ""},
+	}
+	for _, c := range cases {
+		plan, err := db.Plan(c.preds...)
+		if err != nil {
+			t.Fatalf("Plan %v failed: %v", c.preds, err)
+		}
+		if plan.Access != c.access || plan.Column != c.column {
+			t.Errorf("%v: expected %v on %q, got:\n%s", c.preds, c.access, c.column, plan.Explain())
+		}
+		got, err := plan.Rows()
+		if err != nil {
+			t.Fatalf("Rows failed: %v", err)
+		}
+		want := filterRows(t, db, all, c.preds)
+		if len(got) != len(want) {
+			t.Errorf("%v: expected %d rows, got %d", c.preds, len(want), len(got))
+			continue
+		}
+		// * Index range scans return the rows in the order of the index column
+		wanted := map[string]bool{}
+		for _, row := range want {
+			wanted[fmt.Sprint(row)] = true
+		}
+		for _, row := range got {
+			if !wanted[fmt.Sprint(row)] {
+				t.Errorf("%v: unexpected row %v", c.preds, row)
+			}
+		}
+	}
+
+	t.Run("Explain", func(t *testing.T) {
+		explain, err := db.Explain(core.Gt("id", 10), core.Le("id", 20), core.Eq("dept", 1))
+		if err != nil {
+			t.Fatalf("Explain failed: %v", err)
+		}
+		if !strings.HasPrefix(explain, "PKEY RANGE SCAN on ID (10, 20]") || !strings.Contains(explain, "FILTER DEPT = 1") {
+			t.Errorf("Unexpected explain output:\n%s", explain)
+		}
+	})
+
+	t.Run("InvalidPredicates", func(t *testing.T) {
+		if _, err := db.Query(core.Eq("age", 1)); err == nil {
+			t.Error("Expected error for unknown column")
+		}
+		if _, err := db.Query(core.Eq("id", []byte("1"))); err == nil {
+			t.Error("Expected error for wrong value type")
+		}
+	})
+
+	t.Run("RangeQueryInOrder", func(t *testing.T) {
+		rows, err := db.RangeQuery(0, -10, 10)
+		if err != nil || len(rows) != 21 {
+			t.Fatalf("Expected 21 rows, got %d: %v", len(rows), err)
+		}
+		for i, row := range rows {
+			if row[0] != i-10 {
+				t.Fatalf("Rows out of order at %d: %v", i, row)
+			}
+		}
+	})
+}
+
+// * filterRows returns the rows of all matching every predicate, ordered by primary key
+func filterRows(t *testing.T, db *core.DB, all [][]any, preds []core.Predicate) [][]any {
+	tDef := db.TableDef()
+	var ret [][]any
+	for _, row := range all {
+		match := true
+		for _, p := range preds {
+			colIndex, err := tDef.ColIndex(p.Col)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Matches(row[colIndex]) {
+				match = false
+			}
+		}
+		if match {
+			ret = append(ret, row)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,133 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReadOnly tests that a table opened read only serves reads, refuses every change and leaves its files untouched
+func TestReadOnly(t *testing.T) {
+	dir := tempDataDir(t)
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "NAME", "QTY"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64},
+		UniqueCols: []int{1},
+	}
+	db, err := core.DbInit("readonly_items", tDef)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	name := func(id int) []byte { return []byte(fmt.Sprintf("name-%d", id)) }
+	for i := 0; i < 100; i++ {
+		if err := db.Insert(i, name(i), i); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	db.Close()
+	files := []string{"READONLY_ITEMSrec.db", "READONLY_ITEMSNAME.db"}
+	saved := map[string][]byte{}
+	for _, file := range files {
+		if saved[file], err = os.ReadFile(filepath.Join(dir, file)); err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+	}
+
+	opts := &core.Options{ReadOnly: true}
+	reader, err := core.OpenDBWithOptions("readonly_items", opts)
+	if err != nil {
+		t.Fatalf("OpenDB read only failed: %v", err)
+	}
+	other, err := core.OpenDBWithOptions("readonly_items", opts)
+	if err != nil {
+		t.Fatalf("second read only OpenDB failed: %v", err)
+	}
+	if _, err := core.OpenDB("readonly_items"); !errors.Is(err, core.ErrLocked) {
+		t.Errorf("expected ErrLocked opening for writing, got %v", err)
+	}
+
+	t.Run("Reads", func(t *testing.T) {
+		if rows, err := reader.Query(core.Ge("id", 90)); err != nil || len(rows) != 10 {
+			t.Errorf("expected 10 rows, got %d %v", len(rows), err)
+		}
+		if row, err := other.PointQueryUniqueCol(1, name(42)); err != nil || row[0] != 42 {
+			t.Errorf("PointQueryUniqueCol failed: %v %v", row, err)
+		}
+		if count, err := reader.Count(); err != nil || count != 100 {
+			t.Errorf("expected a count of 100, got %d %v", count, err)
+		}
+		if _, err := reader.Verify(); err != nil {
+			t.Errorf("Verify failed: %v", err)
+		}
+		if err := reader.Backup(&bytes.Buffer{}); err != nil {
+			t.Errorf("Backup failed: %v", err)
+		}
+	})
+
+	t.Run("Writes", func(t *testing.T) {
+		for call, fn := range map[string]func() error{
+			"Insert":      func() error { return reader.Insert(500, name(500), 1) },
+			"InsertRow":   func() error { return reader.InsertRow(map[string]any{"ID": 501, "NAME": name(501), "QTY": 1}) },
+			"InsertBatch": func() error { return reader.InsertBatch([][]any{{502, name(502), 1}}) },
+			"BulkLoad":    func() error { _, err := reader.BulkLoad(nil, nil); return err },
+			"Update":      func() error { return reader.Update(1, map[string]any{"QTY": 7}) },
+			"UpdatePoint": func() error { return reader.UpdatePoint(2, 1, 7) },
+			"Delete":      func() error { return reader.Delete(0, 1) },
+			"DeleteRange": func() error { _, err := reader.DeleteRange("id", 0, 10); return err },
+			"DeleteWhere": func() error { _, err := reader.DeleteWhere(core.Lt("id", 10)); return err },
+			"Truncate":    func() error { return reader.Truncate() },
+			"Compact":     func() error { _, err := reader.Compact(); return err },
+			"Drop":        func() error { return reader.Drop() },
+		} {
+			if err := fn(); !errors.Is(err, core.ErrReadOnly) {
+				t.Errorf("%s: expected ErrReadOnly, got %v", call, err)
+			}
+		}
+		if count, err := reader.Count(); err != nil || count != 100 {
+			t.Errorf("expected a count of 100, got %d %v", count, err)
+		}
+	})
+
+	reader.Close()
+	other.Close()
+	for _, file := range files {
+		if data, _ := os.ReadFile(filepath.Join(dir, file)); !bytes.Equal(data, saved[file]) {
+			t.Errorf("%s changed", file)
+		}
+	}
+
+	t.Run("Missing", func(t *testing.T) {
+		if _, err := core.DbInitWithOptions("readonly_missing", tDef, opts); err == nil {
+			t.Errorf("expected opening a missing table read only to fail")
+		}
+		if exists, _ := core.TableExists("readonly_missing"); exists {
+			t.Errorf("a read only open created a table")
+		}
+	})
+
+	t.Run("CLI", func(t *testing.T) {
+		out, exitCode := runCLI(t, "", "-read-only", "query", "readonly_items", "id", "<", "3")
+		if exitCode != 0 || !strings.Contains(out, "(3 rows)") {
+			t.Errorf("Unexpected query output: %s", out)
+		}
+		for _, args := range [][]string{
+			{"-read-only", "insert", "readonly_items", "id=600", "name=x", "qty=1"},
+			{"-read-only", "delete", "readonly_items", "id", "<", "3"},
+			{"-read-only", "create-table", "readonly_new", "id:int:pk"},
+		} {
+			if out, exitCode := runCLI(t, "", args...); exitCode == 0 || !strings.Contains(out, "read only") {
+				t.Errorf("%v: expected a read only error: %s", args, out)
+			}
+		}
+		for _, file := range files {
+			if data, _ := os.ReadFile(filepath.Join(dir, file)); !bytes.Equal(data, saved[file]) {
+				t.Errorf("%s changed", file)
+			}
+		}
+	})
+}
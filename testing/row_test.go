@@ -0,0 +1,127 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"testing"
+)
+
+// TestNamedColumnRows tests the Row type and the column name based DB methods
+func TestNamedColumnRows(t *testing.T) {
+	// * The primary key is not the first column, so positions get swapped when the table is created
+	tDef := &core.TableDef{
+		Cols:       []string{"Name", "ID", "Cabin"},
+		Types:      []uint16{core.TYPE_BYTE, core.TYPE_INT64, core.TYPE_INT64},
+		PKeyIndex:  1,
+		UniqueCols: []int{2},
+	}
+	db, err := core.DbInitWithOptions("named_rows", tDef, memoryOptions())
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer db.Close()
+
+	people := []struct {
+		id    int
+		name  string
+		cabin int
+	}{
+		{1, "Alice", 101},
+		{2, "Bob", 102},
+		{3, "Charlie", 103},
+	}
+	for _, p := range people {
+		err := db.InsertRow(map[string]any{"id": p.id, "name": []byte(p.name), "cabin": p.cabin})
+		if err != nil {
+			t.Fatalf("InsertRow failed: %v", err)
+		}
+	}
+
+	t.Run("GetRow", func(t *testing.T) {
+		row, err := db.GetRow(2)
+		if err != nil {
+			t.Fatalf("GetRow failed: %v", err)
+		}
+		if row.Int("ID") != 2 || row.String("name") != "Bob" || row.Int("Cabin") != 102 {
+			t.Errorf("Unexpected row: %v", row.Values())
+		}
+		if string(row.Bytes("NAME")) != "Bob" {
+			t.Errorf("Expected Bob, got %s", row.Bytes("NAME"))
+		}
+		if row.Get("MISSING") != nil {
+			t.Error("Expected nil for unknown column")
+		}
+	})
+
+	t.Run("SelectWhere", func(t *testing.T) {
+		rows, err := db.SelectWhere("cabin", 103)
+		if err != nil {
+			t.Fatalf("SelectWhere failed: %v", err)
+		}
+		if len(rows) != 1 || rows[0].String("name") != "Charlie" {
+			t.Errorf("Unexpected rows: %v", rows)
+		}
+	})
+
+	t.Run("SelectRange", func(t *testing.T) {
+		rows, err := db.SelectRange("cabin", 101, 102)
+		if err != nil {
+			t.Fatalf("SelectRange failed: %v", err)
+		}
+		if len(rows) != 2 {
+			t.Errorf("Expected 2 rows, got %d", len(rows))
+		}
+	})
+
+	t.Run("InsertRowMissingColumn", func(t *testing.T) {
+		err := db.InsertRow(map[string]any{"id": 4, "name": []byte("Dan")})
+		if err == nil {
+			t.Error("Expected error for missing column, got nil")
+		}
+	})
+
+	t.Run("DeleteBy", func(t *testing.T) {
+		if err := db.DeleteBy("cabin", 101); err != nil {
+			t.Fatalf("DeleteBy failed: %v", err)
+		}
+		rows, err := db.SelectAll()
+		if err != nil {
+			t.Fatalf("SelectAll failed: %v", err)
+		}
+		if len(rows) != 2 {
+			t.Errorf("Expected 2 rows, got %d", len(rows))
+		}
+	})
+}
+
+// TestPrimaryKeyUniqueColsRemap tests that the unique columns follow the primary key moving to index 0, the primary
+// key itself and columns listed twice left out
+func TestPrimaryKeyUniqueColsRemap(t *testing.T) {
+	tDef := &core.TableDef{
+		Cols:       []string{"CODE", "NAME", "ID"},
+		Types:      []uint16{core.TYPE_BYTE, core.TYPE_BYTE, core.TYPE_INT64},
+		PKeyIndex:  2,
+		UniqueCols: []int{0, 2, 2, 0},
+	}
+	db, err := core.DbInitWithOptions("remapped_rows", tDef, &core.Options{Storage: core.NewMemoryStorage()})
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer db.Close()
+
+	if got := db.TableDef().UniqueCols; len(got) != 1 || got[0] != 2 {
+		t.Fatalf("Expected the unique columns [2], got %v", got)
+	}
+	if err := db.InsertRow(map[string]any{"id": 1, "code": []byte("A"), "name": []byte("first")}); err != nil {
+		t.Fatalf("InsertRow failed: %v", err)
+	}
+	if err := db.InsertRow(map[string]any{"id": 2, "code": []byte("A"), "name": []byte("second")}); err == nil {
+		t.Error("Expected error for a duplicate CODE, got nil")
+	}
+	row, err := db.PointQueryUniqueCol(2, []byte("A"))
+	if err != nil || row[0] != 1 {
+		t.Errorf("Expected row 1 for CODE A, got %v %v", row, err)
+	}
+	if _, err := db.Verify(); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+}
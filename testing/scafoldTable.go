@@ -16,7 +16,7 @@ type table struct {
 func init() {
 	// fmt.println("Check")
 	var table table
-	data, err := os.ReadFile("./testing/faculty.json")
+	data, err := os.ReadFile("faculty.json")
 	if err != nil {
 		// fmt.println(err)
 		os.Exit(1)
@@ -42,11 +42,11 @@ func init() {
 			}
 			// fmt.printf("%d: %T\n", i, row[i])
 		}
-		err := db.Insert(row...)
-		if err != nil {
-			// panic(err)
-			// fmt.println(err)
-		}
+	}
+	// * Only loads on the first run, BulkLoad refuses tables that already have rows
+	_, err = db.BulkLoad(core.SliceIterator(table.Records), nil)
+	if err != nil {
+		// fmt.println(err)
 	}
 	db.Close()
 	// fmt.println("-- End Test --")
@@ -0,0 +1,216 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestSelect tests projection, ordering, limit/offset and keyset pagination of query results
+func TestSelect(t *testing.T) {
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "NAME", "CABIN", "DEPT"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64, core.TYPE_INT64},
+		UniqueCols: []int{2},
+	}
+	db, err := core.DbInitWithOptions("select", tDef, memoryOptions())
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer db.Close()
+
+	var all [][]any
+	for i := -50; i < 150; i++ {
+		row := []any{i, []byte(fmt.Sprintf("Name_%03d", (i*37+1000)%200)), 5000 - i*3, (i + 50) % 7}
+		all = append(all, row)
+		if err := db.Insert(row...); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	// * sorted returns the rows of all sorted by a column, ties broken by primary key
+	sorted := func(colIndex int, desc bool) [][]any {
+		rows := append([][]any(nil), all...)
+		sort.SliceStable(rows, func(i, j int) bool {
+			a, b := fmt.Sprintf("%s", rows[i][colIndex]), fmt.Sprintf("%s", rows[j][colIndex])
+			if x, ok := rows[i][colIndex].(int); ok {
+				a, b = fmt.Sprintf("%08d", x+1e6), fmt.Sprintf("%08d", rows[j][colIndex].(int)+1e6)
+			}
+			if a == b {
+				a, b = fmt.Sprintf("%08d", rows[i][0].(int)+1e6), fmt.Sprintf("%08d", rows[j][0].(int)+1e6)
+			}
+			if desc {
+				return a > b
+			}
+			return a < b
+		})
+		return rows
+	}
+	sameRows := func(t *testing.T, got, want [][]any) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("expected %d rows, got %d", len(want), len(got))
+		}
+		for i := range want {
+			if fmt.Sprint(got[i]) != fmt.Sprint(want[i]) {
+				t.Fatalf("row %d: expected %v, got %v", i, want[i], got[i])
+			}
+		}
+	}
+
+	t.Run("Ordering", func(t *testing.T) {
+		cases := []struct {
+			orderBy  string
+			colIndex int
+			sorted   bool
+		}{
+			{"", 0, false},
+			{"id", 0, false},
+			{"cabin", 2, false},
+			{"dept", 3, true},
+			{"name", 1, true},
+		}
+		for _, c := range cases {
+			for _, desc := range []bool{false, true} {
+				rs, err := db.Select(&core.QueryOptions{OrderBy: c.orderBy, Desc: desc})
+				if err != nil {
+					t.Fatalf("Select failed: %v", err)
+				}
+				if rs.Sorted != c.sorted {
+					t.Errorf("order by %q: expected Sorted %v", c.orderBy, c.sorted)
+				}
+				sameRows(t, rs.Rows, sorted(c.colIndex, desc))
+			}
+		}
+	})
+
+	t.Run("Projection", func(t *testing.T) {
+		rs, err := db.Select(&core.QueryOptions{Columns: []string{"dept", "ID"}, Limit: 3}, core.Ge("id", 10))
+		if err != nil {
+			t.Fatalf("Select failed: %v", err)
+		}
+		if strings.Join(rs.Columns, ",") != "DEPT,ID" {
+			t.Fatalf("unexpected columns %v", rs.Columns)
+		}
+		sameRows(t, rs.Rows, [][]any{{4, 10}, {5, 11}, {6, 12}})
+		if rs.Row(1).Get("ID") != 11 {
+			t.Errorf("Row(1) doesn't address by column name")
+		}
+		if _, err := db.Select(&core.QueryOptions{Columns: []string{"SALARY"}}); err == nil {
+			t.Errorf("expected an error for an unknown column")
+		}
+	})
+
+	t.Run("LimitOffset", func(t *testing.T) {
+		want := sorted(3, true)
+		rs, err := db.Select(&core.QueryOptions{OrderBy: "dept", Desc: true, Limit: 15, Offset: 20})
+		if err != nil {
+			t.Fatalf("Select failed: %v", err)
+		}
+		sameRows(t, rs.Rows, want[20:35])
+		if rs.NextToken == "" {
+			t.Errorf("expected a next token")
+		}
+
+		rs, err = db.Select(&core.QueryOptions{Offset: 190, Limit: 20})
+		if err != nil {
+			t.Fatalf("Select failed: %v", err)
+		}
+		sameRows(t, rs.Rows, all[190:])
+		if rs.NextToken != "" {
+			t.Errorf("unexpected next token on the last page")
+		}
+
+		if _, err := db.Select(&core.QueryOptions{Limit: -1}); err == nil {
+			t.Errorf("expected an error for a negative limit")
+		}
+	})
+
+	// * Pages stay correct when rows are inserted between them: rows sorting before the current position are skipped
+	// * and rows after it show up
+	t.Run("KeysetPagination", func(t *testing.T) {
+		for _, orderBy := range []string{"ID", "CABIN", "DEPT"} {
+			for _, desc := range []bool{false, true} {
+				opts := &core.QueryOptions{OrderBy: orderBy, Desc: desc, Limit: 17, Filter: func(row []any) bool {
+					return row[0].(int) < 1000
+				}}
+				seen := map[int]bool{}
+				var got [][]any
+				for page := 0; ; page++ {
+					rs, err := db.Select(opts)
+					if err != nil {
+						t.Fatalf("Select failed: %v", err)
+					}
+					for _, row := range rs.Rows {
+						if seen[row[0].(int)] {
+							t.Fatalf("order by %s: row %v returned twice", orderBy, row)
+						}
+						seen[row[0].(int)] = true
+					}
+					got = append(got, rs.Rows...)
+					if rs.NextToken == "" {
+						break
+					}
+					opts.After = rs.NextToken
+				}
+				colIndex, _ := tDef.ColIndex(orderBy)
+				sameRows(t, got, sorted(colIndex, desc))
+			}
+		}
+
+		rs, err := db.Select(&core.QueryOptions{Limit: 50})
+		if err != nil {
+			t.Fatalf("Select failed: %v", err)
+		}
+		// * A row after the page and one before it
+		for _, id := range []int{1000, -1000} {
+			if err := db.Insert(id, []byte("Late"), id*10, 0); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		rs, err = db.Select(&core.QueryOptions{Limit: 500, After: rs.NextToken})
+		if err != nil {
+			t.Fatalf("Select failed: %v", err)
+		}
+		if len(rs.Rows) != 151 || rs.Rows[0][0] != 0 || rs.Rows[150][0] != 1000 {
+			t.Errorf("unexpected rows after inserting between pages: %d rows", len(rs.Rows))
+		}
+	})
+
+	t.Run("InvalidTokens", func(t *testing.T) {
+		rs, err := db.Select(&core.QueryOptions{OrderBy: "cabin", Limit: 5})
+		if err != nil {
+			t.Fatalf("Select failed: %v", err)
+		}
+		if _, err := db.Select(&core.QueryOptions{OrderBy: "cabin", Desc: true, After: rs.NextToken}); err == nil {
+			t.Errorf("expected an error for a token of a different order")
+		}
+		if _, err := db.Select(&core.QueryOptions{After: rs.NextToken}); err == nil {
+			t.Errorf("expected an error for a token of a different column")
+		}
+		for _, token := range []string{"not a token", "AQ", rs.NextToken[:len(rs.NextToken)-2]} {
+			if _, err := db.Select(&core.QueryOptions{OrderBy: "cabin", After: token}); err == nil {
+				t.Errorf("expected an error for token %q", token)
+			}
+		}
+	})
+
+	t.Run("Explain", func(t *testing.T) {
+		explain, err := db.ExplainSelect(&core.QueryOptions{OrderBy: "cabin", Desc: true, Limit: 10, Offset: 5})
+		if err != nil {
+			t.Fatalf("ExplainSelect failed: %v", err)
+		}
+		if want := "INDEX RANGE SCAN on CABIN (-inf, +inf) DESC (cost 100)\nLIMIT 10\nOFFSET 5"; explain != want {
+			t.Errorf("expected:\n%s\ngot:\n%s", want, explain)
+		}
+		explain, err = db.ExplainSelect(&core.QueryOptions{OrderBy: "dept"}, core.Lt("id", 0))
+		if err != nil {
+			t.Fatalf("ExplainSelect failed: %v", err)
+		}
+		if !strings.HasSuffix(explain, "\nSORT BY DEPT") {
+			t.Errorf("expected a sort step, got:\n%s", explain)
+		}
+	})
+}
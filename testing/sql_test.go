@@ -0,0 +1,286 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"BynxDB/sql"
+	"fmt"
+	"testing"
+)
+
+func execSQL(t *testing.T, e *sql.Engine, query string) *sql.Result {
+	t.Helper()
+	res, err := e.Exec(query)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return res
+}
+
+func resultIDs(res *sql.Result) []int {
+	var ids []int
+	for i := range res.Rows {
+		ids = append(ids, res.Row(i).Int("ID"))
+	}
+	return ids
+}
+
+func sameIDs(got []int, want ...int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := map[int]bool{}
+	for _, id := range got {
+		seen[id] = true
+	}
+	for _, id := range want {
+		if !seen[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestSQL tests the SQL statements end to end on one table
+func TestSQL(t *testing.T) {
+	e := sql.NewEngineWithOptions(memoryOptions())
+	defer e.Close()
+
+	execSQL(t, e, `CREATE TABLE sql_faculty (
+		id INT PRIMARY KEY,
+		name VARCHAR(40),
+		cabin INT UNIQUE,
+		dept INT
+	)`)
+	if _, err := e.Exec("CREATE TABLE sql_faculty (id INT)"); err == nil {
+		t.Error("Expected error creating an existing table")
+	}
+
+	res := execSQL(t, e, `INSERT INTO sql_faculty VALUES
+		(10, 'Mudit', 1000, 3),
+		(11, 'Unnat', 1001, 3),
+		(12, 'O''Neil', 1002, 4);`)
+	if res.RowsAffected != 3 {
+		t.Errorf("Expected 3 rows inserted, got %d", res.RowsAffected)
+	}
+	execSQL(t, e, "insert into sql_faculty (dept, cabin, name, id) values (5, 1003, 'Zoe', 13)")
+
+	t.Run("SelectTyped", func(t *testing.T) {
+		res := execSQL(t, e, "SELECT name, cabin FROM sql_faculty WHERE id = 12")
+		if len(res.Rows) != 1 {
+			t.Fatalf("Expected 1 row, got %d", len(res.Rows))
+		}
+		if res.Columns[0].Name != "NAME" || res.Columns[0].Type != core.TYPE_BYTE || res.Columns[1].Type != core.TYPE_INT64 {
+			t.Errorf("Unexpected columns: %v", res.Columns)
+		}
+		row := res.Row(0)
+		if row.String("name") != "O'Neil" || row.Int("cabin") != 1002 {
+			t.Errorf("Unexpected row: %v", res.Rows[0])
+		}
+	})
+
+	t.Run("SelectWhere", func(t *testing.T) {
+		cases := []struct {
+			query string
+			want  []int
+		}{
+			{"SELECT * FROM sql_faculty", []int{10, 11, 12, 13}},
+			{"SELECT * FROM sql_faculty WHERE cabin = 1001", []int{11}},
+			{"SELECT * FROM sql_faculty WHERE dept = 3", []int{10, 11}},
+			{"SELECT * FROM sql_faculty WHERE name = 'Zoe'", []int{13}},
+			{"SELECT * FROM sql_faculty WHERE id = 99", nil},
+			{"SELECT * FROM sql_faculty WHERE cabin BETWEEN 1001 AND 1002", []int{11, 12}},
+			{"SELECT * FROM sql_faculty WHERE id > 10 AND dept < 5", []int{11, 12}},
+			{"SELECT * FROM sql_faculty WHERE dept = 5 OR name = 'Mudit'", []int{10, 13}},
+			{"SELECT * FROM sql_faculty WHERE (dept = 3 OR dept = 4) AND cabin >= 1001", []int{11, 12}},
+			{"SELECT * FROM sql_faculty WHERE name BETWEEN 'N' AND 'V'", []int{11, 12}},
+			{"SELECT * FROM sql_faculty WHERE dept <> 3", []int{12, 13}},
+		}
+		for _, c := range cases {
+			res := execSQL(t, e, c.query)
+			if got := resultIDs(res); !sameIDs(got, c.want...) {
+				t.Errorf("%s: expected %v, got %v", c.query, c.want, got)
+			}
+		}
+	})
+
+	t.Run("OrderLimit", func(t *testing.T) {
+		cases := []struct {
+			query string
+			want  []int
+		}{
+			{"SELECT id FROM sql_faculty ORDER BY id DESC", []int{13, 12, 11, 10}},
+			{"SELECT id, dept FROM sql_faculty ORDER BY dept DESC LIMIT 2", []int{13, 12}},
+			{"SELECT * FROM sql_faculty WHERE dept < 5 ORDER BY name ASC LIMIT 2 OFFSET 1", []int{12, 11}},
+			{"SELECT * FROM sql_faculty ORDER BY cabin LIMIT 0", nil},
+			{"SELECT * FROM sql_faculty LIMIT 10 OFFSET 3", []int{13}},
+		}
+		for _, c := range cases {
+			res := execSQL(t, e, c.query)
+			got := resultIDs(res)
+			if len(got) != len(c.want) {
+				t.Errorf("%s: expected %v, got %v", c.query, c.want, got)
+				continue
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Errorf("%s: expected %v, got %v", c.query, c.want, got)
+					break
+				}
+			}
+		}
+		res := execSQL(t, e, "SELECT name FROM sql_faculty LIMIT 0")
+		if len(res.Columns) != 1 || res.Columns[0].Name != "NAME" {
+			t.Errorf("Unexpected columns: %v", res.Columns)
+		}
+		res = execSQL(t, e, "EXPLAIN SELECT * FROM sql_faculty ORDER BY dept LIMIT 1")
+		if len(res.Rows) != 3 || res.Row(1).String("plan") != "SORT BY DEPT" || res.Row(2).String("plan") != "LIMIT 1" {
+			t.Errorf("Unexpected plan: %v", res.Rows)
+		}
+	})
+
+	t.Run("Aggregates", func(t *testing.T) {
+		res := execSQL(t, e, "SELECT COUNT(*), sum(cabin), MIN(name), max(id), AVG(dept) FROM sql_faculty")
+		if len(res.Rows) != 1 || fmt.Sprint(res.Rows[0]) != "[4 4006 [77 117 100 105 116] 13 3.75]" {
+			t.Errorf("Unexpected row: %v", res.Rows)
+		}
+		if res.Columns[0].Name != "COUNT(*)" || res.Columns[2].Type != core.TYPE_BYTE || res.Columns[4].Type != sql.TYPE_FLOAT64 {
+			t.Errorf("Unexpected columns: %v", res.Columns)
+		}
+		res = execSQL(t, e, "SELECT COUNT(*), dept FROM sql_faculty WHERE id > 10 GROUP BY dept ORDER BY dept DESC LIMIT 2")
+		if fmt.Sprint(res.Rows) != "[[1 5] [1 4]]" || res.Columns[1].Name != "DEPT" {
+			t.Errorf("Unexpected groups: %v", res.Rows)
+		}
+		res = execSQL(t, e, "SELECT dept FROM sql_faculty WHERE dept = 3 OR dept = 5 GROUP BY dept")
+		if fmt.Sprint(res.Rows) != "[[3] [5]]" {
+			t.Errorf("Unexpected groups: %v", res.Rows)
+		}
+		res = execSQL(t, e, "EXPLAIN SELECT MAX(cabin) FROM sql_faculty WHERE id < 12 GROUP BY dept")
+		if len(res.Rows) != 2 || res.Row(1).String("plan") != "AGGREGATE MAX(CABIN) GROUP BY DEPT" {
+			t.Errorf("Unexpected plan: %v", res.Rows)
+		}
+		for _, query := range []string{
+			"SELECT name, COUNT(*) FROM sql_faculty GROUP BY dept",
+			"SELECT SUM(name) FROM sql_faculty",
+			"SELECT AVG(*) FROM sql_faculty",
+		} {
+			if _, err := e.Exec(query); err == nil {
+				t.Errorf("%s: expected error, got nil", query)
+			}
+		}
+	})
+
+	t.Run("Explain", func(t *testing.T) {
+		res := execSQL(t, e, "EXPLAIN SELECT * FROM sql_faculty WHERE cabin > 1000 AND (dept = 3 OR dept = 4)")
+		if len(res.Rows) != 2 || res.Row(0).String("plan") != "INDEX RANGE SCAN on CABIN (1000, +inf) (cost 60)" ||
+			res.Row(1).String("plan") != "FILTER (DEPT = 3 OR DEPT = 4)" {
+			t.Errorf("Unexpected plan: %v", res.Rows)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		res := execSQL(t, e, "UPDATE sql_faculty SET dept = 7, name = 'Dept7' WHERE dept = 3")
+		if res.RowsAffected != 2 {
+			t.Errorf("Expected 2 rows updated, got %d", res.RowsAffected)
+		}
+		res = execSQL(t, e, "SELECT id FROM sql_faculty WHERE dept = 7 AND name = 'Dept7'")
+		if got := resultIDs(res); !sameIDs(got, 10, 11) {
+			t.Errorf("Expected rows 10 and 11, got %v", got)
+		}
+		if _, err := e.Exec("UPDATE sql_faculty SET cabin = 1000 WHERE id = 13"); err == nil {
+			t.Error("Expected unique conflict, got nil")
+		}
+		// * The second row clashes with the first one, which is rolled back with it
+		if _, err := e.Exec("UPDATE sql_faculty SET cabin = 2000 WHERE dept = 7"); err == nil {
+			t.Error("Expected unique conflict, got nil")
+		}
+		res = execSQL(t, e, "SELECT id FROM sql_faculty WHERE cabin = 2000")
+		if got := resultIDs(res); len(got) != 0 {
+			t.Errorf("Expected no row with the new cabin, got %v", got)
+		}
+		res = execSQL(t, e, "SELECT id FROM sql_faculty WHERE (id = 10 AND cabin = 1000) OR (id = 11 AND cabin = 1001)")
+		if got := resultIDs(res); !sameIDs(got, 10, 11) {
+			t.Errorf("Expected rows 10 and 11 unchanged, got %v", got)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		res := execSQL(t, e, "DELETE FROM sql_faculty WHERE cabin > 1001")
+		if res.RowsAffected != 2 {
+			t.Errorf("Expected 2 rows deleted, got %d", res.RowsAffected)
+		}
+		res = execSQL(t, e, "SELECT * FROM sql_faculty")
+		if got := resultIDs(res); !sameIDs(got, 10, 11) {
+			t.Errorf("Expected rows 10 and 11, got %v", got)
+		}
+		// * The unique value of a deleted row is free again
+		execSQL(t, e, "INSERT INTO sql_faculty VALUES (14, 'New', 1002, 1)")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, query := range []string{
+			"SELECT * FROM no_such_table",
+			"SELECT age FROM sql_faculty",
+			"SELECT * FROM sql_faculty WHERE id = 'ten'",
+			"INSERT INTO sql_faculty VALUES (1, 'Short')",
+			"INSERT INTO sql_faculty VALUES ('x', 'Bad', 1, 1)",
+			"SELECT * FROM sql_faculty WHERE",
+			"SELECT * sql_faculty",
+			"UPDATE sql_faculty SET name = 'x' WHERE id BETWEEN 1",
+			"DROP INDEX sql_faculty",
+			"SELECT * FROM sql_faculty WHERE name = 'open",
+		} {
+			if _, err := e.Exec(query); err == nil {
+				t.Errorf("%s: expected error, got nil", query)
+			}
+		}
+	})
+}
+
+// TestSQLParse tests the statements produced by the parser
+func TestSQLParse(t *testing.T) {
+	stmt, err := sql.Parse("SELECT a, b FROM t WHERE a = 1 OR b BETWEEN 'x' AND 'y' AND a < -2")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	sel, ok := stmt.(*sql.Select)
+	if !ok || sel.Table != "t" || len(sel.Cols) != 2 {
+		t.Fatalf("Unexpected statement: %#v", stmt)
+	}
+	// * AND binds tighter than OR
+	or, ok := sel.Where.(*sql.Or)
+	if !ok {
+		t.Fatalf("Expected OR at the top, got %T", sel.Where)
+	}
+	and, ok := or.Right.(*sql.And)
+	if !ok {
+		t.Fatalf("Expected AND on the right, got %T", or.Right)
+	}
+	if cmp, ok := and.Right.(*sql.Comparison); !ok || cmp.Op != "<" || cmp.Value != -2 {
+		t.Errorf("Unexpected comparison: %#v", and.Right)
+	}
+
+	stmt, err = sql.Parse("CREATE TABLE t (a INT, b TEXT, PRIMARY KEY (b), UNIQUE (a));")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	create := stmt.(*sql.CreateTable)
+	if !create.Cols[1].PrimaryKey || !create.Cols[0].Unique {
+		t.Errorf("Table constraints not applied: %#v", create.Cols)
+	}
+
+	stmt, err = sql.Parse("SELECT * FROM t ORDER BY a DESC LIMIT 5 OFFSET 2")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if sel := stmt.(*sql.Select); sel.OrderBy != "a" || !sel.Desc || sel.Limit != 5 || sel.Offset != 2 {
+		t.Errorf("Unexpected select: %#v", sel)
+	}
+	if _, err := sql.Parse("SELECT * FROM t LIMIT -1"); err == nil {
+		t.Error("Expected error for a negative limit")
+	}
+
+	stmts, err := sql.ParseScript("DELETE FROM t; UPDATE t SET a = 1 WHERE b = 'x';")
+	if err != nil || len(stmts) != 2 {
+		t.Errorf("ParseScript failed: %v %v", stmts, err)
+	}
+}
@@ -0,0 +1,175 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"BynxDB/sql"
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// memoryOptions returns options keeping the tables of a test in a storage of its own, in memory
+func memoryOptions() *core.Options {
+	return &core.Options{Storage: core.NewMemoryStorage()}
+}
+
+// tempDataDir moves the files of the tables on disk to a directory of the test, removed once it is done, for the tests
+// that need real files: the command line, locks, migrations and damaged files
+func tempDataDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv(core.DATA_DIR_ENV, dir)
+	return dir
+}
+
+// TestMemoryStorage tests that tables kept in a MemoryStorage work like tables on disk, foreign keys included,
+// without touching the disk and without seeing the tables of other storages
+func TestMemoryStorage(t *testing.T) {
+	// * The checks that nothing reaches the disk look at a directory of the test
+	tempDataDir(t)
+	mem := core.NewMemoryStorage()
+	opts := &core.Options{Storage: mem}
+	depts, err := core.DbInitWithOptions("storage_depts", &core.TableDef{
+		Cols:  []string{"ID", "NAME"},
+		Types: []uint16{core.TYPE_INT64, core.TYPE_BYTE},
+	}, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { depts.Close() }()
+	tDef := &core.TableDef{
+		Cols:        []string{"ID", "CODE", "DEPT"},
+		Types:       []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64},
+		UniqueCols:  []int{1},
+		ForeignKeys: []core.ForeignKey{{Col: "DEPT", RefTable: "STORAGE_DEPTS", OnDelete: core.FK_CASCADE}},
+	}
+	items, err := core.DbInitWithOptions("storage_items", tDef, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { items.Close() }()
+	code := func(id int) []byte { return []byte(fmt.Sprintf("CODE-%05d", id)) }
+
+	for i := 1; i <= 3; i++ {
+		if err := depts.Insert(i, []byte("Dept")); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		if err := items.Insert(i, code(i), 1+i%3); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if err := items.Insert(5000, code(5000), 9); err == nil {
+		t.Errorf("expected a foreign key violation")
+	}
+	if err := depts.Delete(0, 3); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if count, err := items.Count(); err != nil || count != 667 {
+		t.Errorf("expected the cascade to leave 667 rows, got %d %v", count, err)
+	}
+	for _, name := range []string{"storage_depts", "storage_items"} {
+		if exists, _ := core.TableExists(name); exists {
+			t.Errorf("%s was written to disk", name)
+		}
+	}
+
+	t.Run("Reopen", func(t *testing.T) {
+		items.Close()
+		if items, err = core.OpenDBWithOptions("storage_items", opts); err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		if row, err := items.PointQueryUniqueCol(1, code(501)); err != nil || row[0] != 501 {
+			t.Errorf("PointQueryUniqueCol failed: %v %v", row, err)
+		}
+		if _, err := core.OpenDBWithOptions("storage_items", &core.Options{Storage: core.NewMemoryStorage()}); err == nil {
+			t.Errorf("expected another storage not to hold the table")
+		}
+		if _, err := items.DeleteRange("id", 0, 799); err != nil {
+			t.Fatalf("DeleteRange failed: %v", err)
+		}
+		size := mem.Size()
+		if reclaimed, err := items.Compact(); err != nil || reclaimed <= 0 || mem.Size() != size-reclaimed {
+			t.Errorf("Compact reclaimed %d of %d bytes: %v", reclaimed, size, err)
+		}
+		if _, err := items.Verify(); err != nil {
+			t.Errorf("Verify failed: %v", err)
+		}
+		if err := items.Truncate(); err != nil {
+			t.Fatalf("Truncate failed: %v", err)
+		}
+		if count, err := items.Count(); err != nil || count != 0 {
+			t.Errorf("expected an empty table, got %d %v", count, err)
+		}
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		if err := items.Drop(); err != nil {
+			t.Fatalf("Drop failed: %v", err)
+		}
+		if _, err := core.OpenDBWithOptions("storage_items", opts); err == nil {
+			t.Errorf("expected the dropped table to be gone")
+		}
+		if items, err = core.DbInitWithOptions("storage_items", &core.TableDef{
+			Cols:  []string{"ID"},
+			Types: []uint16{core.TYPE_INT64},
+		}, opts); err != nil {
+			t.Fatalf("DbInit failed: %v", err)
+		}
+	})
+
+	t.Run("SQL", func(t *testing.T) {
+		e := sql.NewEngineWithOptions(&core.Options{Storage: core.NewMemoryStorage()})
+		defer e.Close()
+		execSQL(t, e, "CREATE TABLE storage_depts (id INT PRIMARY KEY, name TEXT)")
+		execSQL(t, e, "INSERT INTO storage_depts VALUES (1, 'Physics'), (2, 'Chemistry')")
+		res := execSQL(t, e, "SELECT * FROM storage_depts WHERE id = 2")
+		if len(res.Rows) != 1 || string(res.Rows[0][1].([]byte)) != "Chemistry" {
+			t.Errorf("unexpected rows %v", res.Rows)
+		}
+		if rows, err := depts.Query(); err != nil || len(rows) != 2 {
+			t.Errorf("the engine's storage changed another storage: %d %v", len(rows), err)
+		}
+		if _, err := e.Exec("SELECT * FROM storage_missing"); err == nil || errors.Is(err, core.ErrNotFound) {
+			t.Errorf("expected an error for a missing table, got %v", err)
+		}
+		if _, err := e.Exec("CREATE TABLE storage_depts (id INT PRIMARY KEY)"); err == nil {
+			t.Errorf("expected an error creating a table the engine's storage holds")
+		}
+	})
+
+	t.Run("TablesAndRestore", func(t *testing.T) {
+		if tables, err := core.TablesWithOptions(opts); err != nil || !slices.Equal(tables, []string{"STORAGE_DEPTS", "STORAGE_ITEMS"}) {
+			t.Errorf("unexpected tables %v %v", tables, err)
+		}
+		if exists, err := core.TableExistsWithOptions("storage_depts", opts); err != nil || !exists {
+			t.Errorf("expected the table to exist in its storage, got %v %v", exists, err)
+		}
+		var archive bytes.Buffer
+		if err := depts.Backup(&archive); err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		dir, err := core.DataDir()
+		if err != nil {
+			t.Fatalf("DataDir failed: %v", err)
+		}
+		other := &core.Options{Storage: core.NewMemoryStorage()}
+		if name, err := core.RestoreWithOptions(&archive, dir, other); err != nil || name != "STORAGE_DEPTS" {
+			t.Fatalf("Restore failed: %s %v", name, err)
+		}
+		if exists, _ := core.TableExists("storage_depts"); exists {
+			t.Errorf("the restored table was written to disk")
+		}
+		restored, err := core.OpenDBWithOptions("storage_depts", other)
+		if err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		defer restored.Close()
+		if rows, err := restored.Query(); err != nil || len(rows) != 2 {
+			t.Errorf("expected the 2 rows backed up, got %d %v", len(rows), err)
+		}
+	})
+}
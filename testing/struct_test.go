@@ -0,0 +1,121 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"math"
+	"strings"
+	"testing"
+)
+
+type faculty struct {
+	Name       string `bynx:"name"`
+	ID         int    `bynx:"id,pk"`
+	Cabin      int64  `bynx:"cabin,unique"`
+	Department int    `bynx:"department_id"`
+	Notes      string `bynx:"-"`
+}
+
+// TestStructMapping tests inserting and scanning Go structs
+func TestStructMapping(t *testing.T) {
+	tDef, err := core.TableDefFor[faculty]()
+	if err != nil {
+		t.Fatalf("TableDefFor failed: %v", err)
+	}
+	if tDef.PKeyIndex != 1 || len(tDef.UniqueCols) != 1 || tDef.UniqueCols[0] != 2 {
+		t.Fatalf("Unexpected TableDef: %+v", tDef)
+	}
+	db, err := core.DbInitWithOptions("struct_faculty", tDef, memoryOptions())
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer db.Close()
+
+	members := []faculty{
+		{Name: "Alice", ID: 1, Cabin: 101, Department: 3},
+		{Name: "Bob", ID: 2, Cabin: 102, Department: 3},
+		{Name: "Charlie", ID: 3, Cabin: 103, Department: 1, Notes: "not stored"},
+	}
+
+	t.Run("InsertStruct", func(t *testing.T) {
+		for i := range members {
+			if err := db.InsertStruct(&members[i]); err != nil {
+				t.Fatalf("InsertStruct failed: %v", err)
+			}
+		}
+		if err := db.InsertStruct(members[0]); err == nil {
+			t.Error("Expected duplicate key error, got nil")
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		var f faculty
+		if err := db.Get(3, &f); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		want := members[2]
+		want.Notes = ""
+		if f != want {
+			t.Errorf("Expected %+v, got %+v", want, f)
+		}
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		rows, err := db.SelectWhere("department_id", 3)
+		if err != nil {
+			t.Fatalf("SelectWhere failed: %v", err)
+		}
+		got, err := core.Scan[faculty](rows)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("Expected 2 rows, got %d", len(got))
+		}
+		for _, f := range got {
+			if f.Department != 3 || (f.Name != "Alice" && f.Name != "Bob") {
+				t.Errorf("Unexpected struct: %+v", f)
+			}
+		}
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		if err := db.InsertRow(map[string]any{"name": []byte("Dan"), "id": 4, "cabin": 300, "department_id": -1}); err != nil {
+			t.Fatalf("InsertRow failed: %v", err)
+		}
+		var small struct {
+			ID    int   `bynx:"id,pk"`
+			Cabin uint8 `bynx:"cabin"`
+		}
+		if err := db.Get(4, &small); err == nil || !strings.Contains(err.Error(), "CABIN") {
+			t.Errorf("Expected an error naming cabin for 300 in a uint8, got %v", err)
+		}
+		var unsigned struct {
+			ID         int  `bynx:"id,pk"`
+			Department uint `bynx:"department_id"`
+		}
+		if err := db.Get(4, &unsigned); err == nil || !strings.Contains(err.Error(), "DEPARTMENT_ID") {
+			t.Errorf("Expected an error naming department_id for -1 in a uint, got %v", err)
+		}
+		type huge struct {
+			Name  string `bynx:"name"`
+			ID    int    `bynx:"id,pk"`
+			Cabin uint64 `bynx:"cabin"`
+		}
+		if err := db.InsertStruct(huge{Name: "Eve", ID: 5, Cabin: math.MaxUint64}); err == nil {
+			t.Error("Expected an error for a uint64 above MaxInt64, got nil")
+		}
+		if row, _ := db.PKeyQuery(5); row != nil {
+			t.Errorf("Expected no row after the refused insert, got %v", row)
+		}
+	})
+
+	t.Run("UnsupportedField", func(t *testing.T) {
+		type bad struct {
+			ID    int
+			Ratio float64
+		}
+		if _, err := core.TableDefOf(bad{}); err == nil {
+			t.Error("Expected error for float field, got nil")
+		}
+	})
+}
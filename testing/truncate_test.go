@@ -0,0 +1,180 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"BynxDB/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestTruncateDrop tests that Truncate empties a table and shrinks its files, and that Drop removes the files and
+// the references to the table
+func TestTruncateDrop(t *testing.T) {
+	mem := core.NewMemoryStorage()
+	opts := &core.Options{Storage: mem}
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "CODE", "QTY"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64},
+		UniqueCols: []int{1},
+	}
+	db, err := core.DbInitWithOptions("truncate_items", tDef, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { db.Close() }()
+	dir, err := core.DataDir()
+	if err != nil {
+		t.Fatalf("DataDir failed: %v", err)
+	}
+	file := func(name string) string { return filepath.Join(dir, name+".db") }
+	size := func(name string) int64 {
+		t.Helper()
+		f, err := mem.Open(file(name), true)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		defer f.Close()
+		size, err := f.Size()
+		if err != nil {
+			t.Fatalf("Size failed: %v", err)
+		}
+		return size
+	}
+	fill := func(from, to int) {
+		t.Helper()
+		var rows [][]any
+		for i := from; i < to; i++ {
+			rows = append(rows, []any{i, []byte(fmt.Sprintf("CODE-%05d", i)), i * 2})
+		}
+		if err := db.InsertBatch(rows); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+	}
+
+	t.Run("Truncate", func(t *testing.T) {
+		fill(0, 3000)
+		emptySize := int64(4 * 4096)
+		if size("TRUNCATE_ITEMSrec") <= emptySize || size("TRUNCATE_ITEMSCODE") <= emptySize {
+			t.Fatalf("expected the files to grow, got %d and %d", size("TRUNCATE_ITEMSrec"), size("TRUNCATE_ITEMSCODE"))
+		}
+		if err := db.Truncate(); err != nil {
+			t.Fatalf("Truncate failed: %v", err)
+		}
+		if size("TRUNCATE_ITEMSrec") > emptySize || size("TRUNCATE_ITEMSCODE") > emptySize {
+			t.Errorf("expected the files to shrink, got %d and %d", size("TRUNCATE_ITEMSrec"), size("TRUNCATE_ITEMSCODE"))
+		}
+		if count, err := db.Count(); err != nil || count != 0 {
+			t.Errorf("expected no rows, got %d %v", count, err)
+		}
+		if _, err := db.PointQueryUniqueCol(1, []byte("CODE-00010")); !errors.Is(err, core.ErrNotFound) {
+			t.Errorf("expected the index to be empty, got %v", err)
+		}
+
+		// * The table is usable after truncating and after reopening
+		fill(5000, 5100)
+		db.Close()
+		if db, err = core.OpenDBWithOptions("truncate_items", opts); err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		rows, err := db.Query()
+		if err != nil || len(rows) != 100 || rows[0][0] != 5000 {
+			t.Errorf("unexpected rows after reopening: %d %v", len(rows), err)
+		}
+		if row, err := db.PointQueryUniqueCol(1, []byte("CODE-05050")); err != nil || row[0] != 5050 {
+			t.Errorf("unexpected index lookup %v %v", row, err)
+		}
+	})
+
+	t.Run("References", func(t *testing.T) {
+		orders, err := core.DbInitWithOptions("truncate_orders", &core.TableDef{
+			Cols:        []string{"ID", "ITEM"},
+			Types:       []uint16{core.TYPE_INT64, core.TYPE_INT64},
+			ForeignKeys: []core.ForeignKey{{Col: "ITEM", RefTable: "TRUNCATE_ITEMS", OnDelete: core.FK_CASCADE}},
+		}, opts)
+		if err != nil {
+			t.Fatalf("DbInit failed: %v", err)
+		}
+		if err := orders.Insert(1, 5000); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if err := db.Truncate(); err == nil {
+			t.Errorf("expected an error truncating a referenced table")
+		}
+		if err := orders.Truncate(); err != nil {
+			t.Fatalf("Truncate failed: %v", err)
+		}
+		// * Outside SET NULL a zero key references the row with primary key 0
+		if err := db.Insert(0, []byte("CODE-00000"), 0); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if err := orders.Insert(2, 0); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if err := db.Truncate(); err == nil {
+			t.Errorf("expected an error truncating a table referenced through primary key 0")
+		}
+		if err := orders.Truncate(); err != nil {
+			t.Fatalf("Truncate failed: %v", err)
+		}
+		if err := db.Drop(); err == nil {
+			t.Errorf("expected an error dropping a referenced table")
+		}
+		if err := db.Truncate(); err != nil {
+			t.Errorf("Truncate failed: %v", err)
+		}
+
+		if err := orders.Drop(); err != nil {
+			t.Fatalf("Drop failed: %v", err)
+		}
+		if exists, _ := core.TableExistsWithOptions("truncate_orders", opts); exists {
+			t.Errorf("the dropped table still exists")
+		}
+		if refs := db.TableDef().ReferencedBy; len(refs) != 0 {
+			t.Errorf("expected the reference to be removed, got %v", refs)
+		}
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		if err := db.Drop(); err != nil {
+			t.Fatalf("Drop failed: %v", err)
+		}
+		for _, name := range []string{"TRUNCATE_ITEMSrec", "TRUNCATE_ITEMSCODE"} {
+			if exists, err := mem.Exists(file(name)); exists || err != nil {
+				t.Errorf("%s: expected the file to be removed, got %v %v", name, exists, err)
+			}
+		}
+		if tables, _ := core.TablesWithOptions(opts); slices.Contains(tables, "TRUNCATE_ITEMS") {
+			t.Errorf("the dropped table is still listed: %v", tables)
+		}
+		// * The name can be used again
+		if db, err = core.DbInitWithOptions("truncate_items", tDef, opts); err != nil {
+			t.Fatalf("DbInit failed: %v", err)
+		}
+		if count, err := db.Count(); err != nil || count != 0 {
+			t.Errorf("expected a new empty table, got %d %v", count, err)
+		}
+	})
+}
+
+// TestSQLTruncateDrop tests TRUNCATE TABLE and DROP TABLE
+func TestSQLTruncateDrop(t *testing.T) {
+	e := sql.NewEngineWithOptions(memoryOptions())
+	defer e.Close()
+	execSQL(t, e, "CREATE TABLE sqltruncate_t (id INT PRIMARY KEY, name TEXT)")
+	execSQL(t, e, "INSERT INTO sqltruncate_t VALUES (1, 'a'), (2, 'b')")
+	execSQL(t, e, "TRUNCATE TABLE sqltruncate_t")
+	if res := execSQL(t, e, "SELECT * FROM sqltruncate_t"); len(res.Rows) != 0 {
+		t.Errorf("expected no rows, got %v", res.Rows)
+	}
+	execSQL(t, e, "DROP TABLE sqltruncate_t")
+	if _, err := e.Exec("SELECT * FROM sqltruncate_t"); err == nil {
+		t.Error("Expected an error selecting from a dropped table")
+	}
+	if _, err := e.Exec("DROP TABLE sqltruncate_t"); err == nil {
+		t.Error("Expected an error dropping a missing table")
+	}
+	execSQL(t, e, "CREATE TABLE sqltruncate_t (id INT PRIMARY KEY)")
+}
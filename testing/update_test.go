@@ -0,0 +1,118 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"bytes"
+	"testing"
+)
+
+// TestUpdateByPrimaryKey tests DB.Update with changes to plain, unique and primary key columns
+func TestUpdateByPrimaryKey(t *testing.T) {
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "NAME", "EMAIL"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_BYTE},
+		UniqueCols: []int{2},
+	}
+	db, err := core.DbInitWithOptions("update_by_pk", tDef, memoryOptions())
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer db.Close()
+
+	for i, name := range []string{"Alice", "Bob", "Charlie"} {
+		err := db.Insert(i+1, []byte(name), []byte(name+"@example.com"))
+		if err != nil {
+			t.Fatalf("Setup failed: %v", err)
+		}
+	}
+
+	t.Run("UpdateOtherColumn", func(t *testing.T) {
+		err := db.Update(1, map[string]any{"name": []byte("Alicia")})
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		row, err := db.PKeyQuery(1)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if !bytes.Equal(row[1].([]byte), []byte("Alicia")) {
+			t.Errorf("Expected Alicia, got %s", string(row[1].([]byte)))
+		}
+	})
+
+	t.Run("UpdateUniqueColumn", func(t *testing.T) {
+		err := db.Update(2, map[string]any{"EMAIL": []byte("bob@new.com")})
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		rows, err := db.PointQuery(2, []byte("bob@new.com"))
+		if err != nil || len(rows) != 1 || rows[0][0] != 2 {
+			t.Errorf("New unique value not indexed: %v %v", rows, err)
+		}
+		if _, err := db.PointQuery(2, []byte("Bob@example.com")); err == nil {
+			t.Error("Old unique value still indexed")
+		}
+		// * The old value is free again
+		if err := db.Insert(4, []byte("Dan"), []byte("Bob@example.com")); err != nil {
+			t.Errorf("Insert with released unique value failed: %v", err)
+		}
+	})
+
+	t.Run("UpdateUniqueColumnConflict", func(t *testing.T) {
+		err := db.Update(3, map[string]any{"EMAIL": []byte("bob@new.com")})
+		if err == nil {
+			t.Error("Expected unique conflict, got nil")
+		}
+		row, _ := db.PKeyQuery(3)
+		if row == nil || !bytes.Equal(row[2].([]byte), []byte("Charlie@example.com")) {
+			t.Errorf("Row changed after rejected update: %v", row)
+		}
+	})
+
+	t.Run("UpdatePrimaryKey", func(t *testing.T) {
+		err := db.Update(3, map[string]any{"ID": 30, "NAME": []byte("Chuck")})
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if row, _ := db.PKeyQuery(3); row != nil {
+			t.Error("Old primary key still present")
+		}
+		row, err := db.PKeyQuery(30)
+		if err != nil {
+			t.Fatalf("New primary key not found: %v", err)
+		}
+		if !bytes.Equal(row[1].([]byte), []byte("Chuck")) {
+			t.Errorf("Expected Chuck, got %s", string(row[1].([]byte)))
+		}
+		// * The unique index has to follow the record to its new key
+		rows, err := db.PointQuery(2, []byte("Charlie@example.com"))
+		if err != nil || len(rows) != 1 || rows[0][0] != 30 {
+			t.Errorf("Unique index not re-pointed: %v %v", rows, err)
+		}
+	})
+
+	t.Run("UpdatePrimaryKeyConflict", func(t *testing.T) {
+		err := db.Update(30, map[string]any{"ID": 1})
+		if err == nil {
+			t.Error("Expected primary key conflict, got nil")
+		}
+	})
+
+	t.Run("UpdateUnknownColumn", func(t *testing.T) {
+		err := db.Update(1, map[string]any{"AGE": 3})
+		if err == nil {
+			t.Error("Expected error for unknown column, got nil")
+		}
+	})
+
+	t.Run("UpdatePointPrimaryKey", func(t *testing.T) {
+		err := db.UpdatePoint(0, 4, 40)
+		if err != nil {
+			t.Fatalf("UpdatePoint failed: %v", err)
+		}
+		rows, err := db.PointQuery(2, []byte("Bob@example.com"))
+		if err != nil || len(rows) != 1 || rows[0][0] != 40 {
+			t.Errorf("Unique index not re-pointed: %v %v", rows, err)
+		}
+	})
+}
@@ -0,0 +1,303 @@
+package testing
+
+import (
+	"BynxDB/core"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestVerify tests that Verify passes tables changed through every write path and reports damaged files
+func TestVerify(t *testing.T) {
+	dir := tempDataDir(t)
+	tDef := &core.TableDef{
+		Cols:       []string{"ID", "CODE", "QTY"},
+		Types:      []uint16{core.TYPE_INT64, core.TYPE_BYTE, core.TYPE_INT64},
+		UniqueCols: []int{1},
+	}
+	db, err := core.DbInit("verify_items", tDef)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { db.Close() }()
+	code := func(id int) []byte { return []byte(fmt.Sprintf("CODE-%05d", id)) }
+	file := func(name string) string { return filepath.Join(dir, name+".db") }
+	// * damage closes the table, lets fn change a file of it and reopens it
+	damage := func(name string, fn func(f *os.File)) {
+		t.Helper()
+		db.Close()
+		f, err := os.OpenFile(file(name), os.O_RDWR, 0666)
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		fn(f)
+		f.Close()
+		if db, err = core.OpenDB("verify_items"); err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+	}
+	// * expect checks that Verify reports a problem containing want
+	expect := func(t *testing.T, want string) {
+		t.Helper()
+		_, err := db.Verify()
+		var verifyErr *core.VerifyError
+		if !errors.As(err, &verifyErr) {
+			t.Fatalf("expected a VerifyError, got %v", err)
+		}
+		for _, problem := range verifyErr.Problems {
+			if strings.Contains(problem.String(), want) {
+				return
+			}
+		}
+		t.Errorf("expected a problem about %q, got %v", want, verifyErr.Problems)
+	}
+
+	t.Run("Healthy", func(t *testing.T) {
+		r := rand.New(rand.NewSource(7))
+		ids := r.Perm(2000)
+		for _, id := range ids {
+			if err := db.Insert(id, code(id), id); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		for _, id := range ids[:600] {
+			if err := db.Delete(0, id); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+		}
+		for _, id := range ids[600:800] {
+			if err := db.Update(id, map[string]any{"CODE": code(id + 10000)}); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+		}
+		if _, err := db.DeleteRange("id", 1500, 1700); err != nil {
+			t.Fatalf("DeleteRange failed: %v", err)
+		}
+		var rows [][]any
+		for i := 3000; i < 4000; i++ {
+			rows = append(rows, []any{i, code(i), i})
+		}
+		if err := db.InsertBatch(rows); err != nil {
+			t.Fatalf("InsertBatch failed: %v", err)
+		}
+		report, err := db.Verify()
+		if err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+		count, _ := db.Count()
+		if len(report.Trees) != 2 || report.Trees[0].Items != uint64(count) || report.Trees[1].Items != uint64(count) {
+			t.Errorf("unexpected trees %+v for %d rows", report.Trees, count)
+		}
+		if report.Trees[0].Depth < 2 || report.Trees[0].Nodes < 2 {
+			t.Errorf("unexpected records tree %+v", report.Trees[0])
+		}
+		// * Every node outside the fill bounds is named in a warning
+		outside := 0
+		for _, tree := range report.Trees {
+			outside += tree.Underfilled + tree.Overfilled
+		}
+		if outside == 0 || len(report.Warnings) != outside {
+			t.Errorf("expected a warning for each of %d nodes outside the fill bounds, got %v", outside, report.Warnings)
+		}
+		for _, warning := range report.Warnings {
+			if warning.Page == 0 || !strings.Contains(warning.Msg, "FillPercent") {
+				t.Errorf("unexpected warning %s", warning)
+			}
+		}
+		if _, err := db.Compact(); err != nil {
+			t.Fatalf("Compact failed: %v", err)
+		}
+		if report, err := db.Verify(); err != nil || report.Trees[0].FreePages != 0 {
+			t.Errorf("Verify after Compact failed: %v", err)
+		}
+		if out, exitCode := runCLI(t, "", "check", "verify_items"); exitCode != 0 || !strings.Contains(out, "VERIFY_ITEMS: ok") {
+			t.Errorf("Unexpected check output: %s", out)
+		}
+	})
+
+	t.Run("StaleIndex", func(t *testing.T) {
+		db.Close()
+		saved, err := os.ReadFile(file("VERIFY_ITEMSCODE"))
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if db, err = core.OpenDB("verify_items"); err != nil {
+			t.Fatalf("OpenDB failed: %v", err)
+		}
+		if err := db.Delete(0, 3500); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if err := db.Update(3600, map[string]any{"CODE": code(99999)}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		// * The index goes back to before the delete and the update
+		damage("VERIFY_ITEMSCODE", func(f *os.File) {
+			f.Truncate(0)
+			f.WriteAt(saved, 0)
+		})
+		expect(t, "points at missing row 3500")
+		expect(t, "row 3600 has no entry for 'CODE-99999'")
+		expect(t, "points at row 3600 holding 'CODE-99999'")
+
+		out, exitCode := runCLI(t, "", "check", "verify_items")
+		if exitCode == 0 || !strings.Contains(out, "VERIFY_ITEMS: 3 problems") {
+			t.Errorf("Unexpected check output: %s", out)
+		}
+		if err := db.Drop(); err != nil {
+			t.Fatalf("Drop failed: %v", err)
+		}
+		if db, err = core.DbInit("verify_items", tDef); err != nil {
+			t.Fatalf("DbInit failed: %v", err)
+		}
+		for i := 0; i < 500; i++ {
+			if err := db.Insert(i, code(i), i); err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
+		}
+		if _, err := db.DeleteRange("id", 0, 399); err != nil {
+			t.Fatalf("DeleteRange failed: %v", err)
+		}
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		// * An empty freelist leaks every released page
+		damage("VERIFY_ITEMSrec", func(f *os.File) {
+			rewritePage(t, f, 1, func(data []byte) { binary.LittleEndian.PutUint16(data[8:], 0) })
+		})
+		expect(t, "leaked, neither reachable nor on the freelist")
+
+		// * A wrong row count in the meta page
+		damage("VERIFY_ITEMSrec", func(f *os.File) {
+			rewritePage(t, f, 0, func(data []byte) { binary.LittleEndian.PutUint64(data[40:], 42) })
+		})
+		expect(t, "row count 41, the tree holds 100 items")
+
+		// * The root pointing past the end of the file
+		damage("VERIFY_ITEMSCODE", func(f *os.File) {
+			rewritePage(t, f, 0, func(data []byte) { binary.LittleEndian.PutUint64(data[16:], 1<<20) })
+		})
+		expect(t, "node outside the file")
+
+		// * A damaged page fails its checksum
+		damage("VERIFY_ITEMSrec", func(f *os.File) {
+			info, _ := f.Stat()
+			f.WriteAt([]byte{0xff}, info.Size()-100)
+		})
+		expect(t, "checksum mismatch")
+	})
+}
+
+// TestFreelistChain tests that a freelist too long for a page is chained over more pages, none of them leaked, and
+// read back whole when the table is opened again
+func TestFreelistChain(t *testing.T) {
+	mem := core.NewMemoryStorage()
+	opts := &core.Options{Storage: mem, PageSize: core.MIN_PAGE_SIZE}
+	db, err := core.DbInitWithOptions("freelist_items", &core.TableDef{
+		Cols:  []string{"ID", "NAME"},
+		Types: []uint16{core.TYPE_INT64, core.TYPE_BYTE},
+	}, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { db.Close() }()
+	name := func(id int) []byte { return []byte(fmt.Sprintf("name-%05d-%s", id, strings.Repeat("x", 60))) }
+	for id := 0; id < 4000; id++ {
+		if err := db.Insert(id, name(id)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if _, err := db.DeleteRange("id", 10, 4000); err != nil {
+		t.Fatalf("DeleteRange failed: %v", err)
+	}
+	report, err := db.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	free := report.Trees[0].FreePages
+	// A freelist page of a 1K page holds 124 released pages
+	if free <= 124 {
+		t.Fatalf("expected more released pages than a freelist page holds, got %d", free)
+	}
+
+	db.Close()
+	if db, err = core.OpenDBWithOptions("freelist_items", opts); err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	if report, err = db.Verify(); err != nil {
+		t.Fatalf("Verify after reopening failed: %v", err)
+	}
+	if got := report.Trees[0].FreePages; got != free {
+		t.Errorf("expected %d released pages after reopening, got %d", free, got)
+	}
+	pages := report.Trees[0].Pages
+	for id := 10; id < 2000; id++ {
+		if err := db.Insert(id, name(id)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if report, err = db.Verify(); err != nil {
+		t.Fatalf("Verify after reusing pages failed: %v", err)
+	}
+	if got := report.Trees[0].Pages; got != pages {
+		t.Errorf("expected the released pages to be reused, the file grew from %d to %d pages", pages, got)
+	}
+}
+
+// TestWideFile tests that a file of more than 65536 pages keeps its max page when it is opened again, the pages it
+// hands out afterwards being new ones
+func TestWideFile(t *testing.T) {
+	mem := core.NewMemoryStorage()
+	opts := &core.Options{Storage: mem, PageSize: core.MIN_PAGE_SIZE, MinFillPercent: 0.05, MaxFillPercent: 0.1}
+	db, err := core.DbInitWithOptions("wide_items", &core.TableDef{
+		Cols:  []string{"ID", "NAME"},
+		Types: []uint16{core.TYPE_INT64, core.TYPE_BYTE},
+	}, opts)
+	if err != nil {
+		t.Fatalf("DbInit failed: %v", err)
+	}
+	defer func() { db.Close() }()
+	// * A node holds two or three rows
+	name := func(id int) []byte { return []byte(fmt.Sprintf("name-%06d", id)) }
+	const numRecords = 200000
+	rows := make([][]any, numRecords)
+	for id := range rows {
+		rows[id] = []any{id, name(id)}
+	}
+	if _, err := db.BulkLoad(core.SliceIterator(rows), nil); err != nil {
+		t.Fatalf("BulkLoad failed: %v", err)
+	}
+	if _, err := db.Verify(); err != nil {
+		t.Fatalf("Verify after loading failed: %v", err)
+	}
+	db.Close()
+
+	if db, err = core.OpenDBWithOptions("wide_items", opts); err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	report, err := db.Verify()
+	if err != nil {
+		t.Fatalf("Verify after reopening failed: %v", err)
+	}
+	if pages := report.Trees[0].Pages; pages <= 1<<16 {
+		t.Fatalf("expected more than %d pages, got %d", 1<<16, pages)
+	}
+	for id := numRecords; id < numRecords+2000; id++ {
+		if err := db.Insert(id, name(id)); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	if _, err := db.Verify(); err != nil {
+		t.Fatalf("Verify after inserting failed: %v", err)
+	}
+	for id := 0; id < numRecords+2000; id += 97 {
+		if row, err := db.PKeyQuery(id); err != nil || row == nil || !bytes.Equal(row[1].([]byte), name(id)) {
+			t.Fatalf("row %d lost after inserting: %v %v", id, row, err)
+		}
+	}
+}